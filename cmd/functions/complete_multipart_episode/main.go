@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/LucasAndFlores/go_lambdas_project/config"
+	"github.com/LucasAndFlores/go_lambdas_project/constant"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/service"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/ulid"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+type HttpRequest = events.APIGatewayProxyRequest
+
+type HttpResponse struct {
+	StatusCode int    `json:"statusCode"`
+	Body       string `json:"body"`
+}
+
+type handler struct {
+	service service.IAudioService
+}
+
+// handleRequest joins the recorded parts into the episode, which can then
+// be cut into clips.
+func (h *handler) handleRequest(ctx context.Context, request HttpRequest) (HttpResponse, error) {
+	param := request.PathParameters["id"]
+
+	if param == "" {
+		return HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       constant.MISSING_PARAM_ERROR,
+		}, nil
+	}
+
+	if !ulid.Valid(param) {
+		return HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       constant.INVALID_ID_ERROR,
+		}, nil
+	}
+
+	err := h.service.CompleteMultipartUpload(ctx, param)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, service.MultipartNotFoundErr):
+			return HttpResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       err.Error(),
+			}, nil
+
+		case errors.Is(err, service.MissingPartsErr):
+			return HttpResponse{
+				StatusCode: http.StatusConflict,
+				Body:       err.Error(),
+			}, nil
+
+		case errors.Is(err, service.MultipartNotPendingErr):
+			return HttpResponse{
+				StatusCode: http.StatusConflict,
+				Body:       err.Error(),
+			}, nil
+
+		default:
+			return HttpResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       constant.INTERNAL_SERVER_ERROR,
+			}, nil
+		}
+	}
+
+	return HttpResponse{
+		StatusCode: http.StatusNoContent,
+	}, nil
+}
+
+func main() {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+
+	if err != nil {
+		log.Fatalf("An error occurred when tried to load AWS config. Error: %v", err)
+	}
+
+	bucket := s3.NewFromConfig(cfg)
+	preSigned := s3.NewPresignClient(bucket)
+
+	dynamo := dynamodb.NewFromConfig(cfg)
+
+	s := service.NewAudioService(preSigned, bucket, dynamo)
+	h := handler{service: s}
+
+	lambda.Start(h.handleRequest)
+}
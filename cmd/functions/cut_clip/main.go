@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/LucasAndFlores/go_lambdas_project/config"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/service"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+type handler struct {
+	clipService service.IClipService
+}
+
+// handleRequest cuts the clips inserted in the clips table. Returning an
+// error makes Lambda retry the whole batch, which is safe because clips
+// already cut are skipped.
+func (h *handler) handleRequest(ctx context.Context, event events.DynamoDBEvent) error {
+	for _, record := range event.Records {
+		if record.EventName != string(events.DynamoDBOperationTypeInsert) {
+			continue
+		}
+
+		id, ok := record.Change.Keys["id"]
+
+		if !ok || id.DataType() != events.DataTypeString {
+			continue
+		}
+
+		err := h.clipService.CutClip(ctx, id.String())
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func main() {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+
+	if err != nil {
+		log.Fatalf("An error occurred when tried to load AWS config. Error: %v", err)
+	}
+
+	s3Client := s3.NewFromConfig(cfg)
+
+	dynamo := dynamodb.NewFromConfig(cfg)
+
+	s := service.NewClipService(s3Client, dynamo)
+	h := handler{clipService: s}
+
+	lambda.Start(h.handleRequest)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/LucasAndFlores/go_lambdas_project/config"
+	"github.com/LucasAndFlores/go_lambdas_project/constant"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/service"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/ulid"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+type HttpRequest = events.APIGatewayProxyRequest
+
+type HttpResponse struct {
+	StatusCode int    `json:"statusCode"`
+	Body       string `json:"body"`
+}
+
+type handler struct {
+	service service.IMetadataService
+}
+
+func (h *handler) handleRequest(ctx context.Context, request HttpRequest) (HttpResponse, error) {
+	param := request.PathParameters["id"]
+
+	if param == "" {
+		return HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       constant.MISSING_PARAM_ERROR,
+		}, nil
+	}
+
+	if !ulid.Valid(param) {
+		return HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       constant.INVALID_ID_ERROR,
+		}, nil
+	}
+
+	metadataOnly := false
+
+	if value := request.QueryStringParameters["metadataOnly"]; value != "" {
+		parsed, err := strconv.ParseBool(value)
+
+		if err != nil {
+			return HttpResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       constant.INVALID_PARAM_ERROR,
+			}, nil
+		}
+
+		metadataOnly = parsed
+	}
+
+	err := h.service.DeleteItem(ctx, param, metadataOnly)
+
+	if err != nil {
+		if errors.Is(err, service.ItemNotFoundErr) {
+			return HttpResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       err.Error(),
+			}, nil
+		}
+
+		return HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       constant.INTERNAL_SERVER_ERROR,
+		}, nil
+	}
+
+	return HttpResponse{
+		StatusCode: http.StatusNoContent,
+	}, nil
+}
+
+func main() {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+
+	if err != nil {
+		log.Fatalf("An error occurred when tried to load AWS config. Error: %v", err)
+	}
+
+	s3Client := s3.NewFromConfig(cfg)
+
+	dynamo := dynamodb.NewFromConfig(cfg)
+
+	s := service.NewMetadataService(s3Client, dynamo)
+	h := handler{service: s}
+
+	lambda.Start(h.handleRequest)
+}
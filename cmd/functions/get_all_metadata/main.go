@@ -3,24 +3,30 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/LucasAndFlores/go_lambdas_project/config"
 	"github.com/LucasAndFlores/go_lambdas_project/constant"
 	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
 	"github.com/LucasAndFlores/go_lambdas_project/internal/service"
+	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
+type HttpRequest = events.APIGatewayProxyRequest
+
 type handler struct {
 	service service.IMetadataService
 }
 
 type HttpBodyResponse struct {
-	Metadata []dto.MetadataDTOOutput `json:"metadata"`
+	Metadata   []dto.MetadataDTOOutput `json:"metadata"`
+	NextCursor string                  `json:"nextCursor,omitempty"`
 }
 
 type Response struct {
@@ -28,17 +34,42 @@ type Response struct {
 	Body       string `json:"body"`
 }
 
-func (h *handler) handleRequest(ctx context.Context) (Response, error) {
-	metadata, err := h.service.ListAllItems(ctx)
+func (h *handler) handleRequest(ctx context.Context, request HttpRequest) (Response, error) {
+	input, err := parseListInput(request.QueryStringParameters)
 
 	if err != nil {
+		return Response{
+			StatusCode: http.StatusBadRequest,
+			Body:       constant.INVALID_PARAM_ERROR,
+		}, nil
+	}
+
+	// Following every page scans the whole table, which only admin tooling
+	// may afford.
+	if input.All && !service.IsAdmin(service.AdminToken(request.Headers)) {
+		return Response{
+			StatusCode: http.StatusForbidden,
+			Body:       "Only admins can list every page at once",
+		}, nil
+	}
+
+	metadata, err := h.service.ListAllItems(ctx, input)
+
+	if err != nil {
+		if errors.Is(err, service.InvalidCursorErr) {
+			return Response{
+				StatusCode: http.StatusBadRequest,
+				Body:       err.Error(),
+			}, nil
+		}
+
 		return Response{
 			StatusCode: http.StatusInternalServerError,
 			Body:       constant.INTERNAL_SERVER_ERROR,
 		}, nil
 	}
 
-	bytes, err := json.Marshal(HttpBodyResponse{Metadata: metadata})
+	bytes, err := json.Marshal(HttpBodyResponse{Metadata: metadata.Metadata, NextCursor: metadata.NextCursor})
 
 	if err != nil {
 		return Response{
@@ -53,6 +84,37 @@ func (h *handler) handleRequest(ctx context.Context) (Response, error) {
 	}, nil
 }
 
+func parseListInput(params map[string]string) (dto.MetadataListInput, error) {
+	input := dto.MetadataListInput{
+		Cursor: params["cursor"],
+		Author: params["author"],
+		Label:  params["label"],
+		Type:   params["type"],
+	}
+
+	if limit := params["limit"]; limit != "" {
+		parsed, err := strconv.ParseInt(limit, 10, 32)
+
+		if err != nil || parsed < 1 || int32(parsed) > service.MAX_PAGE_SIZE {
+			return dto.MetadataListInput{}, errors.New("invalid limit")
+		}
+
+		input.Limit = int32(parsed)
+	}
+
+	if all := params["all"]; all != "" {
+		parsed, err := strconv.ParseBool(all)
+
+		if err != nil {
+			return dto.MetadataListInput{}, errors.New("invalid all flag")
+		}
+
+		input.All = parsed
+	}
+
+	return input, nil
+}
+
 func main() {
 	cfg, err := config.LoadDefaultConfig(context.Background())
 
@@ -60,6 +122,11 @@ func main() {
 		log.Fatalf("An error occurred when tried to load AWS config. Error: %v", err)
 	}
 
+	// Without a secret, anyone could sign a cursor starting anywhere.
+	if service.CURSOR_SECRET == "" {
+		log.Fatalf("CURSOR_SECRET is required to sign the pagination cursors")
+	}
+
 	s3Client := s3.NewFromConfig(cfg)
 
 	dynamo := dynamodb.NewFromConfig(cfg)
@@ -3,14 +3,20 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/LucasAndFlores/go_lambdas_project/config"
 	"github.com/LucasAndFlores/go_lambdas_project/constant"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
 	"github.com/LucasAndFlores/go_lambdas_project/internal/service"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/ulid"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
@@ -18,6 +24,7 @@ type HttpRequest = events.APIGatewayProxyRequest
 
 type HttpBodyResponse struct {
 	Url string `json:"url"`
+	dto.AudioObjectDTO
 }
 
 type HttpResponse struct {
@@ -26,11 +33,13 @@ type HttpResponse struct {
 }
 
 type handler struct {
-	service service.IAudioService
+	service         service.IAudioService
+	metadataService service.IMetadataService
+	expires         time.Duration
 }
 
 func (h *handler) handleRequest(ctx context.Context, request HttpRequest) (HttpResponse, error) {
-	param := request.PathParameters["filename"]
+	param := request.PathParameters["id"]
 
 	if param == "" {
 		return HttpResponse{
@@ -39,16 +48,70 @@ func (h *handler) handleRequest(ctx context.Context, request HttpRequest) (HttpR
 		}, nil
 	}
 
-	url, err := h.service.GeneratePreSignedGetURL(param, ctx)
+	if !ulid.Valid(param) {
+		return HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       constant.INVALID_ID_ERROR,
+		}, nil
+	}
+
+	download := false
+
+	if value := request.QueryStringParameters["download"]; value != "" {
+		parsed, err := strconv.ParseBool(value)
+
+		if err != nil {
+			return HttpResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       constant.INVALID_PARAM_ERROR,
+			}, nil
+		}
+
+		download = parsed
+	}
+
+	object, err := h.service.HeadAudio(param, ctx)
 
 	if err != nil {
+		if errors.Is(err, service.AudioNotFoundErr) {
+			return HttpResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       err.Error(),
+			}, nil
+		}
+
 		return HttpResponse{
 			StatusCode: http.StatusInternalServerError,
 			Body:       constant.INTERNAL_SERVER_ERROR,
 		}, nil
 	}
 
-	bytes, err := json.Marshal(HttpBodyResponse{Url: url})
+	options := dto.PresignOptions{Expires: h.expires}
+
+	// Audio without metadata yet is still served, under its key.
+	metadata, err := h.metadataService.GetItem(ctx, param)
+
+	if err == nil {
+		options = service.DownloadOptions(metadata, h.expires)
+	} else if !errors.Is(err, service.ItemNotFoundErr) {
+		return HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       constant.INTERNAL_SERVER_ERROR,
+		}, nil
+	}
+
+	options.Attachment = download
+
+	url, err := h.service.GeneratePreSignedGetURL(param, options, ctx)
+
+	if err != nil {
+		return HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       constant.INTERNAL_SERVER_ERROR,
+		}, nil
+	}
+
+	bytes, err := json.Marshal(HttpBodyResponse{Url: url, AudioObjectDTO: object})
 
 	if err != nil {
 		return HttpResponse{
@@ -70,11 +133,20 @@ func main() {
 		log.Fatalf("An error occurred when tried to load AWS config. Error: %v", err)
 	}
 
+	expires, err := service.URLExpiry()
+
+	if err != nil {
+		log.Fatalf("An error occurred when tried to read URL_EXPIRES. Error: %v", err)
+	}
+
 	bucket := s3.NewFromConfig(cfg)
 	preSigned := s3.NewPresignClient(bucket)
 
-	s := service.NewAudioService(preSigned)
-	h := handler{service: s}
+	dynamo := dynamodb.NewFromConfig(cfg)
+
+	s := service.NewAudioService(preSigned, bucket, dynamo)
+	m := service.NewMetadataService(bucket, dynamo)
+	h := handler{service: s, metadataService: m, expires: expires}
 
 	lambda.Start(h.handleRequest)
 }
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LucasAndFlores/go_lambdas_project/config"
+	"github.com/LucasAndFlores/go_lambdas_project/constant"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/service"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/ulid"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+type HttpRequest = events.APIGatewayProxyRequest
+
+type HttpBodyResponse struct {
+	Metadata dto.MetadataDTOOutput `json:"metadata"`
+	Url      string                `json:"url,omitempty"`
+}
+
+type HttpResponse struct {
+	StatusCode int    `json:"statusCode"`
+	Body       string `json:"body"`
+}
+
+type handler struct {
+	service      service.IMetadataService
+	audioService service.IAudioService
+	expires      time.Duration
+}
+
+func (h *handler) handleRequest(ctx context.Context, request HttpRequest) (HttpResponse, error) {
+	param := request.PathParameters["id"]
+
+	if param == "" {
+		return HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       constant.MISSING_PARAM_ERROR,
+		}, nil
+	}
+
+	if !ulid.Valid(param) {
+		return HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       constant.INVALID_ID_ERROR,
+		}, nil
+	}
+
+	includeUrl := false
+
+	if value := request.QueryStringParameters["includeUrl"]; value != "" {
+		parsed, err := strconv.ParseBool(value)
+
+		if err != nil {
+			return HttpResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       constant.INVALID_PARAM_ERROR,
+			}, nil
+		}
+
+		includeUrl = parsed
+	}
+
+	download := false
+
+	if value := request.QueryStringParameters["download"]; value != "" {
+		parsed, err := strconv.ParseBool(value)
+
+		if err != nil {
+			return HttpResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       constant.INVALID_PARAM_ERROR,
+			}, nil
+		}
+
+		download = parsed
+	}
+
+	metadata, err := h.service.GetItem(ctx, param)
+
+	if err != nil {
+		if errors.Is(err, service.ItemNotFoundErr) {
+			return HttpResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       err.Error(),
+			}, nil
+		}
+
+		return HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       constant.INTERNAL_SERVER_ERROR,
+		}, nil
+	}
+
+	response := HttpBodyResponse{Metadata: metadata}
+
+	if includeUrl {
+		options := service.DownloadOptions(metadata, h.expires)
+		options.Attachment = download
+
+		url, err := h.audioService.GeneratePreSignedGetURL(param, options, ctx)
+
+		if err != nil {
+			return HttpResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       constant.INTERNAL_SERVER_ERROR,
+			}, nil
+		}
+
+		response.Url = url
+	}
+
+	bytes, err := json.Marshal(response)
+
+	if err != nil {
+		return HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       constant.INTERNAL_SERVER_ERROR,
+		}, nil
+	}
+
+	return HttpResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(bytes),
+	}, nil
+}
+
+func main() {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+
+	if err != nil {
+		log.Fatalf("An error occurred when tried to load AWS config. Error: %v", err)
+	}
+
+	expires, err := service.URLExpiry()
+
+	if err != nil {
+		log.Fatalf("An error occurred when tried to read URL_EXPIRES. Error: %v", err)
+	}
+
+	s3Client := s3.NewFromConfig(cfg)
+	preSigned := s3.NewPresignClient(s3Client)
+
+	dynamo := dynamodb.NewFromConfig(cfg)
+
+	s := service.NewMetadataService(s3Client, dynamo)
+	a := service.NewAudioService(preSigned, s3Client, dynamo)
+	h := handler{service: s, audioService: a, expires: expires}
+
+	lambda.Start(h.handleRequest)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/LucasAndFlores/go_lambdas_project/config"
+	"github.com/LucasAndFlores/go_lambdas_project/constant"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/service"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/ulid"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+type HttpRequest = events.APIGatewayProxyRequest
+
+type HttpResponse struct {
+	StatusCode int    `json:"statusCode"`
+	Body       string `json:"body"`
+}
+
+type handler struct {
+	service service.IAudioService
+	expires time.Duration
+}
+
+// handleRequest returns the progress of an episode uploaded in parts, with
+// fresh URLs for the parts left, so an interrupted upload can resume.
+func (h *handler) handleRequest(ctx context.Context, request HttpRequest) (HttpResponse, error) {
+	param := request.PathParameters["id"]
+
+	if param == "" {
+		return HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       constant.MISSING_PARAM_ERROR,
+		}, nil
+	}
+
+	if !ulid.Valid(param) {
+		return HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       constant.INVALID_ID_ERROR,
+		}, nil
+	}
+
+	upload, err := h.service.GetMultipartUpload(ctx, param, dto.PresignOptions{Expires: h.expires})
+
+	if err != nil {
+		if errors.Is(err, service.MultipartNotFoundErr) {
+			return HttpResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       err.Error(),
+			}, nil
+		}
+
+		return HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       constant.INTERNAL_SERVER_ERROR,
+		}, nil
+	}
+
+	bytes, err := json.Marshal(upload)
+
+	if err != nil {
+		return HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       constant.INTERNAL_SERVER_ERROR,
+		}, nil
+	}
+
+	return HttpResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(bytes),
+	}, nil
+}
+
+func main() {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+
+	if err != nil {
+		log.Fatalf("An error occurred when tried to load AWS config. Error: %v", err)
+	}
+
+	expires, err := service.URLExpiry()
+
+	if err != nil {
+		log.Fatalf("An error occurred when tried to read URL_EXPIRES. Error: %v", err)
+	}
+
+	bucket := s3.NewFromConfig(cfg)
+	preSigned := s3.NewPresignClient(bucket)
+
+	dynamo := dynamodb.NewFromConfig(cfg)
+
+	s := service.NewAudioService(preSigned, bucket, dynamo)
+	h := handler{service: s, expires: expires}
+
+	lambda.Start(h.handleRequest)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/LucasAndFlores/go_lambdas_project/config"
+	"github.com/LucasAndFlores/go_lambdas_project/constant"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/service"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/ulid"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// CACHE_CONTROL lets players and CDNs keep a waveform for a day. The ETag
+// makes revalidating it afterwards cheap.
+const CACHE_CONTROL = "public, max-age=86400"
+
+type HttpRequest = events.APIGatewayProxyRequest
+
+type HttpResponse struct {
+	StatusCode int               `json:"statusCode"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       string            `json:"body"`
+}
+
+type handler struct {
+	service service.IWaveformService
+}
+
+func (h *handler) handleRequest(ctx context.Context, request HttpRequest) (HttpResponse, error) {
+	param := request.PathParameters["id"]
+
+	if param == "" {
+		return HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       constant.MISSING_PARAM_ERROR,
+		}, nil
+	}
+
+	if !ulid.Valid(param) {
+		return HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       constant.INVALID_ID_ERROR,
+		}, nil
+	}
+
+	waveform, err := h.service.GetWaveform(ctx, param)
+
+	if err != nil {
+		if errors.Is(err, service.WaveformNotFoundErr) {
+			return HttpResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       err.Error(),
+			}, nil
+		}
+
+		return HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       constant.INTERNAL_SERVER_ERROR,
+		}, nil
+	}
+
+	headers := map[string]string{
+		"Cache-Control": CACHE_CONTROL,
+		"ETag":          waveform.ETag,
+	}
+
+	if waveform.ETag != "" && header(request, "If-None-Match") == waveform.ETag {
+		return HttpResponse{
+			StatusCode: http.StatusNotModified,
+			Headers:    headers,
+		}, nil
+	}
+
+	headers["Content-Type"] = "application/json"
+
+	return HttpResponse{
+		StatusCode: http.StatusOK,
+		Headers:    headers,
+		Body:       string(waveform.Body),
+	}, nil
+}
+
+// header looks name up ignoring case, as HTTP/2 clients send lower case
+// names.
+func header(request HttpRequest, name string) string {
+	for key, value := range request.Headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+
+	return ""
+}
+
+func main() {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+
+	if err != nil {
+		log.Fatalf("An error occurred when tried to load AWS config. Error: %v", err)
+	}
+
+	s3Client := s3.NewFromConfig(cfg)
+
+	s := service.NewWaveformService(s3Client)
+	h := handler{service: s}
+
+	lambda.Start(h.handleRequest)
+}
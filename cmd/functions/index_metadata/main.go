@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/LucasAndFlores/go_lambdas_project/config"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/service"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+type handler struct {
+	searchService  service.ISearchService
+	suggestService service.ISuggestService
+}
+
+// handleRequest keeps the search and suggestion indexes in sync with the
+// metadata table stream. Returning an error makes Lambda retry the whole
+// batch. The search index is written as is, so writing it again is harmless,
+// while the suggestion counters skip the records already counted.
+func (h *handler) handleRequest(ctx context.Context, event events.DynamoDBEvent) error {
+	for _, record := range event.Records {
+		id := stringAttribute(record.Change.Keys, "id")
+
+		if id == "" {
+			continue
+		}
+
+		oldWords := stringAttribute(record.Change.OldImage, "words")
+		newWords := stringAttribute(record.Change.NewImage, "words")
+
+		err := h.searchService.IndexItem(ctx, id, oldWords, newWords)
+
+		if err != nil {
+			return err
+		}
+
+		for _, kind := range []string{service.KIND_AUTHOR, service.KIND_LABEL} {
+			oldValue := stringAttribute(record.Change.OldImage, kind)
+			newValue := stringAttribute(record.Change.NewImage, kind)
+
+			err = h.suggestService.IndexItem(ctx, record.EventID, kind, oldValue, newValue)
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func stringAttribute(image map[string]events.DynamoDBAttributeValue, name string) string {
+	value, ok := image[name]
+
+	if !ok || value.DataType() != events.DataTypeString {
+		return ""
+	}
+
+	return value.String()
+}
+
+func main() {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+
+	if err != nil {
+		log.Fatalf("An error occurred when tried to load AWS config. Error: %v", err)
+	}
+
+	dynamo := dynamodb.NewFromConfig(cfg)
+
+	s := service.NewSearchService(dynamo)
+	sg := service.NewSuggestService(dynamo)
+	h := handler{searchService: s, suggestService: sg}
+
+	lambda.Start(h.handleRequest)
+}
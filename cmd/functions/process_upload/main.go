@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/LucasAndFlores/go_lambdas_project/config"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/service"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+type handler struct {
+	uploadService service.IUploadService
+}
+
+// handleRequest validates and marks the uploads whose object was created on
+// S3. Objects that don't belong to a pending upload are logged and skipped,
+// so a re-sent event doesn't make Lambda retry forever.
+func (h *handler) handleRequest(ctx context.Context, event events.S3Event) error {
+	for _, record := range event.Records {
+		id := record.S3.Object.URLDecodedKey
+
+		// Objects written by the pipeline itself, and episodes, which are only
+		// cut into clips, trigger this lambda too.
+		if service.IsQuarantined(id) || service.IsWaveform(id) || service.IsArchived(id) || service.IsEpisode(id) {
+			continue
+		}
+
+		err := h.uploadService.ProcessUpload(ctx, id, record.S3.Object.Size)
+
+		if errors.Is(err, service.UploadNotPendingErr) {
+			log.Printf("Skipping the object %s: %v", id, err)
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func main() {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+
+	if err != nil {
+		log.Fatalf("An error occurred when tried to load AWS config. Error: %v", err)
+	}
+
+	s3Client := s3.NewFromConfig(cfg)
+
+	dynamo := dynamodb.NewFromConfig(cfg)
+
+	u := service.NewUploadService(s3Client, dynamo)
+	h := handler{uploadService: u}
+
+	lambda.Start(h.handleRequest)
+}
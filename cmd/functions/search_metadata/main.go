@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/LucasAndFlores/go_lambdas_project/config"
+	"github.com/LucasAndFlores/go_lambdas_project/constant"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/service"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+type HttpRequest = events.APIGatewayProxyRequest
+
+type HttpBodyResponse struct {
+	Results []dto.SearchResultDTO `json:"results"`
+}
+
+type HttpResponse struct {
+	StatusCode int    `json:"statusCode"`
+	Body       string `json:"body"`
+}
+
+type handler struct {
+	service service.ISearchService
+}
+
+func (h *handler) handleRequest(ctx context.Context, request HttpRequest) (HttpResponse, error) {
+	query := request.QueryStringParameters["q"]
+
+	if query == "" {
+		return HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       constant.MISSING_PARAM_ERROR,
+		}, nil
+	}
+
+	var limit int32
+
+	if value := request.QueryStringParameters["limit"]; value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 32)
+
+		if err != nil || parsed < 1 || int32(parsed) > service.MAX_SEARCH_LIMIT {
+			return HttpResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       constant.INVALID_PARAM_ERROR,
+			}, nil
+		}
+
+		limit = int32(parsed)
+	}
+
+	results, err := h.service.Search(ctx, query, limit)
+
+	if err != nil {
+		if errors.Is(err, service.EmptySearchErr) {
+			return HttpResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       err.Error(),
+			}, nil
+		}
+
+		return HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       constant.INTERNAL_SERVER_ERROR,
+		}, nil
+	}
+
+	bytes, err := json.Marshal(HttpBodyResponse{Results: results})
+
+	if err != nil {
+		return HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       constant.INTERNAL_SERVER_ERROR,
+		}, nil
+	}
+
+	return HttpResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(bytes),
+	}, nil
+}
+
+func main() {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+
+	if err != nil {
+		log.Fatalf("An error occurred when tried to load AWS config. Error: %v", err)
+	}
+
+	dynamo := dynamodb.NewFromConfig(cfg)
+
+	s := service.NewSearchService(dynamo)
+	h := handler{service: s}
+
+	lambda.Start(h.handleRequest)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/LucasAndFlores/go_lambdas_project/config"
+	"github.com/LucasAndFlores/go_lambdas_project/constant"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/service"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+type HttpRequest = events.APIGatewayProxyRequest
+
+type HttpResponse struct {
+	StatusCode int    `json:"statusCode"`
+	Body       string `json:"body"`
+}
+
+type handler struct {
+	service service.IAudioService
+	expires time.Duration
+}
+
+// handleRequest starts uploading an episode too big for a single PUT. The
+// parts are uploaded to the URLs returned, recorded on
+// PUT /episodes/{id}/multipart/parts/{number} and joined on
+// POST /episodes/{id}/multipart/complete.
+func (h *handler) handleRequest(ctx context.Context, request HttpRequest) (HttpResponse, error) {
+	var parsedBody dto.MultipartDTOInput
+
+	err := json.Unmarshal([]byte(request.Body), &parsedBody)
+
+	if err != nil {
+		return HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       "Unable to process the body. Please, review the content",
+		}, nil
+	}
+
+	validatonErr := parsedBody.Validate()
+
+	if validatonErr != nil {
+		bytes, err := json.Marshal(map[string]interface{}{"errors": validatonErr})
+
+		if err != nil {
+			return HttpResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       constant.INTERNAL_SERVER_ERROR,
+			}, nil
+		}
+		return HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       string(bytes),
+		}, nil
+	}
+
+	upload, err := h.service.CreateMultipartUpload(ctx, parsedBody.Size, dto.PresignOptions{Expires: h.expires})
+
+	if err != nil {
+		return HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       constant.INTERNAL_SERVER_ERROR,
+		}, nil
+	}
+
+	bytes, err := json.Marshal(upload)
+
+	if err != nil {
+		return HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       constant.INTERNAL_SERVER_ERROR,
+		}, nil
+	}
+
+	return HttpResponse{
+		StatusCode: http.StatusCreated,
+		Body:       string(bytes),
+	}, nil
+}
+
+func main() {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+
+	if err != nil {
+		log.Fatalf("An error occurred when tried to load AWS config. Error: %v", err)
+	}
+
+	expires, err := service.URLExpiry()
+
+	if err != nil {
+		log.Fatalf("An error occurred when tried to read URL_EXPIRES. Error: %v", err)
+	}
+
+	bucket := s3.NewFromConfig(cfg)
+	preSigned := s3.NewPresignClient(bucket)
+
+	dynamo := dynamodb.NewFromConfig(cfg)
+
+	s := service.NewAudioService(preSigned, bucket, dynamo)
+	h := handler{service: s, expires: expires}
+
+	lambda.Start(h.handleRequest)
+}
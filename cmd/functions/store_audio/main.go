@@ -3,22 +3,29 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/LucasAndFlores/go_lambdas_project/config"
 	"github.com/LucasAndFlores/go_lambdas_project/constant"
 	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
 	"github.com/LucasAndFlores/go_lambdas_project/internal/service"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/ulid"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
 type HttpRequest = events.APIGatewayProxyRequest
 
 type HttpBodyResponse struct {
-	Url string `json:"url"`
+	ID       string            `json:"id"`
+	FileName string            `json:"filename"`
+	Url      string            `json:"url"`
+	Headers  map[string]string `json:"headers"`
 }
 
 type HttpResponse struct {
@@ -27,10 +34,30 @@ type HttpResponse struct {
 }
 
 type handler struct {
-	service service.IAudioService
+	service       service.IAudioService
+	uploadService service.IUploadService
+	expires       time.Duration
 }
 
+// handleRequest hands out the URL to upload a new audio to. With the replace
+// query parameter and the admin token, it hands out one that overwrites the
+// audio stored under that id instead, after archiving it.
 func (h *handler) handleRequest(ctx context.Context, request HttpRequest) (HttpResponse, error) {
+	replace := request.QueryStringParameters["replace"]
+
+	if replace != "" && !service.IsAdmin(service.AdminToken(request.Headers)) {
+		return HttpResponse{
+			StatusCode: http.StatusForbidden,
+			Body:       "Only admins can replace an audio",
+		}, nil
+	}
+
+	if replace != "" && !ulid.Valid(replace) {
+		return HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       constant.INVALID_ID_ERROR,
+		}, nil
+	}
 
 	var parsedBody dto.AudioDTOInput
 
@@ -43,16 +70,98 @@ func (h *handler) handleRequest(ctx context.Context, request HttpRequest) (HttpR
 		}, nil
 	}
 
-	url, err := h.service.GeneratePreSignedPutURL(parsedBody.Filename, ctx)
+	validatonErr := parsedBody.Validate()
+
+	if validatonErr != nil {
+		bytes, err := json.Marshal(map[string]interface{}{"errors": validatonErr})
+
+		if err != nil {
+			return HttpResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       constant.INTERNAL_SERVER_ERROR,
+			}, nil
+		}
+		return HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       string(bytes),
+		}, nil
+	}
+
+	options := dto.PresignOptions{Expires: h.expires}
+
+	if replace != "" {
+		presigned, err := h.service.GeneratePreSignedReplaceURL(replace, parsedBody, options, ctx)
+
+		if err != nil {
+			if errors.Is(err, service.AudioNotFoundErr) {
+				return HttpResponse{
+					StatusCode: http.StatusNotFound,
+					Body:       err.Error(),
+				}, nil
+			}
+
+			if errors.Is(err, service.FileNotFoundErr) {
+				return HttpResponse{
+					StatusCode: http.StatusUnprocessableEntity,
+					Body:       err.Error(),
+				}, nil
+			}
+
+			return HttpResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       constant.INTERNAL_SERVER_ERROR,
+			}, nil
+		}
+
+		return response(HttpBodyResponse{ID: replace, FileName: parsedBody.Filename, Url: presigned.Url, Headers: presigned.Headers})
+	}
+
+	id := ulid.New()
+
+	err = h.uploadService.CreateUpload(ctx, id, parsedBody.Filename)
 
 	if err != nil {
+		if errors.Is(err, service.ConfilctErr) {
+			return HttpResponse{
+				StatusCode: http.StatusConflict,
+				Body:       err.Error(),
+			}, nil
+		}
+
+		return HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       constant.INTERNAL_SERVER_ERROR,
+		}, nil
+	}
+
+	presigned, err := h.service.GeneratePreSignedPutURL(id, parsedBody, options, ctx)
+
+	if err != nil {
+		// The upload would otherwise stay pending until it expires, with no
+		// URL to ever reach it.
+		if h.uploadService.DeleteUpload(ctx, id) != nil {
+			log.Printf("Unable to delete the upload %s, which will expire instead", id)
+		}
+
+		if errors.Is(err, service.ConfilctErr) {
+			return HttpResponse{
+				StatusCode: http.StatusConflict,
+				Body:       err.Error(),
+			}, nil
+		}
+
 		return HttpResponse{
 			StatusCode: http.StatusInternalServerError,
 			Body:       constant.INTERNAL_SERVER_ERROR,
 		}, nil
 	}
 
-	bytes, err := json.Marshal(HttpBodyResponse{Url: url})
+	return response(HttpBodyResponse{ID: id, FileName: parsedBody.Filename, Url: presigned.Url, Headers: presigned.Headers})
+}
+
+func response(body HttpBodyResponse) (HttpResponse, error) {
+
+	bytes, err := json.Marshal(body)
 
 	if err != nil {
 		return HttpResponse{
@@ -74,11 +183,20 @@ func main() {
 		log.Fatalf("An error occurred when tried to load AWS config. Error: %v", err)
 	}
 
+	expires, err := service.URLExpiry()
+
+	if err != nil {
+		log.Fatalf("An error occurred when tried to read URL_EXPIRES. Error: %v", err)
+	}
+
 	bucket := s3.NewFromConfig(cfg)
 	preSigned := s3.NewPresignClient(bucket)
 
-	s := service.NewAudioService(preSigned)
-	h := handler{service: s}
+	dynamo := dynamodb.NewFromConfig(cfg)
+
+	s := service.NewAudioService(preSigned, bucket, dynamo)
+	u := service.NewUploadService(bucket, dynamo)
+	h := handler{service: s, uploadService: u, expires: expires}
 
 	lambda.Start(h.handleRequest)
 }
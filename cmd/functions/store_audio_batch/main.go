@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/LucasAndFlores/go_lambdas_project/config"
+	"github.com/LucasAndFlores/go_lambdas_project/constant"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/service"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/ulid"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+type HttpRequest = events.APIGatewayProxyRequest
+
+type HttpBodyResponse struct {
+	Results []dto.BatchResultDTO `json:"results"`
+}
+
+type HttpResponse struct {
+	StatusCode int    `json:"statusCode"`
+	Body       string `json:"body"`
+}
+
+type handler struct {
+	service       service.IAudioService
+	uploadService service.IUploadService
+	expires       time.Duration
+	limit         int
+}
+
+// handleRequest hands out the upload URLs of many audio at once, as POST
+// /audio does for one. Items are handled on their own: an invalid or failed
+// item gets its reason in its result and doesn't stop the others.
+func (h *handler) handleRequest(ctx context.Context, request HttpRequest) (HttpResponse, error) {
+	var parsedBody dto.AudioBatchDTOInput
+
+	err := json.Unmarshal([]byte(request.Body), &parsedBody)
+
+	if err != nil {
+		return HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       "Unable to process the body. Please, review the content",
+		}, nil
+	}
+
+	if len(parsedBody.Items) == 0 || len(parsedBody.Items) > h.limit {
+		return HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       fmt.Sprintf("The batch should have between 1 and %d items", h.limit),
+		}, nil
+	}
+
+	results := make([]dto.BatchResultDTO, len(parsedBody.Items))
+
+	for index, item := range parsedBody.Items {
+		results[index] = h.presign(ctx, index, item)
+	}
+
+	bytes, err := json.Marshal(HttpBodyResponse{Results: results})
+
+	if err != nil {
+		return HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       constant.INTERNAL_SERVER_ERROR,
+		}, nil
+	}
+
+	return HttpResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(bytes),
+	}, nil
+}
+
+func (h *handler) presign(ctx context.Context, index int, item dto.AudioDTOInput) dto.BatchResultDTO {
+	result := dto.BatchResultDTO{Index: index, FileName: item.Filename}
+
+	validatonErr := item.Validate()
+
+	if validatonErr != nil {
+		result.Status = service.BATCH_INVALID
+		result.Errors = validatonErr
+		return result
+	}
+
+	id := ulid.New()
+
+	err := h.uploadService.CreateUpload(ctx, id, item.Filename)
+
+	var presigned dto.PresignedPutDTO
+
+	if err == nil {
+		presigned, err = h.service.GeneratePreSignedPutURL(id, item, dto.PresignOptions{Expires: h.expires}, ctx)
+
+		// The upload would otherwise stay pending until it expires, with no
+		// URL to ever reach it.
+		if err != nil && h.uploadService.DeleteUpload(ctx, id) != nil {
+			log.Printf("Unable to delete the upload %s, which will expire instead", id)
+		}
+	}
+
+	switch {
+	case err == nil:
+		result.Status = service.BATCH_CREATED
+		result.ID = id
+		result.Url = presigned.Url
+		result.Headers = presigned.Headers
+
+	case errors.Is(err, service.ConfilctErr):
+		result.Status = service.BATCH_CONFLICT
+		result.Message = err.Error()
+
+	default:
+		result.Status = service.BATCH_FAILED
+		result.Message = constant.INTERNAL_SERVER_ERROR
+	}
+
+	return result
+}
+
+func main() {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+
+	if err != nil {
+		log.Fatalf("An error occurred when tried to load AWS config. Error: %v", err)
+	}
+
+	expires, err := service.URLExpiry()
+
+	if err != nil {
+		log.Fatalf("An error occurred when tried to read URL_EXPIRES. Error: %v", err)
+	}
+
+	limit, err := service.BatchLimit()
+
+	if err != nil {
+		log.Fatalf("An error occurred when tried to read BATCH_LIMIT. Error: %v", err)
+	}
+
+	bucket := s3.NewFromConfig(cfg)
+	preSigned := s3.NewPresignClient(bucket)
+
+	dynamo := dynamodb.NewFromConfig(cfg)
+
+	s := service.NewAudioService(preSigned, bucket, dynamo)
+	u := service.NewUploadService(bucket, dynamo)
+	h := handler{service: s, uploadService: u, expires: expires, limit: limit}
+
+	lambda.Start(h.handleRequest)
+}
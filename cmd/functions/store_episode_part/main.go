@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/LucasAndFlores/go_lambdas_project/config"
+	"github.com/LucasAndFlores/go_lambdas_project/constant"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/service"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/ulid"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+type HttpRequest = events.APIGatewayProxyRequest
+
+type HttpResponse struct {
+	StatusCode int    `json:"statusCode"`
+	Body       string `json:"body"`
+}
+
+type handler struct {
+	service service.IAudioService
+}
+
+// handleRequest records a part the client finished uploading, with the ETag
+// S3 answered it with.
+func (h *handler) handleRequest(ctx context.Context, request HttpRequest) (HttpResponse, error) {
+	param := request.PathParameters["id"]
+
+	if param == "" {
+		return HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       constant.MISSING_PARAM_ERROR,
+		}, nil
+	}
+
+	if !ulid.Valid(param) {
+		return HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       constant.INVALID_ID_ERROR,
+		}, nil
+	}
+
+	number, err := strconv.ParseInt(request.PathParameters["number"], 10, 32)
+
+	if err != nil {
+		return HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       service.PartOutOfRangeErr.Error(),
+		}, nil
+	}
+
+	var parsedBody dto.PartDTOInput
+
+	err = json.Unmarshal([]byte(request.Body), &parsedBody)
+
+	if err != nil {
+		return HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       "Unable to process the body. Please, review the content",
+		}, nil
+	}
+
+	validatonErr := parsedBody.Validate()
+
+	if validatonErr != nil {
+		bytes, err := json.Marshal(map[string]interface{}{"errors": validatonErr})
+
+		if err != nil {
+			return HttpResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       constant.INTERNAL_SERVER_ERROR,
+			}, nil
+		}
+		return HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       string(bytes),
+		}, nil
+	}
+
+	err = h.service.RecordPart(ctx, param, int32(number), parsedBody.ETag)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, service.PartOutOfRangeErr):
+			return HttpResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       err.Error(),
+			}, nil
+
+		case errors.Is(err, service.MultipartNotFoundErr):
+			return HttpResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       err.Error(),
+			}, nil
+
+		case errors.Is(err, service.MultipartNotPendingErr):
+			return HttpResponse{
+				StatusCode: http.StatusConflict,
+				Body:       err.Error(),
+			}, nil
+
+		default:
+			return HttpResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       constant.INTERNAL_SERVER_ERROR,
+			}, nil
+		}
+	}
+
+	return HttpResponse{
+		StatusCode: http.StatusNoContent,
+	}, nil
+}
+
+func main() {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+
+	if err != nil {
+		log.Fatalf("An error occurred when tried to load AWS config. Error: %v", err)
+	}
+
+	bucket := s3.NewFromConfig(cfg)
+	preSigned := s3.NewPresignClient(bucket)
+
+	dynamo := dynamodb.NewFromConfig(cfg)
+
+	s := service.NewAudioService(preSigned, bucket, dynamo)
+	h := handler{service: s}
+
+	lambda.Start(h.handleRequest)
+}
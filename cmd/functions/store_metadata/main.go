@@ -25,7 +25,8 @@ type HttpResponse struct {
 }
 
 type handler struct {
-	service service.IMetadataService
+	service       service.IMetadataService
+	uploadService service.IUploadService
 }
 
 func (h *handler) handleRequest(ctx context.Context, request HttpRequest) (HttpResponse, error) {
@@ -40,6 +41,21 @@ func (h *handler) handleRequest(ctx context.Context, request HttpRequest) (HttpR
 		}, nil
 	}
 
+	// Fields left out fall back to the tags of the audio. When there are none,
+	// validation reports the fields as missing.
+	if parsedBody.ID != "" && parsedBody.MissingSuggestedFields() {
+		suggested, err := h.uploadService.SuggestMetadata(ctx, parsedBody.ID)
+
+		if err != nil && !errors.Is(err, service.UploadNotFoundErr) && !errors.Is(err, service.FileNotFoundErr) {
+			return HttpResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       constant.INTERNAL_SERVER_ERROR,
+			}, nil
+		}
+
+		parsedBody.ApplySuggestions(suggested)
+	}
+
 	validatonErr := parsedBody.Validate()
 
 	if validatonErr != nil {
@@ -60,7 +76,28 @@ func (h *handler) handleRequest(ctx context.Context, request HttpRequest) (HttpR
 	err = h.service.CreateItem(ctx, parsedBody)
 
 	if err != nil {
+		var duplicateErr *service.DuplicateAudioError
+
 		switch {
+		case errors.As(err, &duplicateErr):
+			bytes, err := json.Marshal(map[string]string{
+				"message": service.DuplicateAudioErr.Error(),
+				"id":      duplicateErr.ID,
+				"match":   duplicateErr.Match,
+			})
+
+			if err != nil {
+				return HttpResponse{
+					StatusCode: http.StatusInternalServerError,
+					Body:       constant.INTERNAL_SERVER_ERROR,
+				}, nil
+			}
+
+			return HttpResponse{
+				StatusCode: http.StatusConflict,
+				Body:       string(bytes),
+			}, nil
+
 		case errors.Is(err, service.ConfilctErr):
 			return HttpResponse{
 				StatusCode: http.StatusConflict,
@@ -100,7 +137,8 @@ func main() {
 	dynamo := dynamodb.NewFromConfig(cfg)
 
 	s := service.NewMetadataService(s3Client, dynamo)
-	h := handler{service: s}
+	u := service.NewUploadService(s3Client, dynamo)
+	h := handler{service: s, uploadService: u}
 
 	lambda.Start(h.handleRequest)
 }
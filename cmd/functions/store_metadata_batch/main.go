@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/LucasAndFlores/go_lambdas_project/config"
+	"github.com/LucasAndFlores/go_lambdas_project/constant"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/service"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+type HttpRequest = events.APIGatewayProxyRequest
+
+type HttpBodyResponse struct {
+	Results []dto.BatchResultDTO `json:"results"`
+}
+
+type HttpResponse struct {
+	StatusCode int    `json:"statusCode"`
+	Body       string `json:"body"`
+}
+
+type handler struct {
+	service       service.IMetadataService
+	uploadService service.IUploadService
+	limit         int
+}
+
+// handleRequest publishes many uploads at once, as POST /metadata does for
+// one. Items are handled on their own: an item that is refused or fails gets
+// its reason in its result and doesn't stop the others.
+func (h *handler) handleRequest(ctx context.Context, request HttpRequest) (HttpResponse, error) {
+	var parsedBody dto.MetadataBatchDTOInput
+
+	err := json.Unmarshal([]byte(request.Body), &parsedBody)
+
+	if err != nil {
+		return HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       "Unable to process the body. Please, review the content",
+		}, nil
+	}
+
+	if len(parsedBody.Items) == 0 || len(parsedBody.Items) > h.limit {
+		return HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       fmt.Sprintf("The batch should have between 1 and %d items", h.limit),
+		}, nil
+	}
+
+	// Fields left out fall back to the tags of the audio. When there are none,
+	// validation reports the fields as missing.
+	for index := range parsedBody.Items {
+		item := &parsedBody.Items[index]
+
+		if item.ID == "" || !item.MissingSuggestedFields() {
+			continue
+		}
+
+		suggested, err := h.uploadService.SuggestMetadata(ctx, item.ID)
+
+		if err != nil && !errors.Is(err, service.UploadNotFoundErr) && !errors.Is(err, service.FileNotFoundErr) {
+			return HttpResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       constant.INTERNAL_SERVER_ERROR,
+			}, nil
+		}
+
+		item.ApplySuggestions(suggested)
+	}
+
+	results, err := h.service.CreateItems(ctx, parsedBody.Items)
+
+	if err != nil {
+		return HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       constant.INTERNAL_SERVER_ERROR,
+		}, nil
+	}
+
+	for index := range results {
+		if results[index].Status == service.BATCH_FAILED {
+			results[index].Message = constant.INTERNAL_SERVER_ERROR
+		}
+	}
+
+	bytes, err := json.Marshal(HttpBodyResponse{Results: results})
+
+	if err != nil {
+		return HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       constant.INTERNAL_SERVER_ERROR,
+		}, nil
+	}
+
+	return HttpResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(bytes),
+	}, nil
+}
+
+func main() {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+
+	if err != nil {
+		log.Fatalf("An error occurred when tried to load AWS config. Error: %v", err)
+	}
+
+	limit, err := service.BatchLimit()
+
+	if err != nil {
+		log.Fatalf("An error occurred when tried to read BATCH_LIMIT. Error: %v", err)
+	}
+
+	s3Client := s3.NewFromConfig(cfg)
+
+	dynamo := dynamodb.NewFromConfig(cfg)
+
+	s := service.NewMetadataService(s3Client, dynamo)
+	u := service.NewUploadService(s3Client, dynamo)
+	h := handler{service: s, uploadService: u, limit: limit}
+
+	lambda.Start(h.handleRequest)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/LucasAndFlores/go_lambdas_project/config"
+	"github.com/LucasAndFlores/go_lambdas_project/constant"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/service"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+type HttpRequest = events.APIGatewayProxyRequest
+
+type HttpBodyResponse struct {
+	Suggestions []dto.SuggestionDTO `json:"suggestions"`
+}
+
+type HttpResponse struct {
+	StatusCode int    `json:"statusCode"`
+	Body       string `json:"body"`
+}
+
+type handler struct {
+	service service.ISuggestService
+}
+
+func (h *handler) handleRequest(ctx context.Context, request HttpRequest) (HttpResponse, error) {
+	input, err := parseSuggestInput(request.QueryStringParameters)
+
+	if err != nil {
+		return HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       constant.INVALID_PARAM_ERROR,
+		}, nil
+	}
+
+	if input.Prefix == "" {
+		return HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       constant.MISSING_PARAM_ERROR,
+		}, nil
+	}
+
+	suggestions, err := h.service.Suggest(ctx, input)
+
+	if err != nil {
+		if errors.Is(err, service.EmptyPrefixErr) {
+			return HttpResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       err.Error(),
+			}, nil
+		}
+
+		return HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       constant.INTERNAL_SERVER_ERROR,
+		}, nil
+	}
+
+	bytes, err := json.Marshal(HttpBodyResponse{Suggestions: suggestions})
+
+	if err != nil {
+		return HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       constant.INTERNAL_SERVER_ERROR,
+		}, nil
+	}
+
+	return HttpResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(bytes),
+	}, nil
+}
+
+func parseSuggestInput(params map[string]string) (dto.SuggestInput, error) {
+	input := dto.SuggestInput{
+		Prefix: params["prefix"],
+		Kind:   params["kind"],
+		Order:  params["order"],
+	}
+
+	if input.Kind != "" && input.Kind != service.KIND_AUTHOR && input.Kind != service.KIND_LABEL {
+		return dto.SuggestInput{}, errors.New("invalid kind")
+	}
+
+	if input.Order != "" && input.Order != service.ORDER_POPULARITY && input.Order != service.ORDER_ALPHABETICAL {
+		return dto.SuggestInput{}, errors.New("invalid order")
+	}
+
+	if limit := params["limit"]; limit != "" {
+		parsed, err := strconv.ParseInt(limit, 10, 32)
+
+		if err != nil || parsed < 1 || int32(parsed) > service.MAX_SUGGEST_LIMIT {
+			return dto.SuggestInput{}, errors.New("invalid limit")
+		}
+
+		input.Limit = int32(parsed)
+	}
+
+	return input, nil
+}
+
+func main() {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+
+	if err != nil {
+		log.Fatalf("An error occurred when tried to load AWS config. Error: %v", err)
+	}
+
+	dynamo := dynamodb.NewFromConfig(cfg)
+
+	s := service.NewSuggestService(dynamo)
+	h := handler{service: s}
+
+	lambda.Start(h.handleRequest)
+}
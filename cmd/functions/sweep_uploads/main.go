@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/LucasAndFlores/go_lambdas_project/config"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/service"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+type handler struct {
+	uploadService service.IUploadService
+}
+
+// handleRequest runs on a schedule and expires the uploads that were never
+// finished, removing whatever part of the audio reached S3.
+func (h *handler) handleRequest(ctx context.Context, event events.CloudWatchEvent) error {
+	expired, err := h.uploadService.ExpirePendingUploads(ctx, time.Now())
+
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Expired %d pending uploads", expired)
+
+	return nil
+}
+
+func main() {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+
+	if err != nil {
+		log.Fatalf("An error occurred when tried to load AWS config. Error: %v", err)
+	}
+
+	s3Client := s3.NewFromConfig(cfg)
+
+	dynamo := dynamodb.NewFromConfig(cfg)
+
+	u := service.NewUploadService(s3Client, dynamo)
+	h := handler{uploadService: u}
+
+	lambda.Start(h.handleRequest)
+}
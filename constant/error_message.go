@@ -3,4 +3,6 @@ package constant
 const (
     INTERNAL_SERVER_ERROR = "Internal server error"
     MISSING_PARAM_ERROR = "Missing required parameter"
+    INVALID_PARAM_ERROR = "Invalid query parameter"
+    INVALID_ID_ERROR = "The id is not valid"
 )
@@ -0,0 +1,166 @@
+package audio
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"math"
+
+	"github.com/LucasAndFlores/go_lambdas_project/internal/loudness"
+)
+
+// PEAK_BLOCK_FRAMES is how many frames are folded into one block while
+// decoding, so the whole signal never has to be kept in memory.
+const PEAK_BLOCK_FRAMES = 256
+
+var NoDecoderErr = errors.New("The audio format can't be decoded")
+
+// Analysis is what decoding the whole audio tells. Loudness is nil when the
+// audio is silent or shorter than the blocks loudness is measured on.
+// PCMHash is the hex SHA-256 of the samples reduced to 16 bits, so the same
+// audio hashes the same whatever its container, tags or sample encoding.
+type Analysis struct {
+	Peaks    []float64
+	Loudness *loudness.Result
+	PCMHash  string
+}
+
+// Decodable reports whether Analyze can decode audio of format.
+func Decodable(format Format) bool {
+	return format == FormatWAV || format == FormatMP3
+}
+
+// Analyze decodes the audio probed as info in a single pass. Peaks holds
+// resolution peaks, each the loudest sample of its slice of the audio, from
+// 0 for silence to 1 for full scale. Only WAV PCM and MP3 can be decoded;
+// other formats give NoDecoderErr.
+func Analyze(r io.ReaderAt, info Info, resolution int) (Analysis, error) {
+	var stream pcm
+	var err error
+
+	switch info.Format {
+	case FormatWAV:
+		stream, err = openWAV(r, info.Size)
+	case FormatMP3:
+		stream, err = openMP3(r, info.Size, info.Channels)
+	default:
+		return Analysis{}, NoDecoderErr
+	}
+
+	if err != nil {
+		return Analysis{}, err
+	}
+
+	var blocks peakBlocks
+
+	meter := loudness.NewMeter(stream.sampleRate, stream.channels)
+	hasher := newPCMHasher(stream.sampleRate, stream.channels)
+
+	err = stream.frames(func(frame []float64) {
+		amplitude := 0.0
+
+		for _, sample := range frame {
+			amplitude = max(amplitude, math.Abs(sample))
+		}
+
+		blocks.add(amplitude)
+		meter.Add(frame)
+		hasher.add(frame)
+	})
+
+	if err != nil {
+		return Analysis{}, err
+	}
+
+	analysis := Analysis{Peaks: blocks.peaks(resolution), PCMHash: hasher.sum()}
+
+	if result, ok := meter.Result(); ok {
+		analysis.Loudness = &result
+	}
+
+	return analysis, nil
+}
+
+type peakBlocks struct {
+	blocks  []float64
+	current float64
+	frames  int
+}
+
+func (p *peakBlocks) add(amplitude float64) {
+	p.current = max(p.current, min(amplitude, 1))
+	p.frames++
+
+	if p.frames == PEAK_BLOCK_FRAMES {
+		p.blocks = append(p.blocks, p.current)
+		p.current, p.frames = 0, 0
+	}
+}
+
+// peaks folds the blocks into n peaks. When the audio is too short to fill
+// them, blocks are repeated.
+func (p *peakBlocks) peaks(n int) []float64 {
+	if p.frames > 0 {
+		p.blocks = append(p.blocks, p.current)
+		p.current, p.frames = 0, 0
+	}
+
+	peaks := make([]float64, n)
+
+	if len(p.blocks) == 0 {
+		return peaks
+	}
+
+	for i := range peaks {
+		start := i * len(p.blocks) / n
+		end := max((i+1)*len(p.blocks)/n, start+1)
+
+		for _, block := range p.blocks[start:end] {
+			peaks[i] = max(peaks[i], block)
+		}
+
+		// Three decimals are more than a screen can show.
+		peaks[i] = math.Round(peaks[i]*1000) / 1000
+	}
+
+	return peaks
+}
+
+// pcmHasher hashes the frames as 16 bits little endian samples, after the
+// sample rate and channels, since the same samples played at another rate
+// are another audio.
+type pcmHasher struct {
+	hash hash.Hash
+	buf  []byte
+}
+
+func newPCMHasher(sampleRate int, channels int) *pcmHasher {
+	h := &pcmHasher{hash: sha256.New(), buf: make([]byte, 0, DECODE_BUFFER_SIZE)}
+
+	h.buf = binary.LittleEndian.AppendUint32(h.buf, uint32(sampleRate))
+	h.buf = binary.LittleEndian.AppendUint16(h.buf, uint16(channels))
+
+	return h
+}
+
+func (h *pcmHasher) add(frame []float64) {
+	for _, sample := range frame {
+		quantized := int16(min(max(math.Round(sample*(1<<15)), math.MinInt16), math.MaxInt16))
+		h.buf = binary.LittleEndian.AppendUint16(h.buf, uint16(quantized))
+	}
+
+	if len(h.buf) >= DECODE_BUFFER_SIZE {
+		h.hash.Write(h.buf)
+		h.buf = h.buf[:0]
+	}
+}
+
+func (h *pcmHasher) sum() string {
+	h.hash.Write(h.buf)
+	h.buf = h.buf[:0]
+
+	return hex.EncodeToString(h.hash.Sum(nil))
+}
@@ -0,0 +1,160 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/LucasAndFlores/go_lambdas_project/internal/loudness"
+)
+
+type Format string
+
+const (
+	FormatMP3 Format = "mp3"
+	FormatOGG Format = "ogg"
+	FormatWAV Format = "wav"
+	FormatM4A Format = "m4a"
+)
+
+// SNIFF_SIZE is how many bytes of the object Sniff needs to see.
+const SNIFF_SIZE = 12
+
+var UnknownFormatErr = errors.New("The object is not a supported audio format")
+var MalformedErr = errors.New("The audio is malformed")
+var OutOfRangeErr = errors.New("The time range is outside of the audio")
+
+// Info holds the technical properties of an audio file. Bitrate is in bits
+// per second and, for VBR files, is the average over the whole file.
+// Loudness and the hashes aren't found by Probe: they take reading, and for
+// Loudness and PCMHash decoding, the whole audio.
+type Info struct {
+	Format     Format
+	Duration   time.Duration
+	Bitrate    int
+	SampleRate int
+	Channels   int
+	Size       int64
+	Tags       Tags
+	Loudness   *loudness.Result
+	SHA256     string
+	PCMHash    string
+}
+
+// Sniff tells the format from the first bytes of a file, ignoring whatever
+// extension or content type the client claimed. It returns "" when the
+// header matches none of the supported formats.
+func Sniff(header []byte) Format {
+	switch {
+	case len(header) >= 12 && bytes.Equal(header[:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WAVE")):
+		return FormatWAV
+	case bytes.HasPrefix(header, []byte("OggS")):
+		return FormatOGG
+	case len(header) >= 8 && bytes.Equal(header[4:8], []byte("ftyp")):
+		return FormatM4A
+	case bytes.HasPrefix(header, []byte("ID3")):
+		return FormatMP3
+	case len(header) >= 4 && isFrameHeader(header):
+		return FormatMP3
+	}
+
+	return ""
+}
+
+// Probe sniffs the format of the size bytes behind r and parses its headers
+// far enough to know the duration, sample rate and channels, along with the
+// ID3 or MP4 tags it carries. Unsupported and broken files are reported with
+// UnknownFormatErr and MalformedErr; any other error comes from r.
+func Probe(r io.ReaderAt, size int64) (Info, error) {
+	header, err := readAt(r, 0, int(min(size, SNIFF_SIZE)))
+
+	if err != nil {
+		return Info{}, err
+	}
+
+	format := Sniff(header)
+
+	var info Info
+
+	switch format {
+	case FormatMP3:
+		info, err = probeMP3(r, size)
+	case FormatOGG:
+		info, err = probeOGG(r, size)
+	case FormatWAV:
+		info, err = probeWAV(r, size)
+	case FormatM4A:
+		info, err = probeM4A(r, size)
+	default:
+		return Info{}, UnknownFormatErr
+	}
+
+	if err != nil {
+		return Info{}, err
+	}
+
+	if format == FormatMP3 {
+		info.Tags, err = readID3(r, size)
+
+		if err != nil {
+			return Info{}, err
+		}
+	}
+
+	info.Format = format
+	info.Size = size
+
+	if info.Bitrate == 0 {
+		info.Bitrate = averageBitrate(size, info.Duration)
+	}
+
+	return info, nil
+}
+
+// readAt reads exactly n bytes at off. Running out of file means the headers
+// promised more than there is, so it is reported as MalformedErr.
+func readAt(r io.ReaderAt, off int64, n int) ([]byte, error) {
+	buf := make([]byte, n)
+
+	read, err := r.ReadAt(buf, off)
+
+	if read == n {
+		return buf, nil
+	}
+
+	if err == nil || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("%w: unexpected end of file at %d", MalformedErr, off+int64(read))
+	}
+
+	return nil, err
+}
+
+func malformed(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", MalformedErr, fmt.Sprintf(format, args...))
+}
+
+func averageBitrate(size int64, duration time.Duration) int {
+	if duration <= 0 {
+		return 0
+	}
+
+	return int(float64(size*8) / duration.Seconds())
+}
+
+// seconds converts units counted at rate per second, such as samples or
+// bytes. Both come straight from the headers, so the whole seconds are
+// counted apart from the rest, and a duration that doesn't fit is malformed.
+func seconds(units int64, rate int64) (time.Duration, error) {
+	if rate <= 0 {
+		return 0, nil
+	}
+
+	if units < 0 || units/rate >= int64(math.MaxInt64/time.Second) {
+		return 0, malformed("duration of %d units at %d per second", units, rate)
+	}
+
+	return time.Duration(units/rate)*time.Second + time.Duration(units%rate)*time.Second/time.Duration(rate), nil
+}
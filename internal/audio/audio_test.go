@@ -0,0 +1,440 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func wavFile(seconds int) []byte {
+	var buf bytes.Buffer
+
+	byteRate := 8000 * 2
+	dataSize := byteRate * seconds
+
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+dataSize))
+	buf.WriteString("WAVEfmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))
+	binary.Write(&buf, binary.LittleEndian, []uint16{1, 1})
+	binary.Write(&buf, binary.LittleEndian, []uint32{8000, uint32(byteRate)})
+	binary.Write(&buf, binary.LittleEndian, []uint16{2, 16})
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(dataSize))
+	buf.Write(make([]byte, dataSize))
+
+	return buf.Bytes()
+}
+
+// wavChannels is a WAV claiming that many channels.
+func wavChannels(channels uint16) []byte {
+	file := wavFile(1)
+	binary.LittleEndian.PutUint16(file[22:], channels)
+
+	return file
+}
+
+// mp3File has frames of MPEG 1 Layer III, 128 kbit/s, 44.1 kHz, stereo,
+// 417 bytes each, after an ID3v2 tag.
+func mp3File(frames int) []byte {
+	var buf bytes.Buffer
+
+	buf.Write([]byte{'I', 'D', '3', 4, 0, 0, 0, 0, 0, 20})
+	buf.Write(make([]byte, 20))
+
+	for i := 0; i < frames; i++ {
+		frame := make([]byte, 417)
+		copy(frame, []byte{0xFF, 0xFB, 0x90, 0x00})
+		buf.Write(frame)
+	}
+
+	return buf.Bytes()
+}
+
+func oggPage(serial uint32, granule int64, packet []byte) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString("OggS")
+	buf.Write([]byte{0, 0})
+	binary.Write(&buf, binary.LittleEndian, granule)
+	binary.Write(&buf, binary.LittleEndian, []uint32{serial, 0, 0})
+	buf.Write([]byte{1, byte(len(packet))})
+	buf.Write(packet)
+
+	return buf.Bytes()
+}
+
+func opusFile(seconds int) []byte {
+	file := opusGranule(int64(seconds*OPUS_SAMPLE_RATE + 312))
+
+	return append(file, oggPage(9, 999999999, make([]byte, 10))...)
+}
+
+// opusGranule is an Opus file whose last page claims granule samples.
+func opusGranule(granule int64) []byte {
+	head := []byte("OpusHead\x01\x02")
+	head = binary.LittleEndian.AppendUint16(head, 312)
+	head = binary.LittleEndian.AppendUint32(head, 48000)
+	head = append(head, 0, 0, 0)
+
+	return append(oggPage(7, 0, head), oggPage(7, granule, make([]byte, 100))...)
+}
+
+func box(kind string, children ...[]byte) []byte {
+	body := bytes.Join(children, nil)
+
+	return append(binary.BigEndian.AppendUint32(nil, uint32(8+len(body))), append([]byte(kind), body...)...)
+}
+
+func m4aFile(seconds int, handler string) []byte {
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[12:], 1000)
+	binary.BigEndian.PutUint32(mvhd[16:], uint32(seconds*1000))
+
+	hdlr := make([]byte, 24)
+	copy(hdlr[8:], handler)
+
+	mp4a := make([]byte, 28)
+	binary.BigEndian.PutUint16(mp4a[16:], 2)
+	binary.BigEndian.PutUint32(mp4a[24:], 44100<<16)
+
+	stsd := append([]byte{0, 0, 0, 0, 0, 0, 0, 1}, box("mp4a", mp4a)...)
+	minf := box("minf", box("stbl", box("stsd", stsd)))
+
+	return bytes.Join([][]byte{
+		box("ftyp", []byte("M4A \x00\x00\x00\x00")),
+		box("mdat", make([]byte, 64)),
+		box("moov", box("mvhd", mvhd), box("trak", box("mdia", box("hdlr", hdlr), minf))),
+	}, nil)
+}
+
+func TestProbe(t *testing.T) {
+	cases := map[string]struct {
+		file     []byte
+		expected Info
+	}{
+		"wav":  {wavFile(3), Info{Format: FormatWAV, Duration: 3 * time.Second, Bitrate: 128000, SampleRate: 8000, Channels: 1}},
+		"mp3":  {mp3File(100), Info{Format: FormatMP3, Duration: 41700 * 8 * time.Second / 128000, Bitrate: 128000, SampleRate: 44100, Channels: 2}},
+		"opus": {opusFile(4), Info{Format: FormatOGG, Duration: 4 * time.Second, SampleRate: 48000, Channels: 2}},
+		"m4a":  {m4aFile(5, "soun"), Info{Format: FormatM4A, Duration: 5 * time.Second, SampleRate: 44100, Channels: 2}},
+	}
+
+	for name, c := range cases {
+		result, err := Probe(bytes.NewReader(c.file), int64(len(c.file)))
+
+		if err != nil {
+			t.Errorf("%s: The result is different from expected. Expected nil. Result: %v", name, err)
+			continue
+		}
+
+		c.expected.Size = int64(len(c.file))
+
+		if c.expected.Bitrate == 0 {
+			c.expected.Bitrate = averageBitrate(c.expected.Size, c.expected.Duration)
+		}
+
+		if result != c.expected {
+			t.Errorf("%s: The result is different from expected. Result: %v. Expected: %v", name, result, c.expected)
+		}
+	}
+}
+
+func TestProbeXingHeader(t *testing.T) {
+	file := mp3File(10)
+
+	// The Xing header of a stereo MPEG 1 frame sits 36 bytes after its start.
+	xing := 30 + 36
+	copy(file[xing:], "Xing")
+	binary.BigEndian.PutUint32(file[xing+4:], 1)
+	binary.BigEndian.PutUint32(file[xing+8:], 1000)
+
+	result, err := Probe(bytes.NewReader(file), int64(len(file)))
+
+	expected := 1000 * 1152 * time.Second / 44100
+
+	if err != nil || result.Duration != expected {
+		t.Errorf("The result is different from expected. Result: %v %v. Expected: %v", result.Duration, err, expected)
+	}
+}
+
+func TestProbeLongDurationsDontWrap(t *testing.T) {
+	file := mp3File(10)
+
+	xing := 30 + 36
+	copy(file[xing:], "Xing")
+	binary.BigEndian.PutUint32(file[xing+4:], 1)
+	binary.BigEndian.PutUint32(file[xing+8:], 0xFFFFFFFF)
+
+	result, err := Probe(bytes.NewReader(file), int64(len(file)))
+
+	// Over three years, which overflowed when counted in nanoseconds before
+	// dividing.
+	expected := time.Duration(float64(0xFFFFFFFF) * 1152 / 44100 * float64(time.Second))
+
+	if err != nil || (result.Duration-expected).Abs() > time.Second {
+		t.Errorf("The result is different from expected. Result: %v %v. Expected: %v", result.Duration, err, expected)
+	}
+}
+
+func TestProbeRejectsOtherFiles(t *testing.T) {
+	cases := map[string]struct {
+		file     []byte
+		expected error
+	}{
+		"text":      {[]byte("this is not audio at all"), UnknownFormatErr},
+		"video":     {m4aFile(5, "vide"), UnknownFormatErr},
+		"truncated": {wavFile(1)[:30], MalformedErr},
+		"fake mp3":  {append([]byte{0xFF, 0xFB, 0x90, 0x00}, bytes.Repeat([]byte("x"), 2000)...), MalformedErr},
+		"channels":  {wavChannels(20000), MalformedErr},
+		"granule":   {opusGranule(0x7000000000000000), MalformedErr},
+	}
+
+	for name, c := range cases {
+		_, err := Probe(bytes.NewReader(c.file), int64(len(c.file)))
+
+		if !errors.Is(err, c.expected) {
+			t.Errorf("%s: The result is different from expected. Result: %v. Expected: %v", name, err, c.expected)
+		}
+	}
+}
+
+func id3Frame(id string, body []byte) []byte {
+	return append(binary.BigEndian.AppendUint32([]byte(id), uint32(len(body))), append([]byte{0, 0}, body...)...)
+}
+
+// taggedMP3 puts an ID3v2.3 tag holding frames and 10 bytes of padding in
+// front of the frames of mp3File.
+func taggedMP3(frames ...[]byte) []byte {
+	tag := append(bytes.Join(frames, nil), make([]byte, 10)...)
+
+	header := []byte{'I', 'D', '3', 3, 0, 0, 0, 0, byte(len(tag) >> 7), byte(len(tag) & 0x7F)}
+
+	return bytes.Join([][]byte{header, tag, mp3File(10)[30:]}, nil)
+}
+
+func ilstItem(kind string, value string) []byte {
+	return box(kind, box("data", append([]byte{0, 0, 0, 1, 0, 0, 0, 0}, value...)))
+}
+
+func TestProbeTags(t *testing.T) {
+	utf16Artist := []byte{1, 0xFF, 0xFE, 'J', 0, 0xF3, 0, 'a', 0, 'o', 0}
+
+	id3v1 := make([]byte, 128)
+	copy(id3v1, "TAG")
+	copy(id3v1[3:], "Old title")
+	copy(id3v1[33:], "Old artist")
+	copy(id3v1[97:], "Old comment")
+
+	m4a := m4aFile(5, "soun")
+	moov := bytes.Index(m4a, []byte("moov")) - 4
+	udta := box("udta", box("meta", []byte{0, 0, 0, 0}, box("hdlr", make([]byte, 24)), box("ilst",
+		ilstItem("\xa9nam", "Morning news"),
+		ilstItem("aART", "Album artist"),
+		ilstItem("\xa9cmt", "Recorded live"),
+	)))
+
+	cases := map[string]struct {
+		file     []byte
+		expected Tags
+	}{
+		"id3v2": {
+			taggedMP3(
+				id3Frame("TIT2", []byte("\x00Morning news")),
+				id3Frame("TPE1", utf16Artist),
+				id3Frame("COMM", []byte("\x00engiTunNORM\x00 000001")),
+				id3Frame("COMM", []byte("\x03eng\x00Recorded live")),
+			),
+			Tags{Title: "Morning news", Artist: "Jóao", Comment: "Recorded live"},
+		},
+		"id3v1": {
+			append(taggedMP3(id3Frame("TIT2", []byte("\x03Morning news"))), id3v1...),
+			Tags{Title: "Morning news", Artist: "Old artist", Comment: "Old comment"},
+		},
+		"ilst": {
+			append(m4a[:moov:moov], box("moov", m4a[moov+8:], udta)...),
+			Tags{Title: "Morning news", Artist: "Album artist", Comment: "Recorded live"},
+		},
+	}
+
+	for name, c := range cases {
+		result, err := Probe(bytes.NewReader(c.file), int64(len(c.file)))
+
+		if err != nil || result.Tags != c.expected {
+			t.Errorf("%s: The result is different from expected. Result: %v %v. Expected: %v", name, result.Tags, err, c.expected)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	wav := wavFile(2)
+
+	// The second second is at half of full scale, on both signs.
+	for i := 44 + 16000; i+4 <= len(wav); i += 4 {
+		binary.LittleEndian.PutUint16(wav[i:], 1<<14)
+		binary.LittleEndian.PutUint16(wav[i+2:], uint16(0x10000-1<<14))
+	}
+
+	cases := map[string]struct {
+		file     []byte
+		info     Info
+		peaks    []float64
+		loudness bool
+	}{
+		"wav":   {wav, Info{Format: FormatWAV, Channels: 1}, []float64{0, 0, 0.5, 0.5}, true},
+		"mp3":   {mp3File(20), Info{Format: FormatMP3, Channels: 2}, []float64{0, 0, 0, 0}, false},
+		"short": {wavFile(0), Info{Format: FormatWAV, Channels: 1}, []float64{0, 0, 0, 0}, false},
+	}
+
+	for name, c := range cases {
+		c.info.Size = int64(len(c.file))
+
+		result, err := Analyze(bytes.NewReader(c.file), c.info, 4)
+
+		if err != nil || fmt.Sprint(result.Peaks) != fmt.Sprint(c.peaks) || (result.Loudness != nil) != c.loudness {
+			t.Errorf("%s: The result is different from expected. Result: %v %v %v. Expected: %v %v", name, result.Peaks, result.Loudness, err, c.peaks, c.loudness)
+		}
+	}
+}
+
+func TestAnalyzeWithoutDecoder(t *testing.T) {
+	file := opusFile(1)
+
+	_, err := Analyze(bytes.NewReader(file), Info{Format: FormatOGG, Size: int64(len(file))}, 4)
+
+	if !errors.Is(err, NoDecoderErr) {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", err, NoDecoderErr)
+	}
+}
+
+func TestAnalyzeRefusesImplausibleWAV(t *testing.T) {
+	file := wavChannels(20000)
+
+	_, err := Analyze(bytes.NewReader(file), Info{Format: FormatWAV, Size: int64(len(file))}, 4)
+
+	if !errors.Is(err, MalformedErr) {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", err, MalformedErr)
+	}
+}
+
+func TestAnalyzeFloatSamplesThatArentNumbers(t *testing.T) {
+	var buf bytes.Buffer
+
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+16))
+	buf.WriteString("WAVEfmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))
+	binary.Write(&buf, binary.LittleEndian, []uint16{3, 1})
+	binary.Write(&buf, binary.LittleEndian, []uint32{8000, 32000})
+	binary.Write(&buf, binary.LittleEndian, []uint16{4, 32})
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))
+	binary.Write(&buf, binary.LittleEndian, []float32{float32(math.NaN()), 0.25, float32(math.NaN()), float32(math.NaN())})
+
+	file := buf.Bytes()
+
+	result, err := Analyze(bytes.NewReader(file), Info{Format: FormatWAV, Size: int64(len(file))}, 4)
+
+	if err != nil || fmt.Sprint(result.Peaks) != "[0.25 0.25 0.25 0.25]" {
+		t.Errorf("The result is different from expected. Result: %v %v. Expected: %v", result.Peaks, err, "[0.25 0.25 0.25 0.25]")
+	}
+
+	_, err = json.Marshal(result.Peaks)
+
+	if err != nil {
+		t.Errorf("The result is different from expected. Expected nil. Result: %v", err)
+	}
+}
+
+func TestAnalyzePCMHash(t *testing.T) {
+	pcm16 := wavFile(1)
+
+	for i := 44; i < len(pcm16); i += 2 {
+		binary.LittleEndian.PutUint16(pcm16[i:], uint16(i*37))
+	}
+
+	// The same samples as 32 bits float, after a LIST chunk.
+	var buf bytes.Buffer
+
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(4+24+12+8+32000))
+	buf.WriteString("WAVEfmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))
+	binary.Write(&buf, binary.LittleEndian, []uint16{3, 1})
+	binary.Write(&buf, binary.LittleEndian, []uint32{8000, 32000})
+	binary.Write(&buf, binary.LittleEndian, []uint16{4, 32})
+	buf.WriteString("LIST\x04\x00\x00\x00INFO")
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(32000))
+
+	for i := 44; i < len(pcm16); i += 2 {
+		binary.Write(&buf, binary.LittleEndian, float32(int16(binary.LittleEndian.Uint16(pcm16[i:])))/(1<<15))
+	}
+
+	float := buf.Bytes()
+
+	changed := bytes.Clone(pcm16)
+	changed[100]++
+
+	hash := func(file []byte) string {
+		result, err := Analyze(bytes.NewReader(file), Info{Format: FormatWAV, Size: int64(len(file))}, 4)
+
+		if err != nil {
+			t.Fatalf("The result is different from expected. Expected nil. Result: %v", err)
+		}
+
+		return result.PCMHash
+	}
+
+	if hash(pcm16) != hash(float) {
+		t.Errorf("The same samples should hash the same. Result: %v %v", hash(pcm16), hash(float))
+	}
+
+	if hash(pcm16) == hash(changed) {
+		t.Errorf("Other samples should hash differently. Result: %v", hash(changed))
+	}
+}
+
+func TestSliceWAV(t *testing.T) {
+	file := wavFile(3)
+
+	// 1.00001s falls inside the 8000th frame, which starts the slice.
+	slice, err := SliceWAV(bytes.NewReader(file), int64(len(file)), time.Second+10*time.Microsecond, 5*time.Second)
+
+	if err != nil || slice.Offset != 44+16000 || slice.Length != 32000 {
+		t.Fatalf("The result is different from expected. Result: %v %v %v. Expected: %v %v", slice.Offset, slice.Length, err, 44+16000, 32000)
+	}
+
+	clip := append(slice.Header, file[slice.Offset:slice.Offset+slice.Length]...)
+
+	info, err := Probe(bytes.NewReader(clip), int64(len(clip)))
+
+	if err != nil || info.Duration != 2*time.Second {
+		t.Errorf("The result is different from expected. Result: %v %v. Expected: %v", info.Duration, err, 2*time.Second)
+	}
+
+	_, err = SliceWAV(bytes.NewReader(file), int64(len(file)), 4*time.Second, 5*time.Second)
+
+	if !errors.Is(err, OutOfRangeErr) {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", err, OutOfRangeErr)
+	}
+
+	// 900 hours in nanoseconds times the sample rate overflows an int64.
+	slice, err = SliceWAV(bytes.NewReader(file), int64(len(file)), 2*time.Second, 900*time.Hour)
+
+	if err != nil || slice.Offset != 44+32000 || slice.Length != 16000 {
+		t.Errorf("The result is different from expected. Result: %v %v %v. Expected: %v %v", slice.Offset, slice.Length, err, 44+32000, 16000)
+	}
+
+	mp3 := mp3File(10)
+
+	_, err = SliceWAV(bytes.NewReader(mp3), int64(len(mp3)), 0, time.Second)
+
+	if !errors.Is(err, UnknownFormatErr) {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", err, UnknownFormatErr)
+	}
+}
@@ -0,0 +1,166 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/hajimehoshi/go-mp3"
+)
+
+// DECODE_BUFFER_SIZE is how many bytes of PCM are handled at a time.
+const DECODE_BUFFER_SIZE = 64 << 10
+
+// pcm is a decoded audio, read as frames of one sample per channel scaled
+// to [-1, 1].
+type pcm struct {
+	sampleRate int
+	channels   int
+	frames     func(add func(frame []float64)) error
+}
+
+// openWAV reads integer PCM of 8 to 32 bits and 32 bits float samples, the
+// encodings of nearly every WAV file.
+func openWAV(r io.ReaderAt, size int64) (pcm, error) {
+	layout, err := readWAVLayout(r, size)
+
+	if err != nil {
+		return pcm{}, err
+	}
+
+	encoding := binary.LittleEndian.Uint16(layout.format)
+	channels := int(binary.LittleEndian.Uint16(layout.format[2:]))
+	sampleRate := int(binary.LittleEndian.Uint32(layout.format[4:]))
+	bits := int(binary.LittleEndian.Uint16(layout.format[14:]))
+
+	// WAVE_FORMAT_EXTENSIBLE keeps the real encoding in its sub format.
+	if encoding == 0xFFFE && len(layout.format) >= 26 {
+		encoding = binary.LittleEndian.Uint16(layout.format[24:])
+	}
+
+	sample, err := pcmSample(encoding, bits)
+
+	if err != nil {
+		return pcm{}, err
+	}
+
+	if sampleRate == 0 {
+		return pcm{}, malformed("fmt chunk with no sample rate")
+	}
+
+	frames := func(add func(frame []float64)) error {
+		sampleSize := bits / 8
+		frameSize := channels * sampleSize
+		data := io.NewSectionReader(r, layout.dataOffset, layout.dataLength)
+		buf := make([]byte, DECODE_BUFFER_SIZE/frameSize*frameSize)
+		frame := make([]float64, channels)
+
+		for {
+			read, err := io.ReadFull(data, buf)
+
+			if read == 0 && err == nil {
+				return nil
+			}
+
+			for offset := 0; offset+frameSize <= read; offset += frameSize {
+				for i := range frame {
+					frame[i] = sample(buf[offset+i*sampleSize:])
+				}
+
+				add(frame)
+			}
+
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return pcm{sampleRate: sampleRate, channels: channels, frames: frames}, nil
+}
+
+// pcmSample returns how to read one sample, scaled to [-1, 1].
+func pcmSample(encoding uint16, bits int) (func([]byte) float64, error) {
+	switch {
+	case encoding == 1 && bits == 8:
+		// 8 bits samples are the only unsigned ones.
+		return func(b []byte) float64 { return (float64(b[0]) - 128) / 128 }, nil
+
+	case encoding == 1 && bits == 16:
+		return func(b []byte) float64 { return float64(int16(binary.LittleEndian.Uint16(b))) / (1 << 15) }, nil
+
+	case encoding == 1 && bits == 24:
+		return func(b []byte) float64 {
+			return float64(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24)>>8) / (1 << 23)
+		}, nil
+
+	case encoding == 1 && bits == 32:
+		return func(b []byte) float64 { return float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31) }, nil
+
+	case encoding == 3 && bits == 32:
+		return func(b []byte) float64 { return finite(float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))) }, nil
+	}
+
+	return nil, fmt.Errorf("%w: WAV encoding %d of %d bits", NoDecoderErr, encoding, bits)
+}
+
+// finite keeps float samples that aren't numbers from reaching the peaks,
+// the loudness and the PCM hash: NaN is silence and infinities full scale.
+func finite(sample float64) float64 {
+	switch {
+	case math.IsNaN(sample):
+		return 0
+	case math.IsInf(sample, 0):
+		return math.Copysign(1, sample)
+	}
+
+	return sample
+}
+
+// openMP3 always gets 16 bits stereo from the decoder, even for mono files.
+// Mono files keep their left channel alone, or they would measure 3 dB
+// louder than they are.
+func openMP3(r io.ReaderAt, size int64, channels int) (pcm, error) {
+	// Hiding Seek keeps the decoder from reading the whole file up front to
+	// learn its length.
+	decoder, err := mp3.NewDecoder(io.MultiReader(io.NewSectionReader(r, 0, size)))
+
+	if err != nil {
+		return pcm{}, malformed("%v", err)
+	}
+
+	channels = min(max(channels, 1), 2)
+
+	frames := func(add func(frame []float64)) error {
+		buf := make([]byte, DECODE_BUFFER_SIZE)
+		frame := make([]float64, channels)
+
+		for {
+			read, err := io.ReadFull(decoder, buf)
+
+			for offset := 0; offset+4 <= read; offset += 4 {
+				for i := range frame {
+					frame[i] = float64(int16(binary.LittleEndian.Uint16(buf[offset+2*i:]))) / (1 << 15)
+				}
+
+				add(frame)
+			}
+
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil
+			}
+
+			if err != nil {
+				return malformed("%v", err)
+			}
+		}
+	}
+
+	return pcm{sampleRate: decoder.SampleRate(), channels: channels, frames: frames}, nil
+}
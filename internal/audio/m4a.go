@@ -0,0 +1,212 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// MAX_MOOV_SIZE bounds the moov atom, which is read whole. Short clips have
+// a moov of a few kilobytes.
+const MAX_MOOV_SIZE = 8 << 20
+
+type atom struct {
+	kind   string
+	offset int64
+	header int64
+	size   int64
+}
+
+func (a atom) body() (int64, int64) {
+	return a.offset + a.header, a.size - a.header
+}
+
+// probeM4A finds the moov atom among the top level atoms, wherever the
+// encoder put it, takes the duration from mvhd and checks that the file
+// holds a sound track and no video track.
+func probeM4A(r io.ReaderAt, size int64) (Info, error) {
+	var moov *atom
+
+	for offset := int64(0); offset+8 <= size; {
+		current, err := readAtom(r, offset, size)
+
+		if err != nil {
+			return Info{}, err
+		}
+
+		if offset == 0 && current.kind != "ftyp" {
+			return Info{}, UnknownFormatErr
+		}
+
+		if current.kind == "moov" {
+			moov = &current
+			break
+		}
+
+		offset += current.size
+	}
+
+	if moov == nil {
+		return Info{}, malformed("no moov atom")
+	}
+
+	if moov.size > MAX_MOOV_SIZE {
+		return Info{}, malformed("moov atom of %d bytes", moov.size)
+	}
+
+	start, length := moov.body()
+
+	data, err := readAt(r, start, int(length))
+
+	if err != nil {
+		return Info{}, err
+	}
+
+	return parseMoov(data)
+}
+
+func readAtom(r io.ReaderAt, offset int64, size int64) (atom, error) {
+	header, err := readAt(r, offset, 8)
+
+	if err != nil {
+		return atom{}, err
+	}
+
+	current := atom{
+		kind:   string(header[4:8]),
+		offset: offset,
+		header: 8,
+		size:   int64(binary.BigEndian.Uint32(header)),
+	}
+
+	switch current.size {
+	case 0:
+		current.size = size - offset
+	case 1:
+		large, err := readAt(r, offset+8, 8)
+
+		if err != nil {
+			return atom{}, err
+		}
+
+		current.header = 16
+		current.size = int64(binary.BigEndian.Uint64(large))
+	}
+
+	if current.size < current.header || current.size > size-offset {
+		return atom{}, malformed("%s atom of %d bytes at %d", current.kind, current.size, offset)
+	}
+
+	return current, nil
+}
+
+func parseMoov(moov []byte) (Info, error) {
+	mvhd := findAtom(moov, "mvhd")
+
+	if len(mvhd) < 20 {
+		return Info{}, malformed("no mvhd atom")
+	}
+
+	var timescale, units int64
+
+	if mvhd[0] == 1 {
+		if len(mvhd) < 32 {
+			return Info{}, malformed("mvhd atom of %d bytes", len(mvhd))
+		}
+
+		timescale = int64(binary.BigEndian.Uint32(mvhd[20:]))
+		units = int64(binary.BigEndian.Uint64(mvhd[24:]))
+	} else {
+		timescale = int64(binary.BigEndian.Uint32(mvhd[12:]))
+		units = int64(binary.BigEndian.Uint32(mvhd[16:]))
+	}
+
+	if timescale == 0 {
+		return Info{}, malformed("mvhd atom with no timescale")
+	}
+
+	duration, err := seconds(units, timescale)
+
+	if err != nil {
+		return Info{}, err
+	}
+
+	info := Info{Duration: duration, Tags: parseILST(moov)}
+	sound := false
+
+	for _, trak := range findAtoms(moov, "trak") {
+		mdia := findAtom(trak, "mdia")
+		hdlr := findAtom(mdia, "hdlr")
+
+		if len(hdlr) < 12 {
+			continue
+		}
+
+		switch string(hdlr[8:12]) {
+		case "soun":
+			if !sound {
+				readSampleEntry(findAtom(findAtom(findAtom(mdia, "minf"), "stbl"), "stsd"), &info)
+			}
+
+			sound = true
+		case "vide":
+			return Info{}, UnknownFormatErr
+		}
+	}
+
+	if !sound {
+		return Info{}, UnknownFormatErr
+	}
+
+	return info, nil
+}
+
+// readSampleEntry reads the channels and sample rate of the first sample
+// entry in stsd, such as mp4a. Both sit at the same place in every audio
+// sample entry.
+func readSampleEntry(stsd []byte, info *Info) {
+	// version and flags, entry count, then the entry: size, format, 6
+	// reserved bytes, data reference index and 8 reserved bytes.
+	const entry = 8
+
+	if len(stsd) < entry+36 {
+		return
+	}
+
+	info.Channels = int(binary.BigEndian.Uint16(stsd[entry+24:]))
+
+	// The sample rate is a 16.16 fixed point number.
+	info.SampleRate = int(binary.BigEndian.Uint32(stsd[entry+32:]) >> 16)
+}
+
+// findAtoms returns the bodies of the children of kind in data, which holds
+// the body of their parent.
+func findAtoms(data []byte, kind string) [][]byte {
+	var found [][]byte
+
+	for offset := 0; offset+8 <= len(data); {
+		size := int(binary.BigEndian.Uint32(data[offset:]))
+
+		if size < 8 || offset+size > len(data) {
+			break
+		}
+
+		if bytes.Equal(data[offset+4:offset+8], []byte(kind)) {
+			found = append(found, data[offset+8:offset+size])
+		}
+
+		offset += size
+	}
+
+	return found
+}
+
+func findAtom(data []byte, kind string) []byte {
+	found := findAtoms(data, kind)
+
+	if len(found) == 0 {
+		return nil
+	}
+
+	return found[0]
+}
@@ -0,0 +1,221 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// MP3_SYNC_WINDOW bounds how far after the ID3 tag the first frame is looked
+// for, so random data isn't scanned to the end.
+const MP3_SYNC_WINDOW = 64 << 10
+
+// Bitrates of Layer III in kbit/s, by bitrate index.
+var mpeg1Bitrates = [16]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0}
+var mpeg2Bitrates = [16]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0}
+
+// Sample rates of MPEG 1, 2 and 2.5, by sample rate index.
+var mpegSampleRates = map[int][3]int{
+	3: {44100, 48000, 32000},
+	2: {22050, 24000, 16000},
+	0: {11025, 12000, 8000},
+}
+
+type mp3Frame struct {
+	version    int
+	bitrate    int
+	sampleRate int
+	mono       bool
+	length     int
+}
+
+// samples is how many samples per channel a frame holds.
+func (f mp3Frame) samples() int {
+	if f.version == 3 {
+		return 1152
+	}
+
+	return 576
+}
+
+// sideInfo is the size of the side information, which sits between the frame
+// header and the Xing header.
+func (f mp3Frame) sideInfo() int {
+	switch {
+	case f.version == 3 && f.mono:
+		return 17
+	case f.version == 3:
+		return 32
+	case f.mono:
+		return 9
+	}
+
+	return 17
+}
+
+// isFrameHeader only accepts MPEG Layer III headers with valid bitrate and
+// sample rate indexes.
+func isFrameHeader(header []byte) bool {
+	_, ok := parseFrameHeader(header)
+	return ok
+}
+
+func parseFrameHeader(header []byte) (mp3Frame, bool) {
+	if len(header) < 4 || header[0] != 0xFF || header[1]&0xE0 != 0xE0 {
+		return mp3Frame{}, false
+	}
+
+	version := int(header[1]>>3) & 3
+	layer := int(header[1]>>1) & 3
+	bitrateIndex := int(header[2] >> 4)
+	sampleRateIndex := int(header[2]>>2) & 3
+
+	if version == 1 || layer != 1 || bitrateIndex == 0 || bitrateIndex == 15 || sampleRateIndex == 3 {
+		return mp3Frame{}, false
+	}
+
+	frame := mp3Frame{
+		version:    version,
+		bitrate:    mpeg2Bitrates[bitrateIndex] * 1000,
+		sampleRate: mpegSampleRates[version][sampleRateIndex],
+		mono:       header[3]>>6 == 3,
+	}
+
+	if version == 3 {
+		frame.bitrate = mpeg1Bitrates[bitrateIndex] * 1000
+	}
+
+	padding := int(header[2]>>1) & 1
+	frame.length = frame.samples()/8*frame.bitrate/frame.sampleRate + padding
+
+	return frame, true
+}
+
+// probeMP3 finds the first frame after the ID3v2 tag and makes sure the next
+// one follows it. The duration comes from the Xing or VBRI header that VBR
+// encoders write in the first frame, or else from the bitrate.
+func probeMP3(r io.ReaderAt, size int64) (Info, error) {
+	start, err := skipID3(r, size)
+
+	if err != nil {
+		return Info{}, err
+	}
+
+	window, err := readAt(r, start, int(min(size-start, MP3_SYNC_WINDOW)))
+
+	if err != nil {
+		return Info{}, err
+	}
+
+	for i := 0; i+4 <= len(window); i++ {
+		frame, ok := parseFrameHeader(window[i:])
+
+		if !ok || !followedByFrame(window, i, frame) {
+			continue
+		}
+
+		info := Info{SampleRate: frame.sampleRate, Channels: 2}
+
+		if frame.mono {
+			info.Channels = 1
+		}
+
+		audioSize := size - start - int64(i)
+
+		if hasID3v1(r, size) {
+			audioSize -= 128
+		}
+
+		var err error
+
+		if frames, ok := vbrFrames(window[i:], frame); ok {
+			info.Duration, err = seconds(int64(frames)*int64(frame.samples()), int64(frame.sampleRate))
+			info.Bitrate = averageBitrate(audioSize, info.Duration)
+
+			return info, err
+		}
+
+		info.Bitrate = frame.bitrate
+		info.Duration, err = seconds(audioSize*8, int64(frame.bitrate))
+
+		return info, err
+	}
+
+	return Info{}, malformed("no MPEG frame found")
+}
+
+func skipID3(r io.ReaderAt, size int64) (int64, error) {
+	if size < 10 {
+		return 0, nil
+	}
+
+	header, err := readAt(r, 0, 10)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if !bytes.HasPrefix(header, []byte("ID3")) {
+		return 0, nil
+	}
+
+	// The tag size is syncsafe: 7 bits per byte.
+	length := int64(syncsafe(header[6:])) + 10
+
+	if header[5]&0x10 != 0 {
+		length += 10
+	}
+
+	if length >= size {
+		return 0, malformed("ID3 tag of %d bytes in a file of %d", length, size)
+	}
+
+	return length, nil
+}
+
+// followedByFrame tells a real frame from bytes that happen to look like a
+// header. A frame that runs past the window can't be checked and is trusted.
+func followedByFrame(window []byte, offset int, frame mp3Frame) bool {
+	next := offset + frame.length
+
+	if next+4 > len(window) {
+		return next <= len(window) || len(window) == MP3_SYNC_WINDOW
+	}
+
+	following, ok := parseFrameHeader(window[next:])
+
+	return ok && following.version == frame.version && following.sampleRate == frame.sampleRate
+}
+
+func vbrFrames(data []byte, frame mp3Frame) (uint32, bool) {
+	xing := 4 + frame.sideInfo()
+
+	if len(data) >= xing+12 {
+		tag := data[xing : xing+4]
+
+		if bytes.Equal(tag, []byte("Xing")) || bytes.Equal(tag, []byte("Info")) {
+			flags := binary.BigEndian.Uint32(data[xing+4:])
+
+			if flags&1 != 0 {
+				return binary.BigEndian.Uint32(data[xing+8:]), true
+			}
+		}
+	}
+
+	// VBRI always sits 32 bytes after the header.
+	if len(data) >= 36+18 && bytes.Equal(data[36:40], []byte("VBRI")) {
+		return binary.BigEndian.Uint32(data[36+14:]), true
+	}
+
+	return 0, false
+}
+
+func hasID3v1(r io.ReaderAt, size int64) bool {
+	if size < 128 {
+		return false
+	}
+
+	tag, err := readAt(r, size-128, 3)
+
+	return err == nil && bytes.Equal(tag, []byte("TAG"))
+}
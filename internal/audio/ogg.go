@@ -0,0 +1,121 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// OGG_TAIL_SIZE is how much of the end of the file is read to find the last
+// page. A page is at most 65307 bytes long.
+const OGG_TAIL_SIZE = 64 << 10
+
+const OPUS_SAMPLE_RATE = 48000
+
+type oggStream struct {
+	serial     uint32
+	sampleRate int64
+	preSkip    int64
+	channels   int
+}
+
+// probeOGG reads the identification header of the first logical stream,
+// Opus or Vorbis, and takes the duration from the granule position of its
+// last page, which counts samples since the beginning.
+func probeOGG(r io.ReaderAt, size int64) (Info, error) {
+	stream, err := readOGGHeader(r, size)
+
+	if err != nil {
+		return Info{}, err
+	}
+
+	tailSize := min(size, OGG_TAIL_SIZE)
+
+	tail, err := readAt(r, size-tailSize, int(tailSize))
+
+	if err != nil {
+		return Info{}, err
+	}
+
+	for i := bytes.LastIndex(tail, []byte("OggS")); i >= 0; i = bytes.LastIndex(tail[:i], []byte("OggS")) {
+		if i+27 > len(tail) || binary.LittleEndian.Uint32(tail[i+14:]) != stream.serial {
+			continue
+		}
+
+		granule := int64(binary.LittleEndian.Uint64(tail[i+6:]))
+
+		// -1 marks a page where no packet ends.
+		if granule < 0 {
+			continue
+		}
+
+		duration, err := seconds(max(granule-stream.preSkip, 0), stream.sampleRate)
+
+		if err != nil {
+			return Info{}, err
+		}
+
+		return Info{
+			Duration:   duration,
+			SampleRate: int(stream.sampleRate),
+			Channels:   stream.channels,
+		}, nil
+	}
+
+	return Info{}, malformed("no final OGG page")
+}
+
+func readOGGHeader(r io.ReaderAt, size int64) (oggStream, error) {
+	header, err := readAt(r, 0, int(min(size, 27)))
+
+	if err != nil {
+		return oggStream{}, err
+	}
+
+	if len(header) < 27 || header[4] != 0 {
+		return oggStream{}, malformed("OGG page header")
+	}
+
+	segments := int(header[26])
+
+	table, err := readAt(r, 27, segments)
+
+	if err != nil {
+		return oggStream{}, err
+	}
+
+	length := 0
+
+	for _, segment := range table {
+		length += int(segment)
+	}
+
+	packet, err := readAt(r, int64(27+segments), min(length, 64))
+
+	if err != nil {
+		return oggStream{}, err
+	}
+
+	stream := oggStream{serial: binary.LittleEndian.Uint32(header[14:])}
+
+	switch {
+	case len(packet) >= 19 && bytes.HasPrefix(packet, []byte("OpusHead")):
+		// Opus always decodes at 48 kHz, whatever the rate of the source was.
+		stream.sampleRate = OPUS_SAMPLE_RATE
+		stream.preSkip = int64(binary.LittleEndian.Uint16(packet[10:]))
+		stream.channels = int(packet[9])
+
+	case len(packet) >= 30 && bytes.HasPrefix(packet, []byte("\x01vorbis")):
+		stream.sampleRate = int64(binary.LittleEndian.Uint32(packet[12:]))
+		stream.channels = int(packet[11])
+
+	default:
+		return oggStream{}, UnknownFormatErr
+	}
+
+	if stream.sampleRate == 0 {
+		return oggStream{}, malformed("OGG stream with no sample rate")
+	}
+
+	return stream, nil
+}
@@ -0,0 +1,336 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// MAX_TAG_SIZE bounds how much of an ID3v2 tag is read. Text frames usually
+// come first; cover art, which makes tags big, is left out.
+const MAX_TAG_SIZE = 256 << 10
+
+// Tags holds the descriptive tags an encoder or tagger wrote in the file.
+// They are only as good as whoever filled them, so they are never trusted
+// beyond suggesting values.
+type Tags struct {
+	Title   string
+	Artist  string
+	Comment string
+}
+
+// merge fills the empty fields of t with those of other.
+func (t *Tags) merge(other Tags) {
+	setOnce(&t.Title, other.Title)
+	setOnce(&t.Artist, other.Artist)
+	setOnce(&t.Comment, other.Comment)
+}
+
+// readID3 reads the ID3v2 tag at the start of the file and the ID3v1 tag at
+// its end. ID3v2 wins, as ID3v1 cuts every field at 30 characters. Broken
+// tags are ignored: they don't make the audio any less playable.
+func readID3(r io.ReaderAt, size int64) (Tags, error) {
+	tags, err := readID3v2(r, size)
+
+	if err != nil && !errors.Is(err, MalformedErr) {
+		return Tags{}, err
+	}
+
+	v1, err := readID3v1(r, size)
+
+	if err != nil && !errors.Is(err, MalformedErr) {
+		return Tags{}, err
+	}
+
+	tags.merge(v1)
+
+	return tags, nil
+}
+
+func readID3v2(r io.ReaderAt, size int64) (Tags, error) {
+	length, err := skipID3(r, size)
+
+	if err != nil || length == 0 {
+		return Tags{}, err
+	}
+
+	header, err := readAt(r, 0, 10)
+
+	if err != nil {
+		return Tags{}, err
+	}
+
+	data, err := readAt(r, 10, int(min(length-10, MAX_TAG_SIZE)))
+
+	if err != nil {
+		return Tags{}, err
+	}
+
+	return parseID3v2(header[3], header[5], data), nil
+}
+
+// parseID3v2 walks the frames of versions 2.2, 2.3 and 2.4, which differ in
+// the size of the frame ids and how frame sizes are written.
+func parseID3v2(version byte, flags byte, data []byte) Tags {
+	var tags Tags
+
+	// Up to 2.3 unsynchronisation applies to the whole tag.
+	if flags&0x80 != 0 && version < 4 {
+		data = bytes.ReplaceAll(data, []byte{0xFF, 0x00}, []byte{0xFF})
+	}
+
+	if flags&0x40 != 0 && version >= 3 && len(data) >= 4 {
+		extended := int(binary.BigEndian.Uint32(data)) + 4
+
+		if version == 4 {
+			extended = syncsafe(data)
+		}
+
+		if extended > len(data) {
+			return tags
+		}
+
+		data = data[extended:]
+	}
+
+	idLength, headerLength := 4, 10
+
+	if version == 2 {
+		idLength, headerLength = 3, 6
+	}
+
+	for offset := 0; offset+headerLength <= len(data); {
+		header := data[offset : offset+headerLength]
+
+		// Padding fills the rest of the tag with zeros.
+		if header[0] == 0 {
+			break
+		}
+
+		var frameSize int
+
+		switch version {
+		case 2:
+			frameSize = int(header[3])<<16 | int(header[4])<<8 | int(header[5])
+		case 3:
+			frameSize = int(binary.BigEndian.Uint32(header[4:]))
+		default:
+			frameSize = syncsafe(header[4:])
+		}
+
+		start := offset + headerLength
+
+		if frameSize < 0 || frameSize > len(data)-start {
+			break
+		}
+
+		offset = start + frameSize
+
+		body, ok := frameBody(version, header, data[start:offset])
+
+		if !ok || len(body) == 0 {
+			continue
+		}
+
+		switch string(header[:idLength]) {
+		case "TIT2", "TT2":
+			setOnce(&tags.Title, id3Text(body[0], body[1:]))
+		case "TPE1", "TP1":
+			setOnce(&tags.Artist, id3Text(body[0], body[1:]))
+		case "COMM", "COM":
+			setOnce(&tags.Comment, id3Comment(body))
+		}
+	}
+
+	return tags
+}
+
+// frameBody undoes what the frame flags of 2.3 and 2.4 did to the body. It
+// reports false for compressed and encrypted frames, which aren't worth
+// decoding for a suggestion.
+func frameBody(version byte, header []byte, body []byte) ([]byte, bool) {
+	switch version {
+	case 3:
+		return body, header[9]&0xC0 == 0
+
+	case 4:
+		format := header[9]
+
+		if format&0x0C != 0 {
+			return nil, false
+		}
+
+		if format&0x40 != 0 && len(body) > 0 {
+			body = body[1:]
+		}
+
+		if format&0x01 != 0 && len(body) >= 4 {
+			body = body[4:]
+		}
+
+		if format&0x02 != 0 {
+			body = bytes.ReplaceAll(body, []byte{0xFF, 0x00}, []byte{0xFF})
+		}
+	}
+
+	return body, true
+}
+
+// id3Comment skips the language and the description of a comment frame.
+// Players such as iTunes keep their own data in comments described
+// "iTun...", which are no comments at all.
+func id3Comment(body []byte) string {
+	if len(body) < 4 {
+		return ""
+	}
+
+	encoding := body[0]
+	description, text := splitTerminated(encoding, body[4:])
+
+	if strings.HasPrefix(id3Text(encoding, description), "iTun") {
+		return ""
+	}
+
+	return id3Text(encoding, text)
+}
+
+// splitTerminated splits data after the first string, which ends with one
+// zero byte, or two aligned ones for the UTF-16 encodings.
+func splitTerminated(encoding byte, data []byte) ([]byte, []byte) {
+	if encoding == 1 || encoding == 2 {
+		for i := 0; i+1 < len(data); i += 2 {
+			if data[i] == 0 && data[i+1] == 0 {
+				return data[:i], data[i+2:]
+			}
+		}
+
+		return data, nil
+	}
+
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return data[:i], data[i+1:]
+	}
+
+	return data, nil
+}
+
+// id3Text decodes a text in one of the four ID3 encodings. Version 2.4 lets
+// a frame hold several values split by zeros; only the first is kept.
+func id3Text(encoding byte, data []byte) string {
+	var text string
+
+	switch encoding {
+	case 0:
+		text = latin1(data)
+
+	case 1, 2:
+		order := binary.ByteOrder(binary.BigEndian)
+
+		if encoding == 1 && len(data) >= 2 {
+			switch {
+			case data[0] == 0xFF && data[1] == 0xFE:
+				order, data = binary.LittleEndian, data[2:]
+			case data[0] == 0xFE && data[1] == 0xFF:
+				data = data[2:]
+			}
+		}
+
+		units := make([]uint16, len(data)/2)
+
+		for i := range units {
+			units[i] = order.Uint16(data[2*i:])
+		}
+
+		text = string(utf16.Decode(units))
+
+	case 3:
+		text = strings.ToValidUTF8(string(data), "")
+	}
+
+	text, _, _ = strings.Cut(text, "\x00")
+
+	return strings.TrimSpace(text)
+}
+
+func latin1(data []byte) string {
+	runes := make([]rune, len(data))
+
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+
+	return string(runes)
+}
+
+// readID3v1 reads the 128 bytes tag at the end of the file. The comment
+// takes 30 bytes, or 28 when version 1.1 keeps the last two for the track.
+func readID3v1(r io.ReaderAt, size int64) (Tags, error) {
+	if !hasID3v1(r, size) {
+		return Tags{}, nil
+	}
+
+	tag, err := readAt(r, size-128, 128)
+
+	if err != nil {
+		return Tags{}, err
+	}
+
+	field := func(data []byte) string {
+		text, _, _ := strings.Cut(latin1(data), "\x00")
+		return strings.TrimSpace(text)
+	}
+
+	return Tags{
+		Title:   field(tag[3:33]),
+		Artist:  field(tag[33:63]),
+		Comment: field(tag[97:127]),
+	}, nil
+}
+
+// parseILST reads the iTunes style tags kept in moov/udta/meta/ilst. Each
+// item holds a data atom whose body starts with a type and a locale.
+func parseILST(moov []byte) Tags {
+	meta := findAtom(findAtom(moov, "udta"), "meta")
+
+	// meta is a full atom, with version and flags before its children,
+	// except in files written by QuickTime.
+	if len(meta) >= 8 && !bytes.Equal(meta[4:8], []byte("hdlr")) {
+		meta = meta[4:]
+	}
+
+	ilst := findAtom(meta, "ilst")
+
+	item := func(kind string) string {
+		data := findAtom(findAtom(ilst, kind), "data")
+
+		// Type 1 is UTF-8; numbers and pictures are of no use here.
+		if len(data) < 8 || binary.BigEndian.Uint32(data)&0xFFFFFF != 1 {
+			return ""
+		}
+
+		return strings.TrimSpace(strings.ToValidUTF8(string(data[8:]), ""))
+	}
+
+	tags := Tags{
+		Title:   item("\xa9nam"),
+		Artist:  item("\xa9ART"),
+		Comment: item("\xa9cmt"),
+	}
+
+	setOnce(&tags.Artist, item("aART"))
+
+	return tags
+}
+
+func setOnce(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
+
+func syncsafe(data []byte) int {
+	return int(data[0]&0x7F)<<21 | int(data[1]&0x7F)<<14 | int(data[2]&0x7F)<<7 | int(data[3]&0x7F)
+}
@@ -0,0 +1,185 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// WAV_FORMAT_SIZE is how much of the fmt chunk is kept: the 16 bytes every
+// file has and the extension of WAVE_FORMAT_EXTENSIBLE.
+const WAV_FORMAT_SIZE = 40
+
+// MAX_WAV_CHANNELS is far more channels than any audio has, and keeps a
+// frame well within DECODE_BUFFER_SIZE.
+const MAX_WAV_CHANNELS = 64
+
+type wavLayout struct {
+	format     []byte
+	dataOffset int64
+	dataLength int64
+}
+
+// probeWAV takes the properties from the fmt chunk and the duration from
+// the length of the data chunk.
+func probeWAV(r io.ReaderAt, size int64) (Info, error) {
+	layout, err := readWAVLayout(r, size)
+
+	if err != nil {
+		return Info{}, err
+	}
+
+	byteRate := binary.LittleEndian.Uint32(layout.format[8:])
+
+	duration, err := seconds(layout.dataLength, int64(byteRate))
+
+	if err != nil {
+		return Info{}, err
+	}
+
+	return Info{
+		Duration:   duration,
+		Bitrate:    int(byteRate) * 8,
+		SampleRate: int(binary.LittleEndian.Uint32(layout.format[4:])),
+		Channels:   int(binary.LittleEndian.Uint16(layout.format[2:])),
+	}, nil
+}
+
+// readWAVLayout walks the RIFF chunks until the data chunk. The fmt chunk
+// has to come first, as the spec asks, since it says how to read the data.
+func readWAVLayout(r io.ReaderAt, size int64) (wavLayout, error) {
+	var layout wavLayout
+
+	for offset := int64(12); offset+8 <= size; {
+		header, err := readAt(r, offset, 8)
+
+		if err != nil {
+			return wavLayout{}, err
+		}
+
+		id := header[:4]
+		length := int64(binary.LittleEndian.Uint32(header[4:]))
+
+		switch {
+		case bytes.Equal(id, []byte("fmt ")):
+			if length < 16 {
+				return wavLayout{}, malformed("fmt chunk of %d bytes", length)
+			}
+
+			layout.format, err = readAt(r, offset+8, int(min(length, WAV_FORMAT_SIZE)))
+
+			if err != nil {
+				return wavLayout{}, err
+			}
+
+			if binary.LittleEndian.Uint32(layout.format[8:]) == 0 {
+				return wavLayout{}, malformed("fmt chunk with no byte rate")
+			}
+
+			if channels := binary.LittleEndian.Uint16(layout.format[2:]); channels == 0 || channels > MAX_WAV_CHANNELS {
+				return wavLayout{}, malformed("fmt chunk with %d channels", channels)
+			}
+
+		case bytes.Equal(id, []byte("data")):
+			if layout.format == nil {
+				return wavLayout{}, malformed("data chunk before the fmt chunk")
+			}
+
+			// Streaming encoders leave the length unset, so the data runs to the end.
+			if available := size - offset - 8; length > available {
+				length = available
+			}
+
+			layout.dataOffset = offset + 8
+			layout.dataLength = length
+
+			return layout, nil
+		}
+
+		offset += 8 + length + length%2
+	}
+
+	return wavLayout{}, malformed("no data chunk")
+}
+
+// WAVSlice is part of a WAV file made into a file of its own: Header goes
+// before the Length bytes found at Offset of the source.
+type WAVSlice struct {
+	Header []byte
+	Offset int64
+	Length int64
+}
+
+// SliceWAV locates the samples between start and end of a WAV file. Both
+// are moved back to the start of their frame, so no sample is split. An end
+// past the end of the audio is moved back to it.
+func SliceWAV(r io.ReaderAt, size int64, start time.Duration, end time.Duration) (WAVSlice, error) {
+	header, err := readAt(r, 0, int(min(size, SNIFF_SIZE)))
+
+	if err != nil {
+		return WAVSlice{}, err
+	}
+
+	if Sniff(header) != FormatWAV {
+		return WAVSlice{}, UnknownFormatErr
+	}
+
+	layout, err := readWAVLayout(r, size)
+
+	if err != nil {
+		return WAVSlice{}, err
+	}
+
+	sampleRate := int64(binary.LittleEndian.Uint32(layout.format[4:]))
+	blockAlign := int64(binary.LittleEndian.Uint16(layout.format[12:]))
+
+	if blockAlign == 0 {
+		return WAVSlice{}, malformed("fmt chunk with no block align")
+	}
+
+	frames := layout.dataLength / blockAlign
+	first := frameAt(start, sampleRate, frames)
+	last := frameAt(end, sampleRate, frames)
+
+	if first >= last {
+		return WAVSlice{}, OutOfRangeErr
+	}
+
+	length := (last - first) * blockAlign
+
+	var buf bytes.Buffer
+
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(4+8+len(layout.format)+8+int(length)))
+	buf.WriteString("WAVEfmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(layout.format)))
+	buf.Write(layout.format)
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(length))
+
+	return WAVSlice{
+		Header: buf.Bytes(),
+		Offset: layout.dataOffset + first*blockAlign,
+		Length: length,
+	}, nil
+}
+
+// frameAt is the frame playing at t, moved back to frames when past the end.
+// Whole seconds and their fraction are counted apart, as nanoseconds times
+// the sample rate overflow within a couple of days of audio.
+func frameAt(t time.Duration, sampleRate int64, frames int64) int64 {
+	if t <= 0 || sampleRate == 0 {
+		return 0
+	}
+
+	seconds := int64(t / time.Second)
+
+	if seconds > frames/sampleRate {
+		return frames
+	}
+
+	fraction := int64(t%time.Second) * sampleRate / int64(time.Second)
+
+	return min(seconds*sampleRate+fraction, frames)
+}
@@ -1,5 +1,73 @@
 package dto
 
+import (
+	"strconv"
+	"time"
+)
+
+// MAX_AUDIO_SIZE is the most bytes an audio can have, both declared before
+// the upload and checked once it reached S3.
+const MAX_AUDIO_SIZE int64 = 10 << 20
+
+// AudioDTOInput declares the audio about to be uploaded. The content type,
+// size and SHA-256 are signed into the upload URL, so S3 refuses any other
+// object.
 type AudioDTOInput struct {
-	Filename string `json:"filename"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType" validate:"required,oneof=audio/mpeg audio/ogg audio/wav audio/x-wav audio/mp4 audio/x-m4a"`
+	Size        int64  `json:"size" validate:"required,min=1"`
+	SHA256      string `json:"sha256" validate:"required,len=64,hexadecimal"`
+}
+
+func (a *AudioDTOInput) Validate() []MetadataInputError {
+	errors := validate(a)
+
+	if a.Size > MAX_AUDIO_SIZE {
+		errors = append(errors, maxError("Size", MAX_AUDIO_SIZE))
+	}
+
+	return errors
+}
+
+// maxError reports a field over a limit kept in a constant, as the max tag
+// of the validator only takes literals.
+func maxError(field string, limit int64) MetadataInputError {
+	return MetadataInputError{Field: field, Tag: "max", Value: strconv.FormatInt(limit, 10)}
+}
+
+// PresignedPutDTO is a URL to upload to along with the headers it was signed
+// with, which the upload has to send as they are.
+type PresignedPutDTO struct {
+	Url     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// AudioObjectDTO is what S3 tells of a stored audio.
+type AudioObjectDTO struct {
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+// PresignOptions tune a pre-signed URL. Expires keeps the default of the SDK
+// when zero. DownloadName and ContentType only apply to GET URLs, overriding
+// the name the audio is saved as and the type it is served with; Attachment
+// makes browsers save it rather than play it.
+type PresignOptions struct {
+	Expires      time.Duration
+	DownloadName string
+	ContentType  string
+	Attachment   bool
+}
+
+// WaveformDTO is the sidecar stored next to an audio for players to draw
+// it without downloading the audio.
+type WaveformDTO struct {
+	DurationMs int64     `json:"durationMs"`
+	Peaks      []float64 `json:"peaks"`
+}
+
+// WaveformOutput is a stored sidecar, kept as the JSON it was stored as.
+type WaveformOutput struct {
+	Body []byte
+	ETag string
 }
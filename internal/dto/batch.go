@@ -0,0 +1,25 @@
+package dto
+
+// AudioBatchDTOInput declares many audio about to be uploaded at once.
+type AudioBatchDTOInput struct {
+	Items []AudioDTOInput `json:"items"`
+}
+
+// BatchResultDTO is the outcome of the item at Index of a batch. Errors
+// holds why an invalid item was refused, and Message why it failed. Headers
+// are the ones the upload to Url has to send.
+type BatchResultDTO struct {
+	Index    int                  `json:"index"`
+	Status   string               `json:"status"`
+	ID       string               `json:"id,omitempty"`
+	FileName string               `json:"filename,omitempty"`
+	Url      string               `json:"url,omitempty"`
+	Headers  map[string]string    `json:"headers,omitempty"`
+	Message  string               `json:"message,omitempty"`
+	Errors   []MetadataInputError `json:"errors,omitempty"`
+}
+
+// MetadataBatchDTOInput publishes many uploads at once.
+type MetadataBatchDTOInput struct {
+	Items []MetadataDTOInput `json:"items"`
+}
@@ -0,0 +1,26 @@
+package dto
+
+// ClipDTOInput asks for the audio between Start and End of an episode,
+// written as [[hh:]mm:]ss[.mmm], to be stored with the given metadata.
+type ClipDTOInput struct {
+	Start    string `json:"start" validate:"required"`
+	End      string `json:"end" validate:"required"`
+	FileName string `json:"filename" validate:"required"`
+	Author   string `json:"author" validate:"required"`
+	Label    string `json:"label" validate:"required"`
+	Type     string `json:"type" validate:"required"`
+	Words    string `json:"words" validate:"required"`
+}
+
+type ClipDTOOutput struct {
+	ID        string `json:"id"`
+	EpisodeID string `json:"episodeId"`
+	StartMs   int64  `json:"startMs"`
+	EndMs     int64  `json:"endMs"`
+	Status    string `json:"status"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+func (a *ClipDTOInput) Validate() []MetadataInputError {
+	return validate(a)
+}
@@ -1,23 +1,82 @@
 package dto
 
 import (
+	"github.com/LucasAndFlores/go_lambdas_project/internal/ulid"
 	"github.com/go-playground/validator/v10"
 )
 
 type MetadataDTOInput struct {
+	ID       string `json:"id" validate:"required,ulid"`
 	FileName string `json:"filename" validate:"required"`
 	Author   string `json:"author" validate:"required"`
 	Label    string `json:"label" validate:"required"`
 	Type     string `json:"type" validate:"required"`
 	Words    string `json:"words" validate:"required"`
+
+	// EpisodeID and EpisodeOffsetMs are set for clips cut out of an episode,
+	// never by the client.
+	EpisodeID       string `json:"-"`
+	EpisodeOffsetMs int64  `json:"-"`
+}
+
+type MetadataDTOPatchInput struct {
+	FileName *string `json:"filename" validate:"omitnil,min=1"`
+	Author   *string `json:"author" validate:"omitnil,min=1"`
+	Label    *string `json:"label" validate:"omitnil,min=1"`
+	Type     *string `json:"type" validate:"omitnil,min=1"`
+	Words    *string `json:"words" validate:"omitnil,min=1"`
 }
 
 type MetadataDTOOutput struct {
+	ID       string `json:"id" validate:"required"`
 	FileName string `json:"filename" validate:"required"`
 	Author   string `json:"author" validate:"required"`
 	Label    string `json:"label" validate:"required"`
 	Type     string `json:"type" validate:"required"`
 	Words    string `json:"words" validate:"required"`
+	Format   string `json:"format,omitempty"`
+
+	DurationMs int64 `json:"durationMs"`
+	Bitrate    int   `json:"bitrate"`
+	SampleRate int   `json:"sampleRate"`
+	Channels   int   `json:"channels"`
+	Size       int64 `json:"size"`
+
+	LoudnessLUFS *float64 `json:"loudnessLufs,omitempty"`
+	TruePeakDBTP *float64 `json:"truePeakDbtp,omitempty"`
+
+	Episode *EpisodeDTO `json:"episode,omitempty"`
+}
+
+// EpisodeDTO points a clip back to the episode it was cut out of and to
+// where it starts in it.
+type EpisodeDTO struct {
+	ID       string `json:"id"`
+	OffsetMs int64  `json:"offsetMs"`
+}
+
+// SuggestedMetadataDTO holds what the tags of an audio suggest for its
+// metadata. Fields the tags don't cover are empty.
+type SuggestedMetadataDTO struct {
+	ID       string `json:"id"`
+	FileName string `json:"filename"`
+	Author   string `json:"author"`
+	Label    string `json:"label"`
+	Words    string `json:"words"`
+}
+
+type MetadataListInput struct {
+	Limit  int32
+	Cursor string
+	All    bool
+	Author string
+	Label  string
+	Type   string
+}
+
+type MetadataListOutput struct {
+	Metadata   []MetadataDTOOutput
+	NextCursor string
 }
 
 type MetadataInputError struct {
@@ -28,10 +87,49 @@ type MetadataInputError struct {
 
 var MetadataValidator = validator.New()
 
+// The ulid tag accepts the ids handed out by the server only.
+func init() {
+	MetadataValidator.RegisterValidation("ulid", func(fl validator.FieldLevel) bool {
+		return ulid.Valid(fl.Field().String())
+	})
+}
+
 func (a *MetadataDTOInput) Validate() []MetadataInputError {
+	return validate(a)
+}
+
+// ApplySuggestions fills the fields left empty with the suggested values.
+// A submitted value always wins, even over a tag that says otherwise.
+func (a *MetadataDTOInput) ApplySuggestions(s SuggestedMetadataDTO) {
+	for _, field := range []struct {
+		value      *string
+		suggestion string
+	}{
+		{&a.FileName, s.FileName},
+		{&a.Author, s.Author},
+		{&a.Label, s.Label},
+		{&a.Words, s.Words},
+	} {
+		if *field.value == "" {
+			*field.value = field.suggestion
+		}
+	}
+}
+
+// MissingSuggestedFields reports whether a left empty any field that
+// ApplySuggestions could fill.
+func (a *MetadataDTOInput) MissingSuggestedFields() bool {
+	return a.FileName == "" || a.Author == "" || a.Label == "" || a.Words == ""
+}
+
+func (a *MetadataDTOPatchInput) Validate() []MetadataInputError {
+	return validate(a)
+}
+
+func validate(s interface{}) []MetadataInputError {
 	var errors []MetadataInputError
 
-	err := MetadataValidator.Struct(a)
+	err := MetadataValidator.Struct(s)
 
 	if err != nil {
 		for _, err := range err.(validator.ValidationErrors) {
@@ -0,0 +1,48 @@
+package dto
+
+// MAX_EPISODE_SIZE is the most bytes an episode uploaded in parts can have.
+const MAX_EPISODE_SIZE int64 = 5 << 30
+
+// MultipartDTOInput declares the size of an episode uploaded in parts.
+type MultipartDTOInput struct {
+	Size int64 `json:"size" validate:"required,min=1"`
+}
+
+// PartDTOInput is the ETag S3 answered the upload of a part with.
+type PartDTOInput struct {
+	ETag string `json:"etag" validate:"required,max=128"`
+}
+
+// PartDTO is the URL to upload a part to. The part has to be exactly Size
+// bytes long.
+type PartDTO struct {
+	Number int32  `json:"number"`
+	Size   int64  `json:"size"`
+	Url    string `json:"url"`
+}
+
+// MultipartDTOOutput is the progress of an episode uploaded in parts. Parts
+// holds the URLs of the parts not uploaded yet, while the upload is pending.
+type MultipartDTOOutput struct {
+	ID             string    `json:"id"`
+	Status         string    `json:"status"`
+	Size           int64     `json:"size"`
+	PartSize       int64     `json:"partSize"`
+	PartCount      int32     `json:"partCount"`
+	CompletedParts []int32   `json:"completedParts"`
+	Parts          []PartDTO `json:"parts,omitempty"`
+}
+
+func (m *MultipartDTOInput) Validate() []MetadataInputError {
+	errors := validate(m)
+
+	if m.Size > MAX_EPISODE_SIZE {
+		errors = append(errors, maxError("Size", MAX_EPISODE_SIZE))
+	}
+
+	return errors
+}
+
+func (p *PartDTOInput) Validate() []MetadataInputError {
+	return validate(p)
+}
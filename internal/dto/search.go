@@ -0,0 +1,6 @@
+package dto
+
+type SearchResultDTO struct {
+	Metadata MetadataDTOOutput `json:"metadata"`
+	Score    int               `json:"score"`
+}
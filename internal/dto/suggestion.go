@@ -0,0 +1,14 @@
+package dto
+
+type SuggestInput struct {
+	Prefix string
+	Kind   string
+	Order  string
+	Limit  int32
+}
+
+type SuggestionDTO struct {
+	Value string `json:"value"`
+	Kind  string `json:"kind"`
+	Count int    `json:"count"`
+}
@@ -0,0 +1,25 @@
+package entity
+
+// AudioProperties are measured by probing the audio once it reaches S3. They
+// are staged on the upload and copied to the metadata when it is published.
+// Loudness and the PCM hash are only known for audio that could be decoded,
+// and loudness only when it isn't silent. The hashes find duplicates.
+type AudioProperties struct {
+	DurationMs   int64    `dynamodbav:"duration_ms"`
+	Bitrate      int      `dynamodbav:"bitrate"`
+	SampleRate   int      `dynamodbav:"sample_rate"`
+	Channels     int      `dynamodbav:"channels"`
+	Size         int64    `dynamodbav:"size"`
+	LoudnessLUFS *float64 `dynamodbav:"loudness_lufs,omitempty"`
+	TruePeakDBTP *float64 `dynamodbav:"true_peak_dbtp,omitempty"`
+	SHA256       string   `dynamodbav:"sha256,omitempty"`
+	PCMSHA256    string   `dynamodbav:"pcm_sha256,omitempty"`
+}
+
+// AudioTags are read from the ID3 or MP4 tags of the audio. They only feed
+// the suggested metadata, so they stay on the upload.
+type AudioTags struct {
+	Title   string `dynamodbav:"title,omitempty"`
+	Artist  string `dynamodbav:"artist,omitempty"`
+	Comment string `dynamodbav:"comment,omitempty"`
+}
@@ -0,0 +1,31 @@
+package entity
+
+import "github.com/LucasAndFlores/go_lambdas_project/internal/dto"
+
+// Clip is a job cutting an insertion out of an episode. It keeps the
+// metadata the insertion is stored with once its audio is cut.
+type Clip struct {
+	ID        string `dynamodbav:"id"`
+	EpisodeID string `dynamodbav:"episode_id"`
+	StartMs   int64  `dynamodbav:"start_ms"`
+	EndMs     int64  `dynamodbav:"end_ms"`
+	Status    string `dynamodbav:"status"`
+	Reason    string `dynamodbav:"reason,omitempty"`
+
+	FileName string `dynamodbav:"filename"`
+	Author   string `dynamodbav:"author"`
+	Label    string `dynamodbav:"label"`
+	Type     string `dynamodbav:"type"`
+	Words    string `dynamodbav:"words"`
+}
+
+func (c *Clip) ConvertToDTO() dto.ClipDTOOutput {
+	return dto.ClipDTOOutput{
+		ID:        c.ID,
+		EpisodeID: c.EpisodeID,
+		StartMs:   c.StartMs,
+		EndMs:     c.EndMs,
+		Status:    c.Status,
+		Reason:    c.Reason,
+	}
+}
@@ -3,20 +3,47 @@ package entity
 import "github.com/LucasAndFlores/go_lambdas_project/internal/dto"
 
 type Metadata struct {
+	ID       string `dynamodbav:"id"`
 	FileName string `dynamodbav:"filename"`
 	Author   string `dynamodbav:"author"`
 	Label    string `dynamodbav:"label"`
 	Type     string `dynamodbav:"type"`
 	Words    string `dynamodbav:"words"`
+
+	AuthorNormalized string `dynamodbav:"author_normalized"`
+	LabelNormalized  string `dynamodbav:"label_normalized"`
+	Format           string `dynamodbav:"format,omitempty"`
+
+	// Insertions cut out of an episode point back to where they come from.
+	EpisodeID       string `dynamodbav:"episode_id,omitempty"`
+	EpisodeOffsetMs int64  `dynamodbav:"episode_offset_ms,omitempty"`
+
+	AudioProperties
 }
 
 func (m *Metadata) ConvertToDTO() dto.MetadataDTOOutput {
-	return dto.MetadataDTOOutput{
+	output := dto.MetadataDTOOutput{
+		ID:       m.ID,
 		FileName: m.FileName,
 		Author:   m.Author,
 		Label:    m.Label,
 		Type:     m.Type,
 		Words:    m.Words,
+		Format:   m.Format,
+
+		DurationMs: m.DurationMs,
+		Bitrate:    m.Bitrate,
+		SampleRate: m.SampleRate,
+		Channels:   m.Channels,
+		Size:       m.Size,
+
+		LoudnessLUFS: m.LoudnessLUFS,
+		TruePeakDBTP: m.TruePeakDBTP,
+	}
+
+	if m.EpisodeID != "" {
+		output.Episode = &dto.EpisodeDTO{ID: m.EpisodeID, OffsetMs: m.EpisodeOffsetMs}
 	}
 
+	return output
 }
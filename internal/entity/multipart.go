@@ -0,0 +1,59 @@
+package entity
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
+)
+
+// MultipartUpload tracks an episode uploaded in parts. Parts maps the number
+// of every part uploaded so far to its ETag, which completing the upload
+// needs.
+type MultipartUpload struct {
+	ID        string            `dynamodbav:"id"`
+	UploadID  string            `dynamodbav:"upload_id"`
+	Size      int64             `dynamodbav:"size"`
+	PartSize  int64             `dynamodbav:"part_size"`
+	Parts     map[string]string `dynamodbav:"parts"`
+	Status    string            `dynamodbav:"status"`
+	ExpiresAt int64             `dynamodbav:"expires_at"`
+}
+
+func (m *MultipartUpload) PartCount() int32 {
+	return int32((m.Size + m.PartSize - 1) / m.PartSize)
+}
+
+// PartLength is the size of the part number, the last one holding what is
+// left.
+func (m *MultipartUpload) PartLength(number int32) int64 {
+	return min(m.PartSize, m.Size-int64(number-1)*m.PartSize)
+}
+
+// CompletedParts lists the numbers of the parts uploaded, in order.
+func (m *MultipartUpload) CompletedParts() []int32 {
+	completed := make([]int32, 0, len(m.Parts))
+
+	for key := range m.Parts {
+		number, err := strconv.ParseInt(key, 10, 32)
+
+		if err == nil {
+			completed = append(completed, int32(number))
+		}
+	}
+
+	sort.Slice(completed, func(i, j int) bool { return completed[i] < completed[j] })
+
+	return completed
+}
+
+func (m *MultipartUpload) ConvertToDTO() dto.MultipartDTOOutput {
+	return dto.MultipartDTOOutput{
+		ID:             m.ID,
+		Status:         m.Status,
+		Size:           m.Size,
+		PartSize:       m.PartSize,
+		PartCount:      m.PartCount(),
+		CompletedParts: m.CompletedParts(),
+	}
+}
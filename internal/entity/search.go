@@ -0,0 +1,7 @@
+package entity
+
+type SearchEntry struct {
+	Term string `dynamodbav:"term"`
+	ID   string `dynamodbav:"id"`
+	TF   int    `dynamodbav:"tf"`
+}
@@ -0,0 +1,19 @@
+package entity
+
+import "github.com/LucasAndFlores/go_lambdas_project/internal/dto"
+
+type Suggestion struct {
+	Prefix  string `dynamodbav:"prefix"`
+	Value   string `dynamodbav:"value"`
+	Kind    string `dynamodbav:"kind"`
+	Display string `dynamodbav:"display"`
+	Count   int    `dynamodbav:"count"`
+}
+
+func (s *Suggestion) ConvertToDTO() dto.SuggestionDTO {
+	return dto.SuggestionDTO{
+		Value: s.Display,
+		Kind:  s.Kind,
+		Count: s.Count,
+	}
+}
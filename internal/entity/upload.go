@@ -0,0 +1,17 @@
+package entity
+
+// Upload follows an audio from the URL handed out until it is published.
+// Replacing is the archive of the audio being replaced, while a replacement
+// is under way.
+type Upload struct {
+	ID        string    `dynamodbav:"id"`
+	FileName  string    `dynamodbav:"filename"`
+	Status    string    `dynamodbav:"status"`
+	ExpiresAt int64     `dynamodbav:"expires_at"`
+	Reason    string    `dynamodbav:"reason,omitempty"`
+	Format    string    `dynamodbav:"format,omitempty"`
+	Tags      AudioTags `dynamodbav:"tags,omitempty"`
+	Replacing string    `dynamodbav:"replacing,omitempty"`
+
+	AudioProperties
+}
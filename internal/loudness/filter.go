@@ -0,0 +1,62 @@
+package loudness
+
+import (
+	"math"
+)
+
+type biquad struct {
+	b0, b1, b2 float64
+	a1, a2     float64
+
+	// The state of the transposed direct form II.
+	z1, z2 float64
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.z1
+	f.z1 = f.b1*x - f.a1*y + f.z2
+	f.z2 = f.b2*x - f.a2*y
+
+	return y
+}
+
+// kWeighting returns the two stages of the K-weighting filter: a high shelf
+// for the effect of the head, then a high pass. BS.1770 only lists their
+// coefficients at 48 kHz, so they are derived from the analog prototypes
+// for any sample rate, giving the same coefficients at 48 kHz.
+func kWeighting(sampleRate float64) (biquad, biquad) {
+	const (
+		shelfFrequency = 1681.974450955533
+		shelfGain      = 3.999843853973347
+		shelfQ         = 0.7071752369554196
+
+		passFrequency = 38.13547087602444
+		passQ         = 0.5003270373238773
+	)
+
+	k := math.Tan(math.Pi * shelfFrequency / sampleRate)
+	vh := math.Pow(10, shelfGain/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/shelfQ + k*k
+
+	shelf := biquad{
+		b0: (vh + vb*k/shelfQ + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/shelfQ + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/shelfQ + k*k) / a0,
+	}
+
+	k = math.Tan(math.Pi * passFrequency / sampleRate)
+	a0 = 1 + k/passQ + k*k
+
+	pass := biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/passQ + k*k) / a0,
+	}
+
+	return shelf, pass
+}
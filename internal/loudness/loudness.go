@@ -0,0 +1,192 @@
+// Package loudness measures the integrated loudness and the true peak of
+// decoded audio, as EBU R128 asks, following ITU-R BS.1770-4.
+package loudness
+
+import (
+	"math"
+)
+
+const (
+	// BLOCK_STEP is how far apart the gating blocks start. Each block lasts
+	// four steps, so consecutive blocks overlap by 75%.
+	BLOCK_STEP = 0.1
+
+	ABSOLUTE_GATE = -70.0
+	RELATIVE_GATE = -10.0
+
+	// OVERSAMPLING is how many samples true peak looks at for each sample.
+	OVERSAMPLING = 4
+)
+
+// truePeakFilter is the interpolation filter of BS.1770-4 Annex 2, split in
+// one phase per oversampled position.
+var truePeakFilter = [OVERSAMPLING][12]float64{
+	{0.0017089843750, 0.0109863281250, -0.0196533203125, 0.0332031250000, -0.0594482421875, 0.1373291015625, 0.9721679687500, -0.1022949218750, 0.0476074218750, -0.0266113281250, 0.0148925781250, -0.0083007812500},
+	{-0.0291748046875, 0.0292968750000, -0.0517578125000, 0.0891113281250, -0.1665039062500, 0.4650878906250, 0.7797851562500, -0.2003173828125, 0.1015625000000, -0.0582275390625, 0.0330810546875, -0.0189208984375},
+	{-0.0189208984375, 0.0330810546875, -0.0582275390625, 0.1015625000000, -0.2003173828125, 0.7797851562500, 0.4650878906250, -0.1665039062500, 0.0891113281250, -0.0517578125000, 0.0292968750000, -0.0291748046875},
+	{-0.0083007812500, 0.0148925781250, -0.0266113281250, 0.0476074218750, -0.1022949218750, 0.9721679687500, 0.1373291015625, -0.0594482421875, 0.0332031250000, -0.0196533203125, 0.0109863281250, 0.0017089843750},
+}
+
+// Result holds the integrated loudness in LUFS and the true peak in dBTP.
+type Result struct {
+	Integrated float64
+	TruePeak   float64
+}
+
+// Meter takes the frames of an audio one at a time, so the audio never has
+// to be kept in memory.
+type Meter struct {
+	weights  []float64
+	channels []channel
+
+	stepFrames int
+	frames     int
+
+	// steps keeps the weighted energy of the last steps of a block.
+	steps  [4]float64
+	filled int
+	blocks []float64
+
+	peak float64
+}
+
+type channel struct {
+	shelf  biquad
+	pass   biquad
+	energy float64
+
+	history [12]float64
+}
+
+// NewMeter returns a meter for audio of sampleRate Hz with channels in the
+// usual order: left, right, center, LFE, left and right surround.
+func NewMeter(sampleRate int, channels int) *Meter {
+	shelf, pass := kWeighting(float64(sampleRate))
+
+	m := &Meter{
+		weights:    make([]float64, channels),
+		channels:   make([]channel, channels),
+		stepFrames: max(int(math.Round(float64(sampleRate)*BLOCK_STEP)), 1),
+	}
+
+	for i := range m.channels {
+		m.channels[i] = channel{shelf: shelf, pass: pass}
+		m.weights[i] = 1
+	}
+
+	// The LFE channel doesn't count, while surround channels count more.
+	if channels == 6 {
+		m.weights[3] = 0
+		m.weights[4] = 1.41
+		m.weights[5] = 1.41
+	}
+
+	return m
+}
+
+// Add takes a frame with one sample per channel, scaled to [-1, 1].
+func (m *Meter) Add(frame []float64) {
+	for i := range m.channels {
+		c := &m.channels[i]
+		sample := frame[i]
+
+		filtered := c.pass.process(c.shelf.process(sample))
+		c.energy += filtered * filtered
+
+		m.peak = max(m.peak, c.truePeak(sample))
+	}
+
+	m.frames++
+
+	if m.frames < m.stepFrames {
+		return
+	}
+
+	energy := 0.0
+
+	for i := range m.channels {
+		energy += m.weights[i] * m.channels[i].energy / float64(m.stepFrames)
+		m.channels[i].energy = 0
+	}
+
+	m.frames = 0
+	m.steps[m.filled%4] = energy
+	m.filled++
+
+	if m.filled >= 4 {
+		m.blocks = append(m.blocks, (m.steps[0]+m.steps[1]+m.steps[2]+m.steps[3])/4)
+	}
+}
+
+// Result gates the blocks and returns the loudness of what is left. It
+// reports false when nothing is left, as happens with silence or audio
+// shorter than a block.
+func (m *Meter) Result() (Result, bool) {
+	absolute := gated(m.blocks, energy(ABSOLUTE_GATE))
+
+	if len(absolute) == 0 {
+		return Result{}, false
+	}
+
+	// The relative gate sits below the loudness of what passed the absolute
+	// one, so it is a ratio of energies.
+	relative := gated(absolute, mean(absolute)*math.Pow(10, RELATIVE_GATE/10))
+
+	return Result{
+		Integrated: loudness(mean(relative)),
+		TruePeak:   20 * math.Log10(m.peak),
+	}, true
+}
+
+// truePeak oversamples the channel around its last sample. The filter
+// passes the samples through unchanged only approximately, so the sample
+// itself is looked at too.
+func (c *channel) truePeak(sample float64) float64 {
+	copy(c.history[1:], c.history[:len(c.history)-1])
+	c.history[0] = sample
+
+	peak := math.Abs(sample)
+
+	for _, phase := range truePeakFilter {
+		interpolated := 0.0
+
+		for k, coefficient := range phase {
+			interpolated += coefficient * c.history[k]
+		}
+
+		peak = max(peak, math.Abs(interpolated))
+	}
+
+	return peak
+}
+
+func gated(blocks []float64, threshold float64) []float64 {
+	var kept []float64
+
+	for _, block := range blocks {
+		if block > threshold {
+			kept = append(kept, block)
+		}
+	}
+
+	return kept
+}
+
+func mean(values []float64) float64 {
+	sum := 0.0
+
+	for _, value := range values {
+		sum += value
+	}
+
+	return sum / float64(len(values))
+}
+
+func loudness(energy float64) float64 {
+	return -0.691 + 10*math.Log10(energy)
+}
+
+// energy is the inverse of loudness.
+func energy(loudness float64) float64 {
+	return math.Pow(10, (loudness+0.691)/10)
+}
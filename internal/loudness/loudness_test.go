@@ -0,0 +1,81 @@
+package loudness
+
+import (
+	"math"
+	"testing"
+)
+
+// sine measures seconds of a sine wave of frequency Hz and amplitude in
+// every channel.
+func sine(sampleRate int, channels int, seconds float64, frequency float64, phase float64, amplitude float64) *Meter {
+	meter := NewMeter(sampleRate, channels)
+	frame := make([]float64, channels)
+
+	for n := 0; n < int(seconds*float64(sampleRate)); n++ {
+		for i := range frame {
+			frame[i] = amplitude * math.Sin(2*math.Pi*frequency*float64(n)/float64(sampleRate)+phase)
+		}
+
+		meter.Add(frame)
+	}
+
+	return meter
+}
+
+func TestIntegratedLoudness(t *testing.T) {
+	// EBU Tech 3341 expects a stereo 1 kHz sine at -23 dBFS to measure -23 LUFS.
+	cases := map[string]struct {
+		meter    *Meter
+		expected float64
+	}{
+		"stereo 48 kHz":   {sine(48000, 2, 5, 1000, 0, math.Pow(10, -23.0/20)), -23},
+		"stereo 44.1 kHz": {sine(44100, 2, 5, 1000, 0, math.Pow(10, -23.0/20)), -23},
+		"mono":            {sine(44100, 1, 5, 1000, 0, math.Pow(10, -20.0/20)), -23.01},
+	}
+
+	for name, c := range cases {
+		result, ok := c.meter.Result()
+
+		if !ok || math.Abs(result.Integrated-c.expected) > 0.1 {
+			t.Errorf("%s: The result is different from expected. Result: %v %v. Expected: %v", name, result.Integrated, ok, c.expected)
+		}
+	}
+}
+
+func TestIntegratedLoudnessIgnoresSilence(t *testing.T) {
+	meter := sine(48000, 2, 5, 1000, 0, math.Pow(10, -23.0/20))
+
+	for n := 0; n < 48000*5; n++ {
+		meter.Add([]float64{0, 0})
+	}
+
+	result, ok := meter.Result()
+
+	// The blocks straddling the end of the sine are quieter, but still pass
+	// the relative gate.
+	if !ok || math.Abs(result.Integrated+23) > 0.2 {
+		t.Errorf("The result is different from expected. Result: %v %v. Expected: %v", result.Integrated, ok, -23)
+	}
+
+	_, ok = sine(48000, 2, 5, 1000, 0, 0).Result()
+
+	if ok {
+		t.Errorf("Silence should have no loudness")
+	}
+}
+
+func TestTruePeak(t *testing.T) {
+	// Sampled a quarter of a period apart and 45 degrees off, the samples of
+	// this sine never reach its peak of 1.
+	result, ok := sine(48000, 2, 1, 12000, math.Pi/4, 1).Result()
+
+	if !ok || result.TruePeak < -0.5 || result.TruePeak > 0.5 {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", result.TruePeak, 0)
+	}
+
+	result, _ = sine(48000, 2, 1, 1000, 0, 0.5).Result()
+
+	if math.Abs(result.TruePeak+6.02) > 0.1 {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", result.TruePeak, -6.02)
+	}
+}
@@ -0,0 +1,75 @@
+package normalize
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// stopWords are common Brazilian Portuguese words, already folded, that carry
+// no meaning on their own and would match almost every insertion.
+var stopWords = map[string]struct{}{
+	"a": {}, "o": {}, "e": {}, "as": {}, "os": {}, "um": {}, "uma": {}, "uns": {}, "umas": {},
+	"de": {}, "da": {}, "do": {}, "das": {}, "dos": {}, "em": {}, "na": {}, "no": {}, "nas": {}, "nos": {},
+	"ao": {}, "aos": {}, "por": {}, "pelo": {}, "pela": {}, "pelos": {}, "pelas": {}, "para": {}, "pra": {},
+	"com": {}, "sem": {}, "que": {}, "se": {}, "ou": {}, "mas": {}, "como": {}, "mais": {}, "muito": {},
+	"me": {}, "te": {}, "lhe": {}, "ja": {}, "so": {}, "ate": {}, "isso": {}, "isto": {}, "esse": {},
+	"essa": {}, "este": {}, "esta": {}, "ele": {}, "ela": {}, "eles": {}, "elas": {}, "eu": {}, "voce": {},
+}
+
+// Fold returns the comparable form of s: accents are stripped after the NFD
+// decomposition, the result is recomposed with NFC, lower-cased and its
+// whitespace collapsed. "Medo e Delírio em  BRASÍLIA" becomes
+// "medo e delirio em brasilia".
+func Fold(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(stripAccents(s))), " ")
+}
+
+// ASCII strips the accents of s, keeping its case, and replaces whatever
+// is still not printable ASCII with "_". "Ação: 1º" becomes "Acao: 1_".
+func ASCII(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || r > '~' {
+			return '_'
+		}
+
+		return r
+	}, stripAccents(s))
+}
+
+// stripAccents removes the marks left by the NFD decomposition and
+// recomposes the rest with NFC.
+func stripAccents(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+
+	stripped, _, err := transform.String(t, s)
+
+	if err != nil {
+		return s
+	}
+
+	return stripped
+}
+
+// Tokens folds s, splits it on anything that is not a letter or a digit and
+// drops the stop words.
+func Tokens(s string) []string {
+	fields := strings.FieldsFunc(Fold(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	tokens := make([]string, 0, len(fields))
+
+	for _, field := range fields {
+		if _, ok := stopWords[field]; ok {
+			continue
+		}
+
+		tokens = append(tokens, field)
+	}
+
+	return tokens
+}
@@ -0,0 +1,49 @@
+package normalize
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFold(t *testing.T) {
+	cases := map[string]string{
+		"Medo e delírio em Brasília": "medo e delirio em brasilia",
+		"BRASILIA":                   "brasilia",
+		"  Ação   Coração ":          "acao coracao",
+		"Pinguim":                    "pinguim",
+		"Bras\u0069\u0301lia":        "brasilia",
+	}
+
+	for input, expected := range cases {
+		result := Fold(input)
+
+		if result != expected {
+			t.Errorf("The result is different from expected. Input: %v. Result: %v. Expected: %v", input, result, expected)
+		}
+	}
+}
+
+func TestASCII(t *testing.T) {
+	cases := map[string]string{
+		"Delírio em Brasília": "Delirio em Brasilia",
+		"Ação: 1º":            "Acao: 1_",
+		"Tab\there":           "Tab_here",
+	}
+
+	for input, expected := range cases {
+		result := ASCII(input)
+
+		if result != expected {
+			t.Errorf("The result is different from expected. Input: %v. Result: %v. Expected: %v", input, result, expected)
+		}
+	}
+}
+
+func TestTokensRemovesStopWords(t *testing.T) {
+	result := Tokens("Medo e Delírio em Brasília, é isso!")
+	expected := []string{"medo", "delirio", "brasilia"}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", result, expected)
+	}
+}
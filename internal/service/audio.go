@@ -2,57 +2,289 @@ package service
 
 import (
 	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"path"
+	"strings"
+	"time"
 
+	"github.com/LucasAndFlores/go_lambdas_project/internal/audio"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/normalize"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 )
 
 var BUCKET_NAME = os.Getenv("BUCKET_NAME")
 
+// ADMIN_TOKEN is the secret that lets a request replace stored audio. When
+// empty, nobody can.
+var ADMIN_TOKEN = os.Getenv("ADMIN_TOKEN")
+
+var AudioNotFoundErr = errors.New("Audio not found")
+
+// URL_EXPIRES is how long the URLs handed out by a function last, such as
+// "15m" or "24h". Each function sets its own.
+var URL_EXPIRES = os.Getenv("URL_EXPIRES")
+
+// MAX_URL_EXPIRY is the longest a pre-signed URL can last on S3.
+const MAX_URL_EXPIRY = 7 * 24 * time.Hour
+
+// formatTypes maps the formats found by the probe to the content type and
+// extension the audio is downloaded with.
+var formatTypes = map[string]struct {
+	contentType string
+	extension   string
+}{
+	string(audio.FormatMP3): {"audio/mpeg", ".mp3"},
+	string(audio.FormatOGG): {"audio/ogg", ".ogg"},
+	string(audio.FormatWAV): {"audio/wav", ".wav"},
+	string(audio.FormatM4A): {"audio/mp4", ".m4a"},
+}
+
+// ARCHIVE_PREFIX holds the audio replaced by an admin, under its id and the
+// time it was uploaded, so a replacement can be undone.
+const ARCHIVE_PREFIX = "archive/"
+
 const (
+	ID       = "id"
 	FILENAME = "filename"
 	AUTHOR   = "author"
 	LABEL    = "label"
 	TYPE     = "type"
 	WORDS    = "words"
+
+	AUTHOR_NORMALIZED = "author_normalized"
+	LABEL_NORMALIZED  = "label_normalized"
 )
 
 type S3URLPresigner interface {
 	PresignGetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
 	PresignPutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
+	PresignUploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
 }
 
 type AudioService struct {
 	s3PresignedAPI S3URLPresigner
+	s3             S3Bucket
+	dynamo         DynamoDB
 }
 
 type IAudioService interface {
-	GeneratePreSignedPutURL(filename string, ctx context.Context) (string, error)
-	GeneratePreSignedGetURL(filename string, ctx context.Context) (string, error)
+	HeadAudio(id string, ctx context.Context) (dto.AudioObjectDTO, error)
+	GeneratePreSignedPutURL(id string, object dto.AudioDTOInput, options dto.PresignOptions, ctx context.Context) (dto.PresignedPutDTO, error)
+	GeneratePreSignedReplaceURL(id string, object dto.AudioDTOInput, options dto.PresignOptions, ctx context.Context) (dto.PresignedPutDTO, error)
+	GeneratePreSignedGetURL(id string, options dto.PresignOptions, ctx context.Context) (string, error)
+	CreateMultipartUpload(ctx context.Context, size int64, options dto.PresignOptions) (dto.MultipartDTOOutput, error)
+	GetMultipartUpload(ctx context.Context, id string, options dto.PresignOptions) (dto.MultipartDTOOutput, error)
+	RecordPart(ctx context.Context, id string, number int32, etag string) error
+	CompleteMultipartUpload(ctx context.Context, id string) error
+	AbortMultipartUpload(ctx context.Context, id string) error
 }
 
-func NewAudioService(s S3URLPresigner) IAudioService {
+func NewAudioService(s S3URLPresigner, b S3Bucket, d DynamoDB) IAudioService {
 	return &AudioService{
 		s3PresignedAPI: s,
+		s3:             b,
+		dynamo:         d,
+	}
+}
+
+// HeadAudio checks the audio is stored before a URL to it is handed out,
+// since S3 signs URLs to keys that don't exist just the same.
+func (s *AudioService) HeadAudio(id string, ctx context.Context) (dto.AudioObjectDTO, error) {
+	head, err := s.head(ctx, id)
+
+	if err != nil {
+		return dto.AudioObjectDTO{}, err
+	}
+
+	return dto.AudioObjectDTO{
+		Size:         aws.ToInt64(head.ContentLength),
+		LastModified: aws.ToTime(head.LastModified),
+	}, nil
+}
+
+func (s *AudioService) head(ctx context.Context, id string) (*s3.HeadObjectOutput, error) {
+	head, err := s.s3.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(BUCKET_NAME),
+		Key:    aws.String(id),
+	})
+
+	if err != nil {
+		var notFound *s3types.NotFound
+
+		if errors.As(err, &notFound) {
+			return nil, AudioNotFoundErr
+		}
+
+		log.Printf("Error getting object %s/%s: %s", BUCKET_NAME, id, err.Error())
+		return nil, err
 	}
+
+	return head, nil
 }
 
-func (s *AudioService) GeneratePreSignedPutURL(filename string, ctx context.Context) (string, error) {
-	request, err := s.s3PresignedAPI.PresignPutObject(ctx, &s3.PutObjectInput{Bucket: aws.String(BUCKET_NAME), Key: aws.String(filename)})
+// GeneratePreSignedPutURL signs the declared content type, size and SHA-256
+// of the object along with its key, so S3 refuses an upload that doesn't
+// match them. Fields left empty aren't pinned. A key already holding an
+// object or metadata gives ConfilctErr, and the URL is signed with
+// If-None-Match so S3 refuses to overwrite one that shows up after it was
+// handed out.
+func (s *AudioService) GeneratePreSignedPutURL(id string, object dto.AudioDTOInput, options dto.PresignOptions, ctx context.Context) (dto.PresignedPutDTO, error) {
+	_, err := s.head(ctx, id)
+
+	if err == nil {
+		return dto.PresignedPutDTO{}, ConfilctErr
+	}
+
+	if !errors.Is(err, AudioNotFoundErr) {
+		return dto.PresignedPutDTO{}, err
+	}
+
+	// The key can hold metadata without audio, which the HEAD doesn't see.
+	output, err := s.dynamo.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:                aws.String(DYNAMO_TABLE),
+		Key:                      map[string]types.AttributeValue{ID: &types.AttributeValueMemberS{Value: id}},
+		ProjectionExpression:     aws.String("#id"),
+		ExpressionAttributeNames: map[string]string{"#id": ID},
+	})
+
+	if err != nil {
+		log.Printf("Error when tried to getItem from dynamoDB: %s", err)
+		return dto.PresignedPutDTO{}, err
+	}
+
+	if output.Item != nil {
+		return dto.PresignedPutDTO{}, ConfilctErr
+	}
+
+	return s.presignPut(ctx, id, object, options, withHeader("If-None-Match", "*"))
+}
+
+// GeneratePreSignedReplaceURL copies the audio stored under id to
+// ARCHIVE_PREFIX before signing a URL that overwrites it. The URL is signed
+// with If-Match on the archived version, so it can't overwrite a version
+// that was never archived. The archive is recorded on the upload, for
+// ProcessUpload to tell the replacement apart and to restore the archive if
+// the replacement is rejected. An audio whose upload isn't finished gives
+// FileNotFoundErr.
+func (s *AudioService) GeneratePreSignedReplaceURL(id string, object dto.AudioDTOInput, options dto.PresignOptions, ctx context.Context) (dto.PresignedPutDTO, error) {
+	head, err := s.head(ctx, id)
+
+	if err != nil {
+		return dto.PresignedPutDTO{}, err
+	}
+
+	key := ArchiveKey(id, aws.ToTime(head.LastModified))
+
+	_, err = s.s3.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:            aws.String(BUCKET_NAME),
+		CopySource:        aws.String(BUCKET_NAME + "/" + id),
+		CopySourceIfMatch: head.ETag,
+		Key:               aws.String(key),
+	})
+
+	if err != nil {
+		log.Printf("Error copying object %s/%s to %s: %s", BUCKET_NAME, id, key, err.Error())
+		return dto.PresignedPutDTO{}, err
+	}
+
+	_, err = s.dynamo.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(UPLOADS_TABLE),
+		Key: map[string]types.AttributeValue{
+			ID: &types.AttributeValueMemberS{Value: id},
+		},
+		UpdateExpression:         aws.String("SET #replacing = :archive"),
+		ConditionExpression:      aws.String("#status IN (:uploaded, :published)"),
+		ExpressionAttributeNames: map[string]string{"#replacing": REPLACING, "#status": STATUS},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":archive":   &types.AttributeValueMemberS{Value: key},
+			":uploaded":  &types.AttributeValueMemberS{Value: UPLOAD_UPLOADED},
+			":published": &types.AttributeValueMemberS{Value: UPLOAD_PUBLISHED},
+		},
+	})
+
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+
+		if errors.As(err, &conditionErr) {
+			return dto.PresignedPutDTO{}, FileNotFoundErr
+		}
+
+		log.Printf("An error occurred when tried to record the replacement of %s. Error: %v", id, err)
+		return dto.PresignedPutDTO{}, err
+	}
+
+	return s.presignPut(ctx, id, object, options, withHeader("If-Match", aws.ToString(head.ETag)))
+}
+
+func (s *AudioService) presignPut(ctx context.Context, id string, object dto.AudioDTOInput, options dto.PresignOptions, condition func(*s3.PresignOptions)) (dto.PresignedPutDTO, error) {
+	input := &s3.PutObjectInput{Bucket: aws.String(BUCKET_NAME), Key: aws.String(id)}
+
+	if object.ContentType != "" {
+		input.ContentType = aws.String(object.ContentType)
+	}
+
+	if object.Size > 0 {
+		input.ContentLength = aws.Int64(object.Size)
+	}
+
+	if object.SHA256 != "" {
+		digest, err := hex.DecodeString(object.SHA256)
+
+		if err != nil {
+			return dto.PresignedPutDTO{}, err
+		}
+
+		// S3 takes checksums in base64 rather than hex.
+		input.ChecksumSHA256 = aws.String(base64.StdEncoding.EncodeToString(digest))
+	}
+
+	request, err := s.s3PresignedAPI.PresignPutObject(ctx, input, expires(options), condition)
 
 	if err != nil {
 		log.Println("An error happened when tried to pre sign a PUT URL", err)
-		return "", err
+		return dto.PresignedPutDTO{}, err
 	}
 
-	return request.URL, nil
+	headers := map[string]string{}
+
+	// The host is the one of the URL, which clients send on their own.
+	for name := range request.SignedHeader {
+		if !strings.EqualFold(name, "Host") {
+			headers[name] = request.SignedHeader.Get(name)
+		}
+	}
+
+	return dto.PresignedPutDTO{Url: request.URL, Headers: headers}, nil
 }
 
-func (s *AudioService) GeneratePreSignedGetURL(filename string, ctx context.Context) (string, error) {
-	request, err := s.s3PresignedAPI.PresignGetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(BUCKET_NAME), Key: aws.String(filename)})
+// GeneratePreSignedGetURL can override the headers S3 serves the audio with,
+// so it is saved under a readable name and apps sharing the link see audio
+// rather than a file named after its key.
+func (s *AudioService) GeneratePreSignedGetURL(id string, options dto.PresignOptions, ctx context.Context) (string, error) {
+	input := &s3.GetObjectInput{Bucket: aws.String(BUCKET_NAME), Key: aws.String(id)}
+
+	if options.DownloadName != "" {
+		input.ResponseContentDisposition = aws.String(contentDisposition(options.DownloadName, options.Attachment))
+	}
+
+	if options.ContentType != "" {
+		input.ResponseContentType = aws.String(options.ContentType)
+	}
+
+	request, err := s.s3PresignedAPI.PresignGetObject(ctx, input, expires(options))
 
 	if err != nil {
 		log.Println("An error happened when tried to pre sign a GET URL", err)
@@ -61,3 +293,125 @@ func (s *AudioService) GeneratePreSignedGetURL(filename string, ctx context.Cont
 
 	return request.URL, nil
 }
+
+// withHeader signs a header the PUT object input of this SDK has no field
+// for, which the client then has to send as is.
+func withHeader(name string, value string) func(*s3.PresignOptions) {
+	return func(o *s3.PresignOptions) {
+		o.ClientOptions = append(o.ClientOptions, func(o *s3.Options) {
+			o.APIOptions = append(o.APIOptions, smithyhttp.AddHeaderValue(name, value))
+		})
+	}
+}
+
+func expires(options dto.PresignOptions) func(*s3.PresignOptions) {
+	return func(o *s3.PresignOptions) {
+		if options.Expires > 0 {
+			o.Expires = options.Expires
+		}
+	}
+}
+
+// IsAdmin reports whether token is the ADMIN_TOKEN.
+func IsAdmin(token string) bool {
+	return ADMIN_TOKEN != "" && subtle.ConstantTimeCompare([]byte(token), []byte(ADMIN_TOKEN)) == 1
+}
+
+// AdminToken reads the X-Admin-Token header, whose case depends on the
+// client.
+func AdminToken(headers map[string]string) string {
+	for name, value := range headers {
+		if strings.EqualFold(name, "X-Admin-Token") {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// ArchiveKey is where the audio uploaded under id at uploaded is archived.
+func ArchiveKey(id string, uploaded time.Time) string {
+	return ARCHIVE_PREFIX + id + "/" + uploaded.UTC().Format("20060102T150405Z")
+}
+
+// IsArchived tells the archived audio apart, as archiving creates objects
+// too.
+func IsArchived(key string) bool {
+	return strings.HasPrefix(key, ARCHIVE_PREFIX)
+}
+
+// URLExpiry reads URL_EXPIRES, returning zero, the default of the SDK, when
+// it is unset.
+func URLExpiry() (time.Duration, error) {
+	if URL_EXPIRES == "" {
+		return 0, nil
+	}
+
+	expiry, err := time.ParseDuration(URL_EXPIRES)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if expiry <= 0 || expiry > MAX_URL_EXPIRY {
+		return 0, fmt.Errorf("URL_EXPIRES should be between 0 and %s, not %s", MAX_URL_EXPIRY, expiry)
+	}
+
+	return expiry, nil
+}
+
+// DownloadOptions name the download after the label of the insertion, with
+// the extension and content type of its format. Metadata published before
+// the format was kept falls back to the extension of its filename.
+func DownloadOptions(metadata dto.MetadataDTOOutput, expires time.Duration) dto.PresignOptions {
+	options := dto.PresignOptions{Expires: expires}
+
+	name := strings.TrimSpace(metadata.Label)
+
+	if name == "" {
+		return options
+	}
+
+	if format, ok := formatTypes[metadata.Format]; ok {
+		options.DownloadName = name + format.extension
+		options.ContentType = format.contentType
+	} else {
+		options.DownloadName = name + path.Ext(metadata.FileName)
+	}
+
+	return options
+}
+
+// contentDisposition gives the name as plain ASCII for old clients and in
+// full, percent-encoded as RFC 6266 asks, for the others.
+func contentDisposition(name string, attachment bool) string {
+	disposition := "inline"
+
+	if attachment {
+		disposition = "attachment"
+	}
+
+	fallback := strings.NewReplacer(`"`, "_", `\`, "_").Replace(normalize.ASCII(name))
+
+	var encoded strings.Builder
+
+	for _, b := range []byte(name) {
+		if isAttrChar(b) {
+			encoded.WriteByte(b)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+
+	return fmt.Sprintf(`%s; filename="%s"; filename*=UTF-8''%s`, disposition, fallback, encoded.String())
+}
+
+// isAttrChar reports whether b can be left as is in an RFC 5987 value.
+func isAttrChar(b byte) bool {
+	switch {
+	case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9':
+		return true
+	}
+
+	return strings.IndexByte("!#$&+-.^_`|~", b) >= 0
+}
@@ -4,13 +4,60 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
 	"github.com/LucasAndFlores/go_lambdas_project/internal/mocks"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
+// missingObjectS3 is a bucket where no key holds an object yet.
+func missingObjectS3() mocks.MockedS3 {
+	mockedS3 := mocks.MockedS3{}
+
+	mockedS3.HeadObjectFuncMock = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		return nil, &s3types.NotFound{}
+	}
+
+	return mockedS3
+}
+
+// uploadedDynamoDB has no metadata and records replacements.
+func uploadedDynamoDB() mocks.MockedDynamoDB {
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedDynamodb.GetItemFuncMock = func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+
+	mockedDynamodb.UpdateItemFuncMock = func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		return &dynamodb.UpdateItemOutput{}, nil
+	}
+
+	return mockedDynamodb
+}
+
+// presignPut signs params with a real client, to tell which headers the
+// options passed to the presigner end up signing.
+func presignPut(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+	client := s3.New(s3.Options{
+		Region: "us-east-1",
+		Credentials: aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "key", SecretAccessKey: "secret"}, nil
+		}),
+	})
+
+	return s3.NewPresignClient(client).PresignPutObject(ctx, params, optFns...)
+}
+
 func TestGeneratePreSignedPutURLSuccessfulResponse(t *testing.T) {
 	expected := "test.com/audio.mp3"
 
@@ -20,18 +67,49 @@ func TestGeneratePreSignedPutURLSuccessfulResponse(t *testing.T) {
 		return &v4.PresignedHTTPRequest{URL: expected, SignedHeader: http.Header{}, Method: "PUT"}, nil
 	}
 
-	serviceHandler := NewAudioService(preSigned)
+	serviceHandler := NewAudioService(preSigned, missingObjectS3(), uploadedDynamoDB())
 
 	filename := "audio"
 
-	url, err := serviceHandler.GeneratePreSignedPutURL(filename, context.TODO())
+	presigned, err := serviceHandler.GeneratePreSignedPutURL(filename, dto.AudioDTOInput{}, dto.PresignOptions{}, context.TODO())
 
 	if err != nil {
 		t.Errorf("An error occurred when tried to test sucesss scenario. Result: %v, Expected: %v", err.Error(), expected)
 	}
 
-	if url != expected {
-		t.Errorf("The result is different from the expected. Result: %v, Expected: %v", url, expected)
+	if presigned.Url != expected {
+		t.Errorf("The result is different from the expected. Result: %v, Expected: %v", presigned.Url, expected)
+	}
+}
+
+func TestGeneratePreSignedPutURLPinsDeclaredObject(t *testing.T) {
+	preSigned := mocks.MockedPresignedClient{}
+
+	preSigned.PresignPutObjectFuncMock = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+		if aws.ToString(params.ContentType) != "audio/mpeg" || aws.ToInt64(params.ContentLength) != 4096 {
+			t.Errorf("The result is different from the expected. Result: %v %v, Expected: %v %v", aws.ToString(params.ContentType), aws.ToInt64(params.ContentLength), "audio/mpeg", 4096)
+		}
+
+		// The SHA-256 of an empty body, in base64.
+		expected := "47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+
+		if aws.ToString(params.ChecksumSHA256) != expected {
+			t.Errorf("The result is different from the expected. Result: %v, Expected: %v", aws.ToString(params.ChecksumSHA256), expected)
+		}
+
+		return &v4.PresignedHTTPRequest{URL: "test.com/audio.mp3", SignedHeader: http.Header{}, Method: "PUT"}, nil
+	}
+
+	serviceHandler := NewAudioService(preSigned, missingObjectS3(), uploadedDynamoDB())
+
+	_, err := serviceHandler.GeneratePreSignedPutURL("audio", dto.AudioDTOInput{
+		ContentType: "audio/mpeg",
+		Size:        4096,
+		SHA256:      "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+	}, dto.PresignOptions{}, context.TODO())
+
+	if err != nil {
+		t.Errorf("The result is different from the expected. Expected nil. Result: %v", err)
 	}
 }
 
@@ -44,14 +122,14 @@ func TestGeneratePreSignedPutURLErrorFromAWS(t *testing.T) {
 		return nil, awsErr
 	}
 
-	serviceHandler := NewAudioService(preSigned)
+	serviceHandler := NewAudioService(preSigned, missingObjectS3(), uploadedDynamoDB())
 
 	filename := "audio"
 
-	url, err := serviceHandler.GeneratePreSignedPutURL(filename, context.TODO())
+	presigned, err := serviceHandler.GeneratePreSignedPutURL(filename, dto.AudioDTOInput{}, dto.PresignOptions{}, context.TODO())
 
-	if url != "" {
-		t.Errorf("An error occurred when tried to test error scenario. Result: %v, Expected: %v", url, nil)
+	if presigned.Url != "" {
+		t.Errorf("An error occurred when tried to test error scenario. Result: %v, Expected: %v", presigned.Url, nil)
 	}
 
 	if err.Error() != awsErr.Error() {
@@ -68,11 +146,11 @@ func TestGeneratePreSignedGetURLSuccessfulResponse(t *testing.T) {
 		return &v4.PresignedHTTPRequest{URL: expected, SignedHeader: http.Header{}, Method: "GET"}, nil
 	}
 
-	serviceHandler := NewAudioService(preSigned)
+	serviceHandler := NewAudioService(preSigned, missingObjectS3(), uploadedDynamoDB())
 
 	filename := "audio"
 
-	url, err := serviceHandler.GeneratePreSignedGetURL(filename, context.TODO())
+	url, err := serviceHandler.GeneratePreSignedGetURL(filename, dto.PresignOptions{}, context.TODO())
 
 	if err != nil {
 		t.Errorf("An error occurred when tried to test sucesss scenario. Result: %v, Expected: %v", err.Error(), expected)
@@ -92,11 +170,11 @@ func TestGeneratePreSignedGetURLErrorResponse(t *testing.T) {
 		return nil, awsErr
 	}
 
-	serviceHandler := NewAudioService(preSigned)
+	serviceHandler := NewAudioService(preSigned, missingObjectS3(), uploadedDynamoDB())
 
 	filename := "audio"
 
-	url, err := serviceHandler.GeneratePreSignedGetURL(filename, context.TODO())
+	url, err := serviceHandler.GeneratePreSignedGetURL(filename, dto.PresignOptions{}, context.TODO())
 
 	if url != "" {
 		t.Errorf("An error occurred when tried to test error scenario. Result: %v, Expected: %v", url, nil)
@@ -107,3 +185,320 @@ func TestGeneratePreSignedGetURLErrorResponse(t *testing.T) {
 	}
 
 }
+
+func TestGeneratePreSignedGetURLOverridesDownload(t *testing.T) {
+	preSigned := mocks.MockedPresignedClient{}
+
+	preSigned.PresignGetObjectFuncMock = func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+		expected := `attachment; filename="Promocao de Verao.mp3"; filename*=UTF-8''Promo%C3%A7%C3%A3o%20de%20Ver%C3%A3o.mp3`
+
+		if aws.ToString(params.ResponseContentDisposition) != expected {
+			t.Errorf("The result is different from the expected. Result: %v, Expected: %v", aws.ToString(params.ResponseContentDisposition), expected)
+		}
+
+		if aws.ToString(params.ResponseContentType) != "audio/mpeg" {
+			t.Errorf("The result is different from the expected. Result: %v, Expected: %v", aws.ToString(params.ResponseContentType), "audio/mpeg")
+		}
+
+		options := s3.PresignOptions{}
+
+		for _, fn := range optFns {
+			fn(&options)
+		}
+
+		if options.Expires != time.Hour {
+			t.Errorf("The result is different from the expected. Result: %v, Expected: %v", options.Expires, time.Hour)
+		}
+
+		return &v4.PresignedHTTPRequest{URL: "test.com/audio.mp3", SignedHeader: http.Header{}, Method: "GET"}, nil
+	}
+
+	serviceHandler := NewAudioService(preSigned, missingObjectS3(), uploadedDynamoDB())
+
+	options := DownloadOptions(dto.MetadataDTOOutput{Label: "Promoção de Verão", FileName: "promo.wav", Format: "mp3"}, time.Hour)
+	options.Attachment = true
+
+	_, err := serviceHandler.GeneratePreSignedGetURL("audio", options, context.TODO())
+
+	if err != nil {
+		t.Errorf("The result is different from the expected. Expected nil. Result: %v", err)
+	}
+}
+
+func TestDownloadOptions(t *testing.T) {
+	cases := map[string]struct {
+		metadata dto.MetadataDTOOutput
+		expected dto.PresignOptions
+	}{
+		"format": {
+			dto.MetadataDTOOutput{Label: "Jingle", FileName: "jingle.bin", Format: "ogg"},
+			dto.PresignOptions{DownloadName: "Jingle.ogg", ContentType: "audio/ogg"},
+		},
+		"filename": {
+			dto.MetadataDTOOutput{Label: "Jingle", FileName: "jingle.mp3"},
+			dto.PresignOptions{DownloadName: "Jingle.mp3"},
+		},
+		"no label": {
+			dto.MetadataDTOOutput{FileName: "jingle.mp3", Format: "mp3"},
+			dto.PresignOptions{},
+		},
+	}
+
+	for name, c := range cases {
+		result := DownloadOptions(c.metadata, 0)
+
+		if result != c.expected {
+			t.Errorf("%s: The result is different from the expected. Result: %+v, Expected: %+v", name, result, c.expected)
+		}
+	}
+}
+
+func TestBatchLimit(t *testing.T) {
+	defer func(value string) { BATCH_LIMIT = value }(BATCH_LIMIT)
+
+	cases := map[string]int{"": DEFAULT_BATCH_LIMIT, "1": 1, "100": MAX_BATCH_LIMIT}
+
+	for value, expected := range cases {
+		BATCH_LIMIT = value
+
+		result, err := BatchLimit()
+
+		if err != nil || result != expected {
+			t.Errorf("The result is different from the expected. Result: %v %v, Expected: %v", result, err, expected)
+		}
+	}
+
+	for _, value := range []string{"many", "0", "-5", "101"} {
+		BATCH_LIMIT = value
+
+		if _, err := BatchLimit(); err == nil {
+			t.Errorf("The limit %q should be invalid", value)
+		}
+	}
+}
+
+func TestURLExpiry(t *testing.T) {
+	defer func(value string) { URL_EXPIRES = value }(URL_EXPIRES)
+
+	cases := map[string]time.Duration{"": 0, "15m": 15 * time.Minute, "168h": MAX_URL_EXPIRY}
+
+	for value, expected := range cases {
+		URL_EXPIRES = value
+
+		result, err := URLExpiry()
+
+		if err != nil || result != expected {
+			t.Errorf("The result is different from the expected. Result: %v %v, Expected: %v", result, err, expected)
+		}
+	}
+
+	for _, value := range []string{"soon", "-1m", "0s", "169h"} {
+		URL_EXPIRES = value
+
+		if _, err := URLExpiry(); err == nil {
+			t.Errorf("The expiry %q should be invalid", value)
+		}
+	}
+}
+
+func TestHeadAudioSuccessfulResponse(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	modified := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	mockedS3.HeadObjectFuncMock = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		return &s3.HeadObjectOutput{ContentLength: aws.Int64(4096), LastModified: aws.Time(modified)}, nil
+	}
+
+	serviceHandler := NewAudioService(mocks.MockedPresignedClient{}, mockedS3, mocks.MockedDynamoDB{})
+
+	object, err := serviceHandler.HeadAudio("audio", context.TODO())
+
+	if err != nil {
+		t.Errorf("The result is different from the expected. Expected nil. Result: %v", err)
+	}
+
+	expected := dto.AudioObjectDTO{Size: 4096, LastModified: modified}
+
+	if object != expected {
+		t.Errorf("The result is different from the expected. Result: %+v, Expected: %+v", object, expected)
+	}
+}
+
+func TestHeadAudioNotFound(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+
+	mockedS3.HeadObjectFuncMock = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		return nil, &s3types.NotFound{}
+	}
+
+	serviceHandler := NewAudioService(mocks.MockedPresignedClient{}, mockedS3, mocks.MockedDynamoDB{})
+
+	_, err := serviceHandler.HeadAudio("audio", context.TODO())
+
+	if !errors.Is(err, AudioNotFoundErr) {
+		t.Errorf("The result is different from the expected. Result: %v, Expected: %v", err, AudioNotFoundErr)
+	}
+}
+
+func TestGeneratePreSignedPutURLRefusesExistingKey(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+
+	mockedS3.HeadObjectFuncMock = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		return &s3.HeadObjectOutput{}, nil
+	}
+
+	serviceHandler := NewAudioService(mocks.MockedPresignedClient{}, mockedS3, mocks.MockedDynamoDB{})
+
+	_, err := serviceHandler.GeneratePreSignedPutURL("audio", dto.AudioDTOInput{}, dto.PresignOptions{}, context.TODO())
+
+	if !errors.Is(err, ConfilctErr) {
+		t.Errorf("The result is different from the expected. Result: %v, Expected: %v", err, ConfilctErr)
+	}
+}
+
+func TestGeneratePreSignedPutURLSignsIfNoneMatch(t *testing.T) {
+	preSigned := mocks.MockedPresignedClient{PresignPutObjectFuncMock: presignPut}
+
+	serviceHandler := NewAudioService(preSigned, missingObjectS3(), uploadedDynamoDB())
+
+	presigned, err := serviceHandler.GeneratePreSignedPutURL("audio", dto.AudioDTOInput{}, dto.PresignOptions{}, context.TODO())
+
+	if err != nil {
+		t.Errorf("The result is different from the expected. Expected nil. Result: %v", err)
+	}
+
+	if !strings.Contains(presigned.Url, "X-Amz-SignedHeaders=host%3Bif-none-match") {
+		t.Errorf("The URL should sign If-None-Match. Result: %v", presigned.Url)
+	}
+
+	expected := map[string]string{"If-None-Match": "*"}
+
+	if !reflect.DeepEqual(presigned.Headers, expected) {
+		t.Errorf("The result is different from the expected. Result: %v, Expected: %v", presigned.Headers, expected)
+	}
+}
+
+func TestGeneratePreSignedReplaceURLArchivesFirst(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+	uploaded := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	var archived *s3.CopyObjectInput
+	var recorded string
+
+	mockedS3.HeadObjectFuncMock = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		return &s3.HeadObjectOutput{ETag: aws.String(`"etag"`), LastModified: aws.Time(uploaded)}, nil
+	}
+
+	mockedS3.CopyObjectFuncMock = func(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+		archived = params
+		return &s3.CopyObjectOutput{}, nil
+	}
+
+	mockedDynamodb.UpdateItemFuncMock = func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		recorded = params.ExpressionAttributeValues[":archive"].(*types.AttributeValueMemberS).Value
+		return &dynamodb.UpdateItemOutput{}, nil
+	}
+
+	preSigned := mocks.MockedPresignedClient{}
+
+	preSigned.PresignPutObjectFuncMock = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+		if archived == nil || recorded == "" {
+			t.Errorf("The audio should be archived before the URL is signed")
+		}
+
+		return presignPut(ctx, params, optFns...)
+	}
+
+	serviceHandler := NewAudioService(preSigned, mockedS3, mockedDynamodb)
+
+	presigned, err := serviceHandler.GeneratePreSignedReplaceURL("audio", dto.AudioDTOInput{ContentType: "audio/mpeg", Size: 4096}, dto.PresignOptions{}, context.TODO())
+
+	if err != nil {
+		t.Errorf("The result is different from the expected. Expected nil. Result: %v", err)
+	}
+
+	expected := "archive/audio/20240301T120000Z"
+
+	if archived == nil || *archived.Key != expected || aws.ToString(archived.CopySourceIfMatch) != `"etag"` {
+		t.Errorf("The result is different from the expected. Result: %+v, Expected: %v", archived, expected)
+	}
+
+	if recorded != expected {
+		t.Errorf("The result is different from the expected. Result: %v, Expected: %v", recorded, expected)
+	}
+
+	headers := map[string]string{"Content-Length": "4096", "Content-Type": "audio/mpeg", "If-Match": `"etag"`}
+
+	if !reflect.DeepEqual(presigned.Headers, headers) {
+		t.Errorf("The result is different from the expected. Result: %v, Expected: %v", presigned.Headers, headers)
+	}
+}
+
+func TestGeneratePreSignedReplaceURLUploadNotFinished(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedS3.HeadObjectFuncMock = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		return &s3.HeadObjectOutput{ETag: aws.String(`"etag"`)}, nil
+	}
+
+	mockedS3.CopyObjectFuncMock = func(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+		return &s3.CopyObjectOutput{}, nil
+	}
+
+	mockedDynamodb.UpdateItemFuncMock = func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+
+	serviceHandler := NewAudioService(mocks.MockedPresignedClient{}, mockedS3, mockedDynamodb)
+
+	_, err := serviceHandler.GeneratePreSignedReplaceURL("audio", dto.AudioDTOInput{}, dto.PresignOptions{}, context.TODO())
+
+	if !errors.Is(err, FileNotFoundErr) {
+		t.Errorf("The result is different from the expected. Result: %v, Expected: %v", err, FileNotFoundErr)
+	}
+}
+
+func TestGeneratePreSignedPutURLRefusesKeyWithMetadata(t *testing.T) {
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedDynamodb.GetItemFuncMock = func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+		return &dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+			ID: &types.AttributeValueMemberS{Value: "audio"},
+		}}, nil
+	}
+
+	serviceHandler := NewAudioService(mocks.MockedPresignedClient{}, missingObjectS3(), mockedDynamodb)
+
+	_, err := serviceHandler.GeneratePreSignedPutURL("audio", dto.AudioDTOInput{}, dto.PresignOptions{}, context.TODO())
+
+	if !errors.Is(err, ConfilctErr) {
+		t.Errorf("The result is different from the expected. Result: %v, Expected: %v", err, ConfilctErr)
+	}
+}
+
+func TestGeneratePreSignedReplaceURLAudioNotFound(t *testing.T) {
+	serviceHandler := NewAudioService(mocks.MockedPresignedClient{}, missingObjectS3(), mocks.MockedDynamoDB{})
+
+	_, err := serviceHandler.GeneratePreSignedReplaceURL("audio", dto.AudioDTOInput{}, dto.PresignOptions{}, context.TODO())
+
+	if !errors.Is(err, AudioNotFoundErr) {
+		t.Errorf("The result is different from the expected. Result: %v, Expected: %v", err, AudioNotFoundErr)
+	}
+}
+
+func TestIsAdmin(t *testing.T) {
+	defer func(value string) { ADMIN_TOKEN = value }(ADMIN_TOKEN)
+
+	ADMIN_TOKEN = ""
+
+	if IsAdmin("") {
+		t.Errorf("Nobody should be an admin without ADMIN_TOKEN")
+	}
+
+	ADMIN_TOKEN = "secret"
+
+	if !IsAdmin("secret") || IsAdmin("secreT") || IsAdmin("") {
+		t.Errorf("Only the ADMIN_TOKEN should be accepted")
+	}
+}
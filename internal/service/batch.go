@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	MAX_BATCH_WRITE_SIZE = 25
+	MAX_BATCH_GET_SIZE   = 100
+	MAX_BATCH_RETRIES    = 5
+)
+
+// BATCH_LIMIT is how many items the batch routes take at once. Each function
+// sets its own, from 1 to MAX_BATCH_LIMIT.
+var BATCH_LIMIT = os.Getenv("BATCH_LIMIT")
+
+const (
+	DEFAULT_BATCH_LIMIT = 25
+	MAX_BATCH_LIMIT     = 100
+)
+
+// The status of each item of a batch.
+const (
+	BATCH_CREATED        = "created"
+	BATCH_INVALID        = "invalid"
+	BATCH_CONFLICT       = "conflict"
+	BATCH_FILE_NOT_FOUND = "file-not-found"
+	BATCH_FAILED         = "failed"
+)
+
+var UnprocessedItemsErr = errors.New("DynamoDB left items unprocessed after all retries")
+
+// BatchLimit reads BATCH_LIMIT, returning DEFAULT_BATCH_LIMIT when it is
+// unset.
+func BatchLimit() (int, error) {
+	if BATCH_LIMIT == "" {
+		return DEFAULT_BATCH_LIMIT, nil
+	}
+
+	limit, err := strconv.Atoi(BATCH_LIMIT)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if limit < 1 || limit > MAX_BATCH_LIMIT {
+		return 0, fmt.Errorf("BATCH_LIMIT should be between 1 and %d, not %d", MAX_BATCH_LIMIT, limit)
+	}
+
+	return limit, nil
+}
+
+var batchRetryDelay = 50 * time.Millisecond
+
+// batchWrite sends the requests in chunks of MAX_BATCH_WRITE_SIZE, resending
+// UnprocessedItems with exponential backoff.
+func batchWrite(ctx context.Context, dynamo DynamoDB, table string, requests []types.WriteRequest) error {
+	for start := 0; start < len(requests); start += MAX_BATCH_WRITE_SIZE {
+		end := min(start+MAX_BATCH_WRITE_SIZE, len(requests))
+
+		pending := map[string][]types.WriteRequest{table: requests[start:end]}
+
+		for attempt := 0; len(pending) > 0; attempt++ {
+			if attempt > MAX_BATCH_RETRIES {
+				return UnprocessedItemsErr
+			}
+
+			if attempt > 0 {
+				time.Sleep(batchRetryDelay << (attempt - 1))
+			}
+
+			output, err := dynamo.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: pending})
+
+			if err != nil {
+				log.Printf("Error when trying to use batchWriteItem method: %s", err)
+				return err
+			}
+
+			pending = output.UnprocessedItems
+		}
+	}
+
+	return nil
+}
+
+// batchGet reads the keys in chunks of MAX_BATCH_GET_SIZE, resending
+// UnprocessedKeys with exponential backoff. Missing items are simply absent
+// from the result, which is in no particular order.
+func batchGet(ctx context.Context, dynamo DynamoDB, table string, keys []map[string]types.AttributeValue) ([]map[string]types.AttributeValue, error) {
+	var items []map[string]types.AttributeValue
+
+	for start := 0; start < len(keys); start += MAX_BATCH_GET_SIZE {
+		end := min(start+MAX_BATCH_GET_SIZE, len(keys))
+
+		pending := map[string]types.KeysAndAttributes{table: {Keys: keys[start:end]}}
+
+		for attempt := 0; len(pending) > 0; attempt++ {
+			if attempt > MAX_BATCH_RETRIES {
+				return nil, UnprocessedItemsErr
+			}
+
+			if attempt > 0 {
+				time.Sleep(batchRetryDelay << (attempt - 1))
+			}
+
+			output, err := dynamo.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{RequestItems: pending})
+
+			if err != nil {
+				log.Printf("Error when trying to use batchGetItem method: %s", err)
+				return nil, err
+			}
+
+			items = append(items, output.Responses[table]...)
+			pending = output.UnprocessedKeys
+		}
+	}
+
+	return items, nil
+}
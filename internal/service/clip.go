@@ -0,0 +1,431 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LucasAndFlores/go_lambdas_project/internal/audio"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/entity"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/ulid"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+var CLIPS_TABLE = os.Getenv("CLIPS_TABLE")
+
+const (
+	// EPISODE_PREFIX keeps the episodes apart from the audio, as they are
+	// longer than any audio is allowed to be and never get metadata.
+	EPISODE_PREFIX = "episodes/"
+
+	CLIP_QUEUED = "queued"
+	CLIP_DONE   = "done"
+	CLIP_FAILED = "failed"
+
+	// MAX_TIMESTAMP keeps timestamps from overflowing, whichever unit they
+	// are given in.
+	MAX_TIMESTAMP = 1000 * time.Hour
+)
+
+var EpisodeNotFoundErr = errors.New("Episode not found")
+var ClipNotFoundErr = errors.New("Clip not found")
+var InvalidClipRangeErr = errors.New("The clip range is invalid")
+
+type ClipService struct {
+	s3       S3Bucket
+	dynamo   DynamoDB
+	uploads  IUploadService
+	metadata IMetadataService
+}
+
+type IClipService interface {
+	CreateClip(ctx context.Context, episodeID string, input dto.ClipDTOInput) (dto.ClipDTOOutput, error)
+	GetClip(ctx context.Context, id string) (dto.ClipDTOOutput, error)
+	CutClip(ctx context.Context, id string) error
+}
+
+func NewClipService(s S3Bucket, d DynamoDB) IClipService {
+	return &ClipService{
+		s3:       s,
+		dynamo:   d,
+		uploads:  NewUploadService(s, d),
+		metadata: NewMetadataService(s, d),
+	}
+}
+
+// CreateClip queues the cut of an episode between two timestamps. The clip
+// gets its ID right away, while the audio is cut by CutClip, which follows
+// the table stream.
+func (s *ClipService) CreateClip(ctx context.Context, episodeID string, input dto.ClipDTOInput) (dto.ClipDTOOutput, error) {
+	start, err := ParseTimestamp(input.Start)
+
+	if err != nil {
+		return dto.ClipDTOOutput{}, fmt.Errorf("%w: start %v", InvalidClipRangeErr, err)
+	}
+
+	end, err := ParseTimestamp(input.End)
+
+	if err != nil {
+		return dto.ClipDTOOutput{}, fmt.Errorf("%w: end %v", InvalidClipRangeErr, err)
+	}
+
+	if end <= start {
+		return dto.ClipDTOOutput{}, fmt.Errorf("%w: the end should come after the start", InvalidClipRangeErr)
+	}
+
+	if end-start > MAX_AUDIO_DURATION {
+		return dto.ClipDTOOutput{}, fmt.Errorf("%w: the clip should last at most %s", InvalidClipRangeErr, MAX_AUDIO_DURATION)
+	}
+
+	_, err = s.s3.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(BUCKET_NAME),
+		Key:    aws.String(EpisodeKey(episodeID)),
+	})
+
+	if err != nil {
+		var notFound *s3types.NotFound
+
+		if errors.As(err, &notFound) {
+			return dto.ClipDTOOutput{}, EpisodeNotFoundErr
+		}
+
+		log.Printf("Error getting object %s/%s: %s", BUCKET_NAME, EpisodeKey(episodeID), err.Error())
+		return dto.ClipDTOOutput{}, err
+	}
+
+	clip := entity.Clip{
+		ID:        ulid.New(),
+		EpisodeID: episodeID,
+		StartMs:   start.Milliseconds(),
+		EndMs:     end.Milliseconds(),
+		Status:    CLIP_QUEUED,
+		FileName:  input.FileName,
+		Author:    input.Author,
+		Label:     input.Label,
+		Type:      input.Type,
+		Words:     input.Words,
+	}
+
+	item, err := attributevalue.MarshalMap(clip)
+
+	if err != nil {
+		log.Printf("An error occurred when tried use attributevalue. Error: %v", err)
+		return dto.ClipDTOOutput{}, err
+	}
+
+	_, err = s.dynamo.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                aws.String(CLIPS_TABLE),
+		Item:                     item,
+		ConditionExpression:      aws.String("attribute_not_exists(#id)"),
+		ExpressionAttributeNames: map[string]string{"#id": ID},
+	})
+
+	if err != nil {
+		log.Printf("Error when trying to use putItem method: %s", err)
+		return dto.ClipDTOOutput{}, err
+	}
+
+	return clip.ConvertToDTO(), nil
+}
+
+func (s *ClipService) GetClip(ctx context.Context, id string) (dto.ClipDTOOutput, error) {
+	clip, err := s.getClip(ctx, id, false)
+
+	if err != nil {
+		return dto.ClipDTOOutput{}, err
+	}
+
+	return clip.ConvertToDTO(), nil
+}
+
+func (s *ClipService) getClip(ctx context.Context, id string, consistent bool) (entity.Clip, error) {
+	output, err := s.dynamo.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(CLIPS_TABLE),
+		Key: map[string]types.AttributeValue{
+			ID: &types.AttributeValueMemberS{Value: id},
+		},
+		ConsistentRead: aws.Bool(consistent),
+	})
+
+	if err != nil {
+		log.Printf("Error when tried to getItem from dynamoDB: %s", err)
+		return entity.Clip{}, err
+	}
+
+	if output.Item == nil {
+		return entity.Clip{}, ClipNotFoundErr
+	}
+
+	var clip entity.Clip
+
+	err = attributevalue.UnmarshalMap(output.Item, &clip)
+
+	if err != nil {
+		log.Printf("An error occurred when tried use attributevalue. Error: %v", err)
+		return entity.Clip{}, err
+	}
+
+	return clip, nil
+}
+
+// CutClip writes the audio of a queued clip under the clip ID and publishes
+// it with the metadata given when it was queued. Each step can be repeated,
+// so an error is returned to be retried, while a clip that can never be cut
+// is marked as failed with the reason.
+func (s *ClipService) CutClip(ctx context.Context, id string) error {
+	clip, err := s.getClip(ctx, id, true)
+
+	if err != nil {
+		return err
+	}
+
+	if clip.Status != CLIP_QUEUED {
+		log.Printf("Skipping the clip %s, which is %s", id, clip.Status)
+		return nil
+	}
+
+	reason, err := s.cut(ctx, clip)
+
+	if err != nil {
+		return err
+	}
+
+	if reason != "" {
+		log.Printf("Unable to cut the clip %s: %s", id, reason)
+		return s.finish(ctx, id, CLIP_FAILED, reason)
+	}
+
+	return s.finish(ctx, id, CLIP_DONE, "")
+}
+
+// cut returns why the clip can't be cut, or an empty reason once it is
+// published.
+func (s *ClipService) cut(ctx context.Context, clip entity.Clip) (string, error) {
+	key := EpisodeKey(clip.EpisodeID)
+
+	head, err := s.s3.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(BUCKET_NAME),
+		Key:    aws.String(key),
+	})
+
+	if err != nil {
+		var notFound *s3types.NotFound
+
+		if errors.As(err, &notFound) {
+			return EpisodeNotFoundErr.Error(), nil
+		}
+
+		log.Printf("Error getting object %s/%s: %s", BUCKET_NAME, key, err.Error())
+		return "", err
+	}
+
+	size := aws.ToInt64(head.ContentLength)
+	start := time.Duration(clip.StartMs) * time.Millisecond
+	end := time.Duration(clip.EndMs) * time.Millisecond
+
+	slice, err := audio.SliceWAV(newS3ReaderAt(ctx, s.s3, BUCKET_NAME, key, size), size, start, end)
+
+	if errors.Is(err, audio.UnknownFormatErr) || errors.Is(err, audio.MalformedErr) || errors.Is(err, audio.OutOfRangeErr) {
+		return err.Error(), nil
+	}
+
+	if err != nil {
+		log.Printf("An error occurred when tried to read the object %s/%s. Error: %v", BUCKET_NAME, key, err)
+		return "", err
+	}
+
+	if total := int64(len(slice.Header)) + slice.Length; total > MAX_AUDIO_SIZE {
+		return fmt.Sprintf("The clip has %d bytes, more than the limit of %d", total, MAX_AUDIO_SIZE), nil
+	}
+
+	err = s.uploads.CreateUpload(ctx, clip.ID, clip.FileName)
+
+	if err != nil && !errors.Is(err, ConfilctErr) {
+		return "", err
+	}
+
+	err = s.writeSlice(ctx, clip.ID, key, slice)
+
+	if err != nil {
+		return "", err
+	}
+
+	// The object also triggers ProcessUpload on its own, and whichever call
+	// comes second finds the upload no longer pending.
+	err = s.uploads.ProcessUpload(ctx, clip.ID, int64(len(slice.Header))+slice.Length)
+
+	if err != nil && !errors.Is(err, UploadNotPendingErr) {
+		return "", err
+	}
+
+	err = s.metadata.CreateItem(ctx, dto.MetadataDTOInput{
+		ID:              clip.ID,
+		FileName:        clip.FileName,
+		Author:          clip.Author,
+		Label:           clip.Label,
+		Type:            clip.Type,
+		Words:           clip.Words,
+		EpisodeID:       clip.EpisodeID,
+		EpisodeOffsetMs: clip.StartMs,
+	})
+
+	// A clip that is never published leaves nothing behind, so its ID
+	// doesn't hold an upload nor audio that nothing points to.
+	if errors.Is(err, FileNotFoundErr) || errors.Is(err, DuplicateAudioErr) {
+		reason := err.Error()
+
+		if errors.Is(err, FileNotFoundErr) {
+			reason = "The clip was rejected as audio"
+		}
+
+		err = s.uploads.DiscardUpload(ctx, clip.ID)
+
+		if err != nil {
+			return "", err
+		}
+
+		return reason, nil
+	}
+
+	if err != nil && !errors.Is(err, ConfilctErr) {
+		return "", err
+	}
+
+	return "", nil
+}
+
+// writeSlice copies the samples of the slice out of the episode, after the
+// header that makes them a file of their own.
+func (s *ClipService) writeSlice(ctx context.Context, id string, key string, slice audio.WAVSlice) error {
+	output, err := s.s3.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(BUCKET_NAME),
+		Key:    aws.String(key),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", slice.Offset, slice.Offset+slice.Length-1)),
+	})
+
+	if err != nil {
+		log.Printf("Error getting object %s/%s: %s", BUCKET_NAME, key, err.Error())
+		return err
+	}
+
+	defer output.Body.Close()
+
+	data, err := io.ReadAll(output.Body)
+
+	if err != nil {
+		log.Printf("Error reading object %s/%s: %s", BUCKET_NAME, key, err.Error())
+		return err
+	}
+
+	if int64(len(data)) != slice.Length {
+		return io.ErrUnexpectedEOF
+	}
+
+	_, err = s.s3.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(BUCKET_NAME),
+		Key:         aws.String(id),
+		Body:        bytes.NewReader(append(slice.Header, data...)),
+		ContentType: aws.String("audio/wav"),
+	})
+
+	if err != nil {
+		log.Printf("Error putting object %s/%s: %s", BUCKET_NAME, id, err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func (s *ClipService) finish(ctx context.Context, id string, status string, reason string) error {
+	update := "SET #status = :status"
+	values := map[string]types.AttributeValue{
+		":status": &types.AttributeValueMemberS{Value: status},
+		":queued": &types.AttributeValueMemberS{Value: CLIP_QUEUED},
+	}
+	names := map[string]string{"#status": STATUS}
+
+	if reason != "" {
+		update += ", #reason = :reason"
+		values[":reason"] = &types.AttributeValueMemberS{Value: reason}
+		names["#reason"] = REASON
+	}
+
+	_, err := s.dynamo.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(CLIPS_TABLE),
+		Key: map[string]types.AttributeValue{
+			ID: &types.AttributeValueMemberS{Value: id},
+		},
+		UpdateExpression:          aws.String(update),
+		ConditionExpression:       aws.String("#status = :queued"),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	})
+
+	var conditionErr *types.ConditionalCheckFailedException
+
+	if err != nil && !errors.As(err, &conditionErr) {
+		log.Printf("An error occurred when tried to mark the clip %s as %s. Error: %v", id, status, err)
+		return err
+	}
+
+	return nil
+}
+
+func EpisodeKey(id string) string {
+	return EPISODE_PREFIX + id
+}
+
+// IsEpisode tells the episodes apart from the audio, as uploading them
+// creates objects in the same bucket.
+func IsEpisode(key string) bool {
+	return strings.HasPrefix(key, EPISODE_PREFIX)
+}
+
+// ParseTimestamp reads a position in an episode written as seconds, mm:ss
+// or hh:mm:ss, where the seconds may have a fraction down to milliseconds.
+func ParseTimestamp(value string) (time.Duration, error) {
+	parts := strings.Split(value, ":")
+
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("%q is not a timestamp", value)
+	}
+
+	seconds, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+
+	if err != nil || math.IsNaN(seconds) || seconds < 0 || seconds > MAX_TIMESTAMP.Seconds() || (len(parts) > 1 && seconds >= 60) {
+		return 0, fmt.Errorf("%q is not a timestamp", value)
+	}
+
+	timestamp := time.Duration(math.Round(seconds*1000)) * time.Millisecond
+
+	for i, unit := range []time.Duration{time.Minute, time.Hour}[:len(parts)-1] {
+		n, err := strconv.Atoi(parts[len(parts)-2-i])
+
+		// n is bounded before multiplying, which would otherwise wrap.
+		if err != nil || n < 0 || n > int(MAX_TIMESTAMP/unit) || (unit == time.Minute && len(parts) == 3 && n >= 60) {
+			return 0, fmt.Errorf("%q is not a timestamp", value)
+		}
+
+		timestamp += time.Duration(n) * unit
+	}
+
+	if timestamp > MAX_TIMESTAMP {
+		return 0, fmt.Errorf("%q is not a timestamp", value)
+	}
+
+	return timestamp, nil
+}
@@ -0,0 +1,373 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/mocks"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// episodeWAV is two seconds of 8 kHz 16 bit mono, where each byte holds its
+// position so a slice can be told apart from any other.
+func episodeWAV() []byte {
+	data := make([]byte, 32000)
+
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	var buf bytes.Buffer
+
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+len(data)))
+	buf.WriteString("WAVEfmt ")
+
+	for _, field := range []any{uint32(16), uint16(1), uint16(1), uint32(8000), uint32(16000), uint16(2), uint16(16)} {
+		binary.Write(&buf, binary.LittleEndian, field)
+	}
+
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(data)))
+	buf.Write(data)
+
+	return buf.Bytes()
+}
+
+func queuedClipMock(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{
+		Item: map[string]types.AttributeValue{
+			"id":         &types.AttributeValueMemberS{Value: "clip"},
+			"episode_id": &types.AttributeValueMemberS{Value: "episode"},
+			"start_ms":   &types.AttributeValueMemberN{Value: "500"},
+			"end_ms":     &types.AttributeValueMemberN{Value: "1500"},
+			"status":     &types.AttributeValueMemberS{Value: CLIP_QUEUED},
+			"filename":   &types.AttributeValueMemberS{Value: "insertion.wav"},
+			"author":     &types.AttributeValueMemberS{Value: "João"},
+			"label":      &types.AttributeValueMemberS{Value: "Insertion"},
+			"type":       &types.AttributeValueMemberS{Value: "ad"},
+			"words":      &types.AttributeValueMemberS{Value: "buy now"},
+		},
+	}, nil
+}
+
+func TestParseTimestamp(t *testing.T) {
+	cases := map[string]time.Duration{
+		"90":           90 * time.Second,
+		"1.5":          1500 * time.Millisecond,
+		"01:30":        90 * time.Second,
+		"1:02:03.250":  time.Hour + 2*time.Minute + 3250*time.Millisecond,
+		"00:00:00.001": time.Millisecond,
+	}
+
+	for value, expected := range cases {
+		result, err := ParseTimestamp(value)
+
+		if err != nil || result != expected {
+			t.Errorf("The result is different from expected. Result: %v %v. Expected: %v", result, err, expected)
+		}
+	}
+
+	for _, value := range []string{"", "abc", "-1", "1:60", "1:60:00", "1:2:3:4", "NaN", "1000:00:01", "60001:00", "2562048:00:00", "9223372036854775807:00"} {
+		_, err := ParseTimestamp(value)
+
+		if err == nil {
+			t.Errorf("The timestamp %q should be invalid", value)
+		}
+	}
+}
+
+func TestCreateClipInvalidRange(t *testing.T) {
+	serviceHandler := NewClipService(mocks.MockedS3{}, mocks.MockedDynamoDB{})
+
+	for _, input := range []dto.ClipDTOInput{
+		{Start: "00:10", End: "00:05"},
+		{Start: "00:10", End: "00:10"},
+		{Start: "0", End: "10:00"},
+		{Start: "ten", End: "00:05"},
+	} {
+		_, err := serviceHandler.CreateClip(context.TODO(), "episode", input)
+
+		if !errors.Is(err, InvalidClipRangeErr) {
+			t.Errorf("The result is different from expected. Result: %v. Expected: %v", err, InvalidClipRangeErr)
+		}
+	}
+}
+
+func TestCreateClipEpisodeNotFound(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+
+	mockedS3.HeadObjectFuncMock = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		return nil, &s3types.NotFound{}
+	}
+
+	serviceHandler := NewClipService(mockedS3, mocks.MockedDynamoDB{})
+
+	_, err := serviceHandler.CreateClip(context.TODO(), "episode", dto.ClipDTOInput{Start: "1", End: "2"})
+
+	if !errors.Is(err, EpisodeNotFoundErr) {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", err, EpisodeNotFoundErr)
+	}
+}
+
+func TestCreateClipQueuesJob(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedS3.HeadObjectFuncMock = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		if *params.Key != "episodes/episode" {
+			t.Errorf("The result is different from expected. Result: %v. Expected: %v", *params.Key, "episodes/episode")
+		}
+
+		return &s3.HeadObjectOutput{}, nil
+	}
+
+	mockedDynamodb.PutItemFuncMock = func(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+		status := params.Item[STATUS].(*types.AttributeValueMemberS).Value
+
+		if status != CLIP_QUEUED {
+			t.Errorf("The result is different from expected. Result: %v. Expected: %v", status, CLIP_QUEUED)
+		}
+
+		return &dynamodb.PutItemOutput{}, nil
+	}
+
+	serviceHandler := NewClipService(mockedS3, mockedDynamodb)
+
+	clip, err := serviceHandler.CreateClip(context.TODO(), "episode", dto.ClipDTOInput{Start: "01:00.5", End: "01:30"})
+
+	if err != nil {
+		t.Errorf("The result is different from expected. Expected nil. Result: %v", err)
+	}
+
+	if clip.ID == "" || clip.EpisodeID != "episode" || clip.StartMs != 60500 || clip.EndMs != 90000 || clip.Status != CLIP_QUEUED {
+		t.Errorf("The result is different from expected. Result: %+v", clip)
+	}
+}
+
+func TestCutClipStoresSliceAndMetadata(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	episode := episodeWAV()
+	objects := map[string][]byte{}
+	var published map[string]types.AttributeValue
+	var finished string
+
+	mockedS3.HeadObjectFuncMock = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		return &s3.HeadObjectOutput{ContentLength: aws.Int64(int64(len(episode)))}, nil
+	}
+
+	mockedS3.GetObjectFuncMock = func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+		if *params.Key == "clip" {
+			return objectMock(objects["clip"])(ctx, params, optFns...)
+		}
+
+		return objectMock(episode)(ctx, params, optFns...)
+	}
+
+	mockedS3.PutObjectFuncMock = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		objects[*params.Key], _ = io.ReadAll(params.Body)
+		return &s3.PutObjectOutput{}, nil
+	}
+
+	calls := 0
+
+	mockedDynamodb.GetItemFuncMock = func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+		calls++
+
+		switch calls {
+		case 1:
+			return queuedClipMock(ctx, params, optFns...)
+		case 2:
+			return pendingUploadMock(ctx, params, optFns...)
+		}
+
+		return uploadMock(ctx, params, optFns...)
+	}
+
+	mockedDynamodb.PutItemFuncMock = func(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+		return &dynamodb.PutItemOutput{}, nil
+	}
+
+	mockedDynamodb.UpdateItemFuncMock = func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		if status, ok := params.ExpressionAttributeValues[":status"]; ok {
+			finished = status.(*types.AttributeValueMemberS).Value
+		}
+
+		return &dynamodb.UpdateItemOutput{}, nil
+	}
+
+	mockedDynamodb.TransactWriteItemsFuncMock = func(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+		published = params.TransactItems[0].Put.Item
+		return &dynamodb.TransactWriteItemsOutput{}, nil
+	}
+
+	serviceHandler := NewClipService(mockedS3, mockedDynamodb)
+
+	err := serviceHandler.CutClip(context.TODO(), "clip")
+
+	if err != nil {
+		t.Errorf("The result is different from expected. Expected nil. Result: %v", err)
+	}
+
+	// Half a second in is frame 4000, 8000 bytes into the data.
+	clip := objects["clip"]
+
+	if len(clip) != 44+16000 || !bytes.Equal(clip[44:], episode[44+8000:44+24000]) {
+		t.Errorf("The clip doesn't hold the samples between 0.5s and 1.5s. Length: %v", len(clip))
+	}
+
+	if _, ok := objects["waveforms/clip.json"]; !ok {
+		t.Errorf("The clip should have been processed as an upload")
+	}
+
+	episodeID := published["episode_id"].(*types.AttributeValueMemberS).Value
+	offset := published["episode_offset_ms"].(*types.AttributeValueMemberN).Value
+
+	if episodeID != "episode" || offset != "500" {
+		t.Errorf("The result is different from expected. Result: %v %v. Expected: %v %v", episodeID, offset, "episode", "500")
+	}
+
+	if finished != CLIP_DONE {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", finished, CLIP_DONE)
+	}
+}
+
+func TestCutClipNotPublishedLeavesNothing(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	episode := episodeWAV()
+	objects := map[string][]byte{}
+	var finished, discarded string
+
+	mockedS3.HeadObjectFuncMock = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		return &s3.HeadObjectOutput{ContentLength: aws.Int64(int64(len(episode)))}, nil
+	}
+
+	mockedS3.GetObjectFuncMock = func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+		if *params.Key == "clip" {
+			return objectMock(objects["clip"])(ctx, params, optFns...)
+		}
+
+		return objectMock(episode)(ctx, params, optFns...)
+	}
+
+	mockedS3.PutObjectFuncMock = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		objects[*params.Key], _ = io.ReadAll(params.Body)
+		return &s3.PutObjectOutput{}, nil
+	}
+
+	mockedS3.DeleteObjectFuncMock = func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+		delete(objects, *params.Key)
+		return &s3.DeleteObjectOutput{}, nil
+	}
+
+	calls := 0
+
+	mockedDynamodb.GetItemFuncMock = func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+		calls++
+
+		switch calls {
+		case 1:
+			return queuedClipMock(ctx, params, optFns...)
+		case 2:
+			return pendingUploadMock(ctx, params, optFns...)
+		}
+
+		return uploadMock(ctx, params, optFns...)
+	}
+
+	mockedDynamodb.PutItemFuncMock = func(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+		return &dynamodb.PutItemOutput{}, nil
+	}
+
+	mockedDynamodb.UpdateItemFuncMock = func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		if status, ok := params.ExpressionAttributeValues[":status"]; ok {
+			finished = status.(*types.AttributeValueMemberS).Value
+		}
+
+		return &dynamodb.UpdateItemOutput{}, nil
+	}
+
+	// The upload moved on from uploaded before the clip was published.
+	mockedDynamodb.TransactWriteItemsFuncMock = func(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+		return nil, canceledTransaction("None", "ConditionalCheckFailed")
+	}
+
+	mockedDynamodb.DeleteItemFuncMock = func(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+		discarded = params.Key[ID].(*types.AttributeValueMemberS).Value
+		return &dynamodb.DeleteItemOutput{}, nil
+	}
+
+	serviceHandler := NewClipService(mockedS3, mockedDynamodb)
+
+	err := serviceHandler.CutClip(context.TODO(), "clip")
+
+	if err != nil {
+		t.Errorf("The result is different from expected. Expected nil. Result: %v", err)
+	}
+
+	if len(objects) != 0 {
+		t.Errorf("The clip and its waveform should have been removed. Result: %v", objects)
+	}
+
+	if discarded != "clip" {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", discarded, "clip")
+	}
+
+	if finished != CLIP_FAILED {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", finished, CLIP_FAILED)
+	}
+}
+
+func TestCutClipOutsideEpisodeFails(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	// One second of audio, which ends before the clip starts.
+	episode := episodeWAV()[:44+16000]
+
+	mockedS3.HeadObjectFuncMock = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		return &s3.HeadObjectOutput{ContentLength: aws.Int64(int64(len(episode)))}, nil
+	}
+
+	mockedS3.GetObjectFuncMock = objectMock(episode)
+
+	mockedDynamodb.GetItemFuncMock = func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+		output, _ := queuedClipMock(ctx, params, optFns...)
+		output.Item["start_ms"] = &types.AttributeValueMemberN{Value: "1200"}
+		return output, nil
+	}
+
+	var finished, reason string
+
+	mockedDynamodb.UpdateItemFuncMock = func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		finished = params.ExpressionAttributeValues[":status"].(*types.AttributeValueMemberS).Value
+		reason = params.ExpressionAttributeValues[":reason"].(*types.AttributeValueMemberS).Value
+		return &dynamodb.UpdateItemOutput{}, nil
+	}
+
+	serviceHandler := NewClipService(mockedS3, mockedDynamodb)
+
+	err := serviceHandler.CutClip(context.TODO(), "clip")
+
+	if err != nil {
+		t.Errorf("The result is different from expected. Expected nil. Result: %v", err)
+	}
+
+	if finished != CLIP_FAILED || reason == "" {
+		t.Errorf("The result is different from expected. Result: %v %q. Expected: %v", finished, reason, CLIP_FAILED)
+	}
+}
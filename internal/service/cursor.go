@@ -0,0 +1,92 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var CURSOR_SECRET = os.Getenv("CURSOR_SECRET")
+
+var InvalidCursorErr = errors.New("Invalid pagination cursor")
+
+type cursorPayload struct {
+	Key   map[string]interface{} `json:"k"`
+	Scope string                 `json:"s,omitempty"`
+}
+
+// encodeCursor turns a DynamoDB LastEvaluatedKey into an opaque token. The
+// payload is signed with CURSOR_SECRET so clients can't forge a start key,
+// and carries the scope of the listing so a token can't be replayed against
+// a different index or filter.
+func encodeCursor(key map[string]types.AttributeValue, scope string) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+
+	var plain map[string]interface{}
+
+	err := attributevalue.UnmarshalMap(key, &plain)
+
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(cursorPayload{Key: plain, Scope: scope})
+
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+
+	return encoded + "." + signCursor(encoded), nil
+}
+
+func decodeCursor(token string, scope string) (map[string]types.AttributeValue, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	encoded, signature, found := strings.Cut(token, ".")
+
+	if !found || !hmac.Equal([]byte(signature), []byte(signCursor(encoded))) {
+		return nil, InvalidCursorErr
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+
+	if err != nil {
+		return nil, InvalidCursorErr
+	}
+
+	var parsed cursorPayload
+
+	err = json.Unmarshal(payload, &parsed)
+
+	if err != nil || len(parsed.Key) == 0 || parsed.Scope != scope {
+		return nil, InvalidCursorErr
+	}
+
+	key, err := attributevalue.MarshalMap(parsed.Key)
+
+	if err != nil {
+		return nil, InvalidCursorErr
+	}
+
+	return key, nil
+}
+
+func signCursor(encoded string) string {
+	mac := hmac.New(sha256.New, []byte(CURSOR_SECRET))
+	mac.Write([]byte(encoded))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/LucasAndFlores/go_lambdas_project/internal/entity"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	SHA256     = "sha256"
+	PCM_SHA256 = "pcm_sha256"
+
+	SHA256_INDEX     = "sha256-index"
+	PCM_SHA256_INDEX = "pcm-sha256-index"
+
+	// MATCH_BYTES is a byte for byte copy, while MATCH_PCM is the same audio
+	// in another container, with other tags or another sample encoding.
+	MATCH_BYTES = "bytes"
+	MATCH_PCM   = "pcm"
+)
+
+var DuplicateAudioErr = errors.New("The same audio was already published")
+
+// DuplicateAudioError points to the metadata already published with the
+// same audio.
+type DuplicateAudioError struct {
+	ID    string
+	Match string
+}
+
+func (e *DuplicateAudioError) Error() string {
+	return fmt.Sprintf("%s as %s", DuplicateAudioErr, e.ID)
+}
+
+func (e *DuplicateAudioError) Unwrap() error {
+	return DuplicateAudioErr
+}
+
+// findDuplicate looks the hashes of the audio up in the indexes of the
+// metadata table, ignoring the metadata of id itself so it can be published
+// again. The indexes are eventually consistent, so two copies published at
+// the same moment can both get through. Metadata published before the hashes
+// were kept has none and is never matched.
+func (s *MetadataService) findDuplicate(ctx context.Context, id string, properties entity.AudioProperties) error {
+	for _, index := range []struct {
+		name      string
+		attribute string
+		hash      string
+		match     string
+	}{
+		{SHA256_INDEX, SHA256, properties.SHA256, MATCH_BYTES},
+		{PCM_SHA256_INDEX, PCM_SHA256, properties.PCMSHA256, MATCH_PCM},
+	} {
+		if index.hash == "" {
+			continue
+		}
+
+		output, err := s.dynamo.Query(ctx, &dynamodb.QueryInput{
+			TableName:                aws.String(DYNAMO_TABLE),
+			IndexName:                aws.String(index.name),
+			KeyConditionExpression:   aws.String("#hash = :hash"),
+			ExpressionAttributeNames: map[string]string{"#hash": index.attribute},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":hash": &types.AttributeValueMemberS{Value: index.hash},
+			},
+			// Besides id itself, a single match is enough.
+			Limit: aws.Int32(2),
+		})
+
+		if err != nil {
+			log.Printf("Error when trying to use query method: %s", err)
+			return err
+		}
+
+		for _, item := range output.Items {
+			var match entity.Metadata
+
+			err = attributevalue.UnmarshalMap(item, &match)
+
+			if err != nil {
+				log.Printf("An error occurred when tried use attributevalue. Error: %v", err)
+				return err
+			}
+
+			if match.ID != id {
+				return &DuplicateAudioError{ID: match.ID, Match: index.match}
+			}
+		}
+	}
+
+	return nil
+}
@@ -3,123 +3,550 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
 	"github.com/LucasAndFlores/go_lambdas_project/internal/entity"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/normalize"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 var DYNAMO_TABLE = os.Getenv("DYNAMO_TABLE")
 
-var FileNotFoundErr = errors.New("Filename not found. Unable to complete the operation")
+const (
+	DEFAULT_PAGE_SIZE int32 = 25
+	MAX_PAGE_SIZE     int32 = 100
+)
+
+var FileNotFoundErr = errors.New("The audio upload is not finished. Unable to complete the operation")
 var ConfilctErr = errors.New("The object already exists")
+var ItemNotFoundErr = errors.New("Metadata not found")
+var NothingToUpdateErr = errors.New("At least one field should be informed to update")
 
 type S3Bucket interface {
 	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
 }
 
 type DynamoDB interface {
 	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
 	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
 	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error)
+	BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
 }
 
 type MetadataService struct {
-	s3     S3Bucket
-	dynamo DynamoDB
+	s3        S3Bucket
+	dynamo    DynamoDB
+	waveforms IWaveformService
 }
 
 type IMetadataService interface {
 	CreateItem(context.Context, dto.MetadataDTOInput) error
-	ListAllItems(context.Context) ([]dto.MetadataDTOOutput, error)
+	ListAllItems(context.Context, dto.MetadataListInput) (dto.MetadataListOutput, error)
+	GetItem(context.Context, string) (dto.MetadataDTOOutput, error)
+	UpdateItem(context.Context, string, dto.MetadataDTOInput) (dto.MetadataDTOOutput, error)
+	PatchItem(context.Context, string, dto.MetadataDTOPatchInput) (dto.MetadataDTOOutput, error)
+	DeleteItem(context.Context, string, bool) error
+	CreateItems(context.Context, []dto.MetadataDTOInput) ([]dto.BatchResultDTO, error)
 }
 
 func NewMetadataService(s S3Bucket, d DynamoDB) IMetadataService {
 	return &MetadataService{
-		s3:     s,
-		dynamo: d,
+		s3:        s,
+		dynamo:    d,
+		waveforms: NewWaveformService(s),
 	}
 }
 
+// CreateItem publishes an upload: the metadata is stored and the upload is
+// marked as published in a single transaction, so metadata can only point to
+// audio that reached S3. A published upload can be published again, which
+// restores metadata removed with metadataOnly. The audio properties staged
+// on the upload are copied to the metadata, and audio already published
+// under another id is refused with a DuplicateAudioError.
 func (s *MetadataService) CreateItem(ctx context.Context, metadata dto.MetadataDTOInput) error {
-	_, err := s.s3.HeadObject(ctx, &s3.HeadObjectInput{
-		Bucket: aws.String(BUCKET_NAME),
-		Key:    aws.String(metadata.FileName),
+	upload, err := s.getUpload(ctx, metadata.ID)
+
+	if err != nil {
+		return err
+	}
+
+	err = s.findDuplicate(ctx, metadata.ID, upload.AudioProperties)
+
+	if err != nil {
+		return err
+	}
+
+	item, err := metadataItem(metadata, upload)
+
+	if err != nil {
+		return err
+	}
+
+	return s.publish(ctx, metadata.ID, item)
+}
+
+// publish stores the metadata item of id and marks its upload as published
+// in a single transaction. Existing metadata gives ConfilctErr, and an upload
+// that isn't finished FileNotFoundErr.
+func (s *MetadataService) publish(ctx context.Context, id string, item map[string]types.AttributeValue) error {
+	_, err := s.dynamo.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Put: &types.Put{
+					TableName:                aws.String(DYNAMO_TABLE),
+					Item:                     item,
+					ConditionExpression:      aws.String("attribute_not_exists(#id)"),
+					ExpressionAttributeNames: map[string]string{"#id": ID},
+				},
+			},
+			{
+				Update: &types.Update{
+					TableName: aws.String(UPLOADS_TABLE),
+					Key: map[string]types.AttributeValue{
+						ID: &types.AttributeValueMemberS{Value: id},
+					},
+					UpdateExpression:         aws.String("SET #status = :published"),
+					ConditionExpression:      aws.String("#status IN (:uploaded, :published)"),
+					ExpressionAttributeNames: map[string]string{"#status": STATUS},
+					ExpressionAttributeValues: map[string]types.AttributeValue{
+						":uploaded":  &types.AttributeValueMemberS{Value: UPLOAD_UPLOADED},
+						":published": &types.AttributeValueMemberS{Value: UPLOAD_PUBLISHED},
+					},
+				},
+			},
+		},
+	})
+
+	if err != nil {
+		var canceledErr *types.TransactionCanceledException
+
+		if errors.As(err, &canceledErr) {
+			switch {
+			case conditionFailed(canceledErr, 0):
+				return ConfilctErr
+			case conditionFailed(canceledErr, 1):
+				return FileNotFoundErr
+			}
+		}
+
+		log.Printf("Error when trying to use transactWriteItems method: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+// metadataItem is the metadata of an upload as stored, with the audio
+// properties staged on the upload.
+func metadataItem(metadata dto.MetadataDTOInput, upload entity.Upload) (map[string]types.AttributeValue, error) {
+	properties, err := attributevalue.MarshalMap(upload.AudioProperties)
+
+	if err != nil {
+		log.Printf("An error occurred when tried use attributevalue. Error: %v", err)
+		return nil, err
+	}
+
+	item := map[string]types.AttributeValue{
+		"id":                &types.AttributeValueMemberS{Value: metadata.ID},
+		"filename":          &types.AttributeValueMemberS{Value: metadata.FileName},
+		"author":            &types.AttributeValueMemberS{Value: metadata.Author},
+		"label":             &types.AttributeValueMemberS{Value: metadata.Label},
+		"type":              &types.AttributeValueMemberS{Value: metadata.Type},
+		"words":             &types.AttributeValueMemberS{Value: metadata.Words},
+		"author_normalized": &types.AttributeValueMemberS{Value: normalize.Fold(metadata.Author)},
+		"label_normalized":  &types.AttributeValueMemberS{Value: normalize.Fold(metadata.Label)},
+	}
+
+	for name, value := range properties {
+		item[name] = value
+	}
+
+	if upload.Format != "" {
+		item[FORMAT] = &types.AttributeValueMemberS{Value: upload.Format}
+	}
+
+	if metadata.EpisodeID != "" {
+		item["episode_id"] = &types.AttributeValueMemberS{Value: metadata.EpisodeID}
+		item["episode_offset_ms"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(metadata.EpisodeOffsetMs, 10)}
+	}
+
+	return item, nil
+}
+
+func (s *MetadataService) getUpload(ctx context.Context, id string) (entity.Upload, error) {
+	output, err := s.dynamo.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(UPLOADS_TABLE),
+		Key: map[string]types.AttributeValue{
+			ID: &types.AttributeValueMemberS{Value: id},
+		},
+		ConsistentRead: aws.Bool(true),
 	})
 
 	if err != nil {
-		log.Printf("Error getting head of object %s/%s: %s", BUCKET_NAME, metadata.FileName, err.Error())
-		return FileNotFoundErr
+		log.Printf("Error when tried to getItem from dynamoDB: %s", err)
+		return entity.Upload{}, err
 	}
 
+	if output.Item == nil {
+		return entity.Upload{}, FileNotFoundErr
+	}
+
+	var upload entity.Upload
+
+	err = attributevalue.UnmarshalMap(output.Item, &upload)
+
+	if err != nil {
+		log.Printf("An error occurred when tried use attributevalue. Error: %v", err)
+		return entity.Upload{}, err
+	}
+
+	return upload, nil
+}
+
+// conditionFailed reports whether the item at index of a canceled
+// transaction was the one whose condition failed.
+func conditionFailed(err *types.TransactionCanceledException, index int) bool {
+	if index >= len(err.CancellationReasons) {
+		return false
+	}
+
+	code := err.CancellationReasons[index].Code
+
+	return code != nil && *code == "ConditionalCheckFailed"
+}
+
+func (s *MetadataService) GetItem(ctx context.Context, id string) (dto.MetadataDTOOutput, error) {
 	output, err := s.dynamo.GetItem(ctx, &dynamodb.GetItemInput{
 		TableName: aws.String(DYNAMO_TABLE),
 		Key: map[string]types.AttributeValue{
-			"filename": &types.AttributeValueMemberS{Value: metadata.FileName},
+			"id": &types.AttributeValueMemberS{Value: id},
 		},
 	})
 
 	if err != nil {
 		log.Printf("Error when tried to getItem from dynamoDB: %s", err)
-		return err
+		return dto.MetadataDTOOutput{}, err
 	}
 
-	if output.Item != nil {
-		return ConfilctErr
+	if output.Item == nil {
+		return dto.MetadataDTOOutput{}, ItemNotFoundErr
 	}
 
-	item := map[string]types.AttributeValue{
-		"filename": &types.AttributeValueMemberS{Value: metadata.FileName},
-		"author":   &types.AttributeValueMemberS{Value: metadata.Author},
-		"label":    &types.AttributeValueMemberS{Value: metadata.Label},
-		"type":     &types.AttributeValueMemberS{Value: metadata.Type},
-		"words":    &types.AttributeValueMemberS{Value: metadata.Words},
+	var item entity.Metadata
+
+	err = attributevalue.UnmarshalMap(output.Item, &item)
+
+	if err != nil {
+		log.Printf("An error occurred when tried use attributevalue. Error: %v", err)
+		return dto.MetadataDTOOutput{}, err
 	}
 
-	putItemInput := &dynamodb.PutItemInput{
+	return item.ConvertToDTO(), nil
+}
+
+func (s *MetadataService) UpdateItem(ctx context.Context, id string, metadata dto.MetadataDTOInput) (dto.MetadataDTOOutput, error) {
+	return s.updateFields(ctx, id, map[string]string{
+		FILENAME: metadata.FileName,
+		AUTHOR:   metadata.Author,
+		LABEL:    metadata.Label,
+		TYPE:     metadata.Type,
+		WORDS:    metadata.Words,
+	})
+}
+
+func (s *MetadataService) PatchItem(ctx context.Context, id string, patch dto.MetadataDTOPatchInput) (dto.MetadataDTOOutput, error) {
+	fields := map[string]string{}
+
+	if patch.FileName != nil {
+		fields[FILENAME] = *patch.FileName
+	}
+
+	if patch.Author != nil {
+		fields[AUTHOR] = *patch.Author
+	}
+
+	if patch.Label != nil {
+		fields[LABEL] = *patch.Label
+	}
+
+	if patch.Type != nil {
+		fields[TYPE] = *patch.Type
+	}
+
+	if patch.Words != nil {
+		fields[WORDS] = *patch.Words
+	}
+
+	if len(fields) == 0 {
+		return dto.MetadataDTOOutput{}, NothingToUpdateErr
+	}
+
+	return s.updateFields(ctx, id, fields)
+}
+
+// updateFields sets only the given attributes, failing with ItemNotFoundErr
+// instead of creating a new row when the id is unknown. The normalized
+// shadow attributes follow any change to author or label.
+func (s *MetadataService) updateFields(ctx context.Context, id string, fields map[string]string) (dto.MetadataDTOOutput, error) {
+	if author, ok := fields[AUTHOR]; ok {
+		fields[AUTHOR_NORMALIZED] = normalize.Fold(author)
+	}
+
+	if label, ok := fields[LABEL]; ok {
+		fields[LABEL_NORMALIZED] = normalize.Fold(label)
+	}
+
+	names := make([]string, 0, len(fields))
+
+	for name := range fields {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	assignments := make([]string, 0, len(names))
+	attributeNames := map[string]string{"#id": ID}
+	attributeValues := map[string]types.AttributeValue{}
+
+	for _, name := range names {
+		assignments = append(assignments, fmt.Sprintf("#%s = :%s", name, name))
+		attributeNames["#"+name] = name
+		attributeValues[":"+name] = &types.AttributeValueMemberS{Value: fields[name]}
+	}
+
+	output, err := s.dynamo.UpdateItem(ctx, &dynamodb.UpdateItemInput{
 		TableName: aws.String(DYNAMO_TABLE),
-		Item:      item,
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		UpdateExpression:          aws.String("SET " + strings.Join(assignments, ", ")),
+		ConditionExpression:       aws.String("attribute_exists(#id)"),
+		ExpressionAttributeNames:  attributeNames,
+		ExpressionAttributeValues: attributeValues,
+		ReturnValues:              types.ReturnValueAllNew,
+	})
+
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+
+		if errors.As(err, &conditionErr) {
+			return dto.MetadataDTOOutput{}, ItemNotFoundErr
+		}
+
+		log.Printf("Error when trying to use updateItem method: %s", err)
+		return dto.MetadataDTOOutput{}, err
 	}
 
-	_, err = s.dynamo.PutItem(ctx, putItemInput)
+	var item entity.Metadata
+
+	err = attributevalue.UnmarshalMap(output.Attributes, &item)
+
+	if err != nil {
+		log.Printf("An error occurred when tried use attributevalue. Error: %v", err)
+		return dto.MetadataDTOOutput{}, err
+	}
+
+	return item.ConvertToDTO(), nil
+}
+
+// DeleteItem removes the metadata first, so the insertion stops being listed
+// even if the S3 removal fails; a retry then only deletes the leftover object.
+// ItemNotFoundErr is returned only when neither side exists.
+func (s *MetadataService) DeleteItem(ctx context.Context, id string, metadataOnly bool) error {
+	output, err := s.dynamo.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(DYNAMO_TABLE),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ReturnValues: types.ReturnValueAllOld,
+	})
 
 	if err != nil {
-		log.Printf("Error when trying to use putItem method: %s", err)
+		log.Printf("Error when trying to use deleteItem method: %s", err)
+		return err
+	}
+
+	metadataExisted := len(output.Attributes) > 0
+
+	if metadataOnly {
+		if !metadataExisted {
+			return ItemNotFoundErr
+		}
+
+		return nil
+	}
+
+	// Without its upload, the id can't be published again once the audio is gone.
+	_, err = s.dynamo.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(UPLOADS_TABLE),
+		Key: map[string]types.AttributeValue{
+			ID: &types.AttributeValueMemberS{Value: id},
+		},
+	})
+
+	if err != nil {
+		log.Printf("Error when trying to use deleteItem method: %s", err)
+		return err
+	}
+
+	err = s.waveforms.DeleteWaveform(ctx, id)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = s.s3.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(BUCKET_NAME),
+		Key:    aws.String(id),
+	})
+
+	if err != nil {
+		var notFound *s3types.NotFound
+
+		if !errors.As(err, &notFound) {
+			log.Printf("Error getting head of object %s/%s: %s", BUCKET_NAME, id, err.Error())
+			return err
+		}
+
+		if !metadataExisted {
+			return ItemNotFoundErr
+		}
+
+		return nil
+	}
+
+	_, err = s.s3.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(BUCKET_NAME),
+		Key:    aws.String(id),
+	})
+
+	if err != nil {
+		log.Printf("Error deleting object %s/%s: %s", BUCKET_NAME, id, err.Error())
 		return err
 	}
 
 	return nil
+}
+
+func (s *MetadataService) ListAllItems(ctx context.Context, input dto.MetadataListInput) (dto.MetadataListOutput, error) {
+	query := buildListQuery(input)
+
+	startKey, err := decodeCursor(input.Cursor, query.scope)
+
+	if err != nil {
+		return dto.MetadataListOutput{Metadata: []dto.MetadataDTOOutput{}}, err
+	}
+
+	var limit *int32
+
+	if !input.All {
+		limit = aws.Int32(pageSize(input.Limit))
+	}
+
+	var items []map[string]types.AttributeValue
+
+	for {
+		page, lastKey, err := s.fetchPage(ctx, query, startKey, limit)
+
+		if err != nil {
+			log.Printf("An error occurred when tried to list items. Error: %v", err)
+			return dto.MetadataListOutput{Metadata: []dto.MetadataDTOOutput{}}, err
+		}
+
+		items = append(items, page...)
+		startKey = lastKey
+
+		if !input.All || len(lastKey) == 0 {
+			break
+		}
+	}
 
+	metadataOutput, err := convertItems(items)
+
+	if err != nil {
+		return dto.MetadataListOutput{Metadata: []dto.MetadataDTOOutput{}}, err
+	}
+
+	nextCursor, err := encodeCursor(startKey, query.scope)
+
+	if err != nil {
+		log.Printf("An error occurred when tried to encode the pagination cursor. Error: %v", err)
+		return dto.MetadataListOutput{Metadata: []dto.MetadataDTOOutput{}}, err
+	}
+
+	return dto.MetadataListOutput{Metadata: metadataOutput, NextCursor: nextCursor}, nil
 }
 
-func (s *MetadataService) ListAllItems(ctx context.Context) ([]dto.MetadataDTOOutput, error) {
-	output, err := s.dynamo.Scan(context.Background(), &dynamodb.ScanInput{TableName: aws.String(DYNAMO_TABLE)})
+func (s *MetadataService) fetchPage(ctx context.Context, query listQuery, startKey map[string]types.AttributeValue, limit *int32) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error) {
+	if query.index == "" {
+		output, err := s.dynamo.Scan(ctx, &dynamodb.ScanInput{
+			TableName:                 aws.String(DYNAMO_TABLE),
+			ExclusiveStartKey:         startKey,
+			Limit:                     limit,
+			FilterExpression:          query.filterExpression,
+			ExpressionAttributeNames:  query.attributeNames,
+			ExpressionAttributeValues: query.attributeValues,
+		})
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return output.Items, output.LastEvaluatedKey, nil
+	}
+
+	output, err := s.dynamo.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(DYNAMO_TABLE),
+		IndexName:                 aws.String(query.index),
+		ExclusiveStartKey:         startKey,
+		Limit:                     limit,
+		KeyConditionExpression:    query.keyCondition,
+		FilterExpression:          query.filterExpression,
+		ExpressionAttributeNames:  query.attributeNames,
+		ExpressionAttributeValues: query.attributeValues,
+	})
 
 	if err != nil {
-		log.Printf("An error occurred when tried to scan all items. Error: %v", err)
-		return []dto.MetadataDTOOutput{}, err
+		return nil, nil, err
 	}
 
+	return output.Items, output.LastEvaluatedKey, nil
+}
+
+func convertItems(items []map[string]types.AttributeValue) ([]dto.MetadataDTOOutput, error) {
 	var listOfAllItems []entity.Metadata
 
-	err = attributevalue.UnmarshalListOfMaps(output.Items, &listOfAllItems)
+	err := attributevalue.UnmarshalListOfMaps(items, &listOfAllItems)
 
 	if err != nil {
 		log.Printf("An error occurred when tried use attributevalue. Error: %v", err)
 		return []dto.MetadataDTOOutput{}, err
 	}
 
-	if len(listOfAllItems) == 0 {
-		return []dto.MetadataDTOOutput{}, nil
-	}
-
-	var metadataOutput []dto.MetadataDTOOutput
+	metadataOutput := []dto.MetadataDTOOutput{}
 
 	for _, val := range listOfAllItems {
 		converted := val.ConvertToDTO()
@@ -129,3 +556,15 @@ func (s *MetadataService) ListAllItems(ctx context.Context) ([]dto.MetadataDTOOu
 
 	return metadataOutput, nil
 }
+
+func pageSize(limit int32) int32 {
+	if limit <= 0 {
+		return DEFAULT_PAGE_SIZE
+	}
+
+	if limit > MAX_PAGE_SIZE {
+		return MAX_PAGE_SIZE
+	}
+
+	return limit
+}
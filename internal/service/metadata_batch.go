@@ -0,0 +1,363 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/entity"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// MAX_BATCH_CONCURRENCY bounds how many items of a batch are checked at once,
+// so a batch doesn't eat the whole throughput of the tables.
+const MAX_BATCH_CONCURRENCY = 8
+
+var RepeatedIDErr = errors.New("The id is repeated in the batch")
+
+// CreateItems publishes many uploads at once. Each item gets its own result:
+// it is refused when invalid, when its metadata or audio already exists, or
+// when its upload isn't finished, without stopping the others.
+//
+// Unlike CreateItem, the metadata is written with BatchWriteItem, in chunks
+// of MAX_BATCH_WRITE_SIZE, resending the items DynamoDB leaves unprocessed.
+// The uploads are marked as published first, each on its own condition, so
+// a failed write leaves them ready to be sent again. When a chunk fails, its
+// items are looked up again, so the status of an item tells whether its
+// metadata was stored. BatchWriteItem has no conditions, so metadata stored
+// by another request after the lookup is overwritten. Audio repeated within
+// the batch is refused after its first copy, since findDuplicate only sees
+// published audio.
+func (s *MetadataService) CreateItems(ctx context.Context, items []dto.MetadataDTOInput) ([]dto.BatchResultDTO, error) {
+	results := make([]dto.BatchResultDTO, len(items))
+	seen := map[string]bool{}
+
+	var pending []int
+
+	for index, item := range items {
+		results[index] = dto.BatchResultDTO{Index: index, ID: item.ID, FileName: item.FileName}
+
+		validatonErr := item.Validate()
+
+		switch {
+		case validatonErr != nil:
+			results[index].Status = BATCH_INVALID
+			results[index].Errors = validatonErr
+
+		case seen[item.ID]:
+			results[index].Status = BATCH_CONFLICT
+			results[index].Message = RepeatedIDErr.Error()
+
+		default:
+			seen[item.ID] = true
+			pending = append(pending, index)
+		}
+	}
+
+	if len(pending) == 0 {
+		return results, nil
+	}
+
+	existing, err := s.existingItems(ctx, items, pending)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var accepted []int
+
+	for _, index := range pending {
+		if existing[items[index].ID] {
+			results[index].Status = BATCH_CONFLICT
+			results[index].Message = ConfilctErr.Error()
+			continue
+		}
+
+		accepted = append(accepted, index)
+	}
+
+	records := make([]map[string]types.AttributeValue, len(items))
+	properties := make([]entity.AudioProperties, len(items))
+
+	forEach(accepted, func(index int) {
+		record, audioProperties, err := s.prepareItem(ctx, items[index])
+
+		if err != nil {
+			setBatchError(&results[index], err)
+			return
+		}
+
+		records[index] = record
+		properties[index] = audioProperties
+	})
+
+	var ready []int
+
+	hashes := batchHashes{}
+
+	for _, index := range accepted {
+		if records[index] == nil {
+			continue
+		}
+
+		err := hashes.add(items[index].ID, properties[index])
+
+		if err != nil {
+			setBatchError(&results[index], err)
+			continue
+		}
+
+		ready = append(ready, index)
+	}
+
+	marked := make([]bool, len(items))
+
+	forEach(ready, func(index int) {
+		err := s.markPublished(ctx, items[index].ID)
+
+		if err != nil {
+			setBatchError(&results[index], err)
+			return
+		}
+
+		marked[index] = true
+	})
+
+	var writes []int
+
+	for _, index := range ready {
+		if marked[index] {
+			writes = append(writes, index)
+		}
+	}
+
+	// Writing a chunk at a time tells which items may be missing when a
+	// chunk fails.
+	for start := 0; start < len(writes); start += MAX_BATCH_WRITE_SIZE {
+		chunk := writes[start:min(start+MAX_BATCH_WRITE_SIZE, len(writes))]
+		stored := s.writeChunk(ctx, items, records, chunk)
+
+		for _, index := range chunk {
+			if stored[items[index].ID] {
+				results[index].Status = BATCH_CREATED
+			} else {
+				results[index].Status = BATCH_FAILED
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// markPublished marks the upload of id as published, as long as its audio
+// was accepted. Uploads already published stay so, for a batch sent again
+// after its metadata failed to be written.
+func (s *MetadataService) markPublished(ctx context.Context, id string) error {
+	_, err := s.dynamo.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(UPLOADS_TABLE),
+		Key: map[string]types.AttributeValue{
+			ID: &types.AttributeValueMemberS{Value: id},
+		},
+		UpdateExpression:         aws.String("SET #status = :published"),
+		ConditionExpression:      aws.String("#status IN (:uploaded, :published)"),
+		ExpressionAttributeNames: map[string]string{"#status": STATUS},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uploaded":  &types.AttributeValueMemberS{Value: UPLOAD_UPLOADED},
+			":published": &types.AttributeValueMemberS{Value: UPLOAD_PUBLISHED},
+		},
+	})
+
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+
+		if errors.As(err, &conditionErr) {
+			return FileNotFoundErr
+		}
+
+		log.Printf("An error occurred when tried to mark the upload %s as published. Error: %v", id, err)
+		return err
+	}
+
+	return nil
+}
+
+// writeChunk writes the metadata of the items at indexes and tells which
+// ids were stored. A write can fail after some of the items went through,
+// so the chunk is then looked up to tell them apart.
+func (s *MetadataService) writeChunk(ctx context.Context, items []dto.MetadataDTOInput, records []map[string]types.AttributeValue, indexes []int) map[string]bool {
+	requests := make([]types.WriteRequest, 0, len(indexes))
+
+	for _, index := range indexes {
+		requests = append(requests, types.WriteRequest{PutRequest: &types.PutRequest{Item: records[index]}})
+	}
+
+	err := batchWrite(ctx, s.dynamo, DYNAMO_TABLE, requests)
+
+	if err == nil {
+		stored := map[string]bool{}
+
+		for _, index := range indexes {
+			stored[items[index].ID] = true
+		}
+
+		return stored
+	}
+
+	stored, err := s.existingItems(ctx, items, indexes)
+
+	if err != nil {
+		log.Printf("Unable to tell which metadata of the batch was stored. Error: %v", err)
+		return map[string]bool{}
+	}
+
+	return stored
+}
+
+// batchHashes are the hashes of the audio accepted so far in a batch, along
+// with the id of the item that brought them.
+type batchHashes map[batchHash]string
+
+type batchHash struct {
+	match string
+	hash  string
+}
+
+// add refuses the audio of id when an item before it in the batch has the
+// same audio, as findDuplicate would once both are published.
+func (h batchHashes) add(id string, properties entity.AudioProperties) error {
+	var keys []batchHash
+
+	for _, key := range []batchHash{{MATCH_BYTES, properties.SHA256}, {MATCH_PCM, properties.PCMSHA256}} {
+		if key.hash == "" {
+			continue
+		}
+
+		if match, ok := h[key]; ok {
+			return &DuplicateAudioError{ID: match, Match: key.match}
+		}
+
+		keys = append(keys, key)
+	}
+
+	for _, key := range keys {
+		h[key] = id
+	}
+
+	return nil
+}
+
+// existingItems tells which of the items at indexes already have metadata.
+func (s *MetadataService) existingItems(ctx context.Context, items []dto.MetadataDTOInput, indexes []int) (map[string]bool, error) {
+	keys := make([]map[string]types.AttributeValue, 0, len(indexes))
+
+	for _, index := range indexes {
+		keys = append(keys, map[string]types.AttributeValue{
+			ID: &types.AttributeValueMemberS{Value: items[index].ID},
+		})
+	}
+
+	found, err := batchGet(ctx, s.dynamo, DYNAMO_TABLE, keys)
+
+	if err != nil {
+		return nil, err
+	}
+
+	existing := map[string]bool{}
+
+	for _, item := range found {
+		if id, ok := item[ID].(*types.AttributeValueMemberS); ok {
+			existing[id.Value] = true
+		}
+	}
+
+	return existing, nil
+}
+
+// prepareItem runs the checks of CreateItem on a single item, plus one that
+// the audio is still on S3, and returns its metadata item along with the
+// properties of its audio.
+func (s *MetadataService) prepareItem(ctx context.Context, metadata dto.MetadataDTOInput) (map[string]types.AttributeValue, entity.AudioProperties, error) {
+	_, err := s.s3.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(BUCKET_NAME),
+		Key:    aws.String(metadata.ID),
+	})
+
+	if err != nil {
+		var notFound *s3types.NotFound
+
+		if errors.As(err, &notFound) {
+			return nil, entity.AudioProperties{}, FileNotFoundErr
+		}
+
+		log.Printf("Error getting object %s/%s: %s", BUCKET_NAME, metadata.ID, err.Error())
+		return nil, entity.AudioProperties{}, err
+	}
+
+	upload, err := s.getUpload(ctx, metadata.ID)
+
+	if err != nil {
+		return nil, entity.AudioProperties{}, err
+	}
+
+	if upload.Status != UPLOAD_UPLOADED && upload.Status != UPLOAD_PUBLISHED {
+		return nil, entity.AudioProperties{}, FileNotFoundErr
+	}
+
+	err = s.findDuplicate(ctx, metadata.ID, upload.AudioProperties)
+
+	if err != nil {
+		return nil, entity.AudioProperties{}, err
+	}
+
+	item, err := metadataItem(metadata, upload)
+
+	if err != nil {
+		return nil, entity.AudioProperties{}, err
+	}
+
+	return item, upload.AudioProperties, nil
+}
+
+// setBatchError maps the errors of CreateItem to the status of an item.
+func setBatchError(result *dto.BatchResultDTO, err error) {
+	switch {
+	case errors.Is(err, DuplicateAudioErr), errors.Is(err, ConfilctErr):
+		result.Status = BATCH_CONFLICT
+		result.Message = err.Error()
+
+	case errors.Is(err, FileNotFoundErr):
+		result.Status = BATCH_FILE_NOT_FOUND
+		result.Message = err.Error()
+
+	default:
+		result.Status = BATCH_FAILED
+	}
+}
+
+// forEach calls fn with every index, running at most MAX_BATCH_CONCURRENCY
+// calls at once.
+func forEach(indexes []int, fn func(index int)) {
+	var wg sync.WaitGroup
+
+	slots := make(chan struct{}, MAX_BATCH_CONCURRENCY)
+
+	for _, index := range indexes {
+		wg.Add(1)
+		slots <- struct{}{}
+
+		go func(index int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			fn(index)
+		}(index)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,302 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/mocks"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/ulid"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func metadataInput(id string) dto.MetadataDTOInput {
+	return dto.MetadataDTOInput{
+		ID:       id,
+		FileName: "test",
+		Author:   "test",
+		Label:    "test",
+		Words:    "test",
+		Type:     "test",
+	}
+}
+
+// existingMetadataMock answers the lookup of existing metadata with ids.
+func existingMetadataMock(ids ...string) func(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	return func(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+		items := make([]map[string]types.AttributeValue, 0, len(ids))
+
+		for _, id := range ids {
+			items = append(items, map[string]types.AttributeValue{ID: &types.AttributeValueMemberS{Value: id}})
+		}
+
+		return &dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]types.AttributeValue{DYNAMO_TABLE: items}}, nil
+	}
+}
+
+func statuses(results []dto.BatchResultDTO) []string {
+	result := make([]string, 0, len(results))
+
+	for _, item := range results {
+		result = append(result, item.Status)
+	}
+
+	return result
+}
+
+func TestCreateItemsRefusesInvalidAndConflicts(t *testing.T) {
+	mockedDynamodb := mocks.MockedDynamoDB{}
+	existing := ulid.New()
+	mockedDynamodb.BatchGetItemFuncMock = existingMetadataMock(existing)
+
+	serviceHandler := NewMetadataService(mocks.MockedS3{}, mockedDynamodb)
+
+	invalid := metadataInput(ulid.New())
+	invalid.Type = ""
+
+	results, err := serviceHandler.CreateItems(context.TODO(), []dto.MetadataDTOInput{
+		invalid,
+		metadataInput(existing),
+		metadataInput(existing),
+	})
+
+	if err != nil {
+		t.Errorf("The result is different from expected. Expected nil. Result: %v", err)
+	}
+
+	expected := []string{BATCH_INVALID, BATCH_CONFLICT, BATCH_CONFLICT}
+
+	if len(results) != len(expected) || results[0].Status != expected[0] || results[1].Status != expected[1] || results[2].Status != expected[2] {
+		t.Fatalf("The result is different from expected. Result: %v. Expected: %v", statuses(results), expected)
+	}
+
+	if len(results[0].Errors) != 1 || results[0].Errors[0].Field != "Type" {
+		t.Errorf("The result is different from expected. Result: %+v", results[0].Errors)
+	}
+
+	if results[1].Message != ConfilctErr.Error() || results[2].Message != RepeatedIDErr.Error() {
+		t.Errorf("The result is different from expected. Result: %v, %v", results[1].Message, results[2].Message)
+	}
+}
+
+func TestCreateItemsFileNotFound(t *testing.T) {
+	mockedDynamodb := mocks.MockedDynamoDB{}
+	mockedDynamodb.BatchGetItemFuncMock = existingMetadataMock()
+
+	serviceHandler := NewMetadataService(missingObjectS3(), mockedDynamodb)
+
+	results, err := serviceHandler.CreateItems(context.TODO(), []dto.MetadataDTOInput{metadataInput(ulid.New())})
+
+	if err != nil {
+		t.Errorf("The result is different from expected. Expected nil. Result: %v", err)
+	}
+
+	if results[0].Status != BATCH_FILE_NOT_FOUND || results[0].Message != FileNotFoundErr.Error() {
+		t.Errorf("The result is different from expected. Result: %+v. Expected: %v", results[0], BATCH_FILE_NOT_FOUND)
+	}
+}
+
+func TestCreateItemsSuccessfulResponse(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedS3.HeadObjectFuncMock = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		return &s3.HeadObjectOutput{}, nil
+	}
+
+	mockedDynamodb.BatchGetItemFuncMock = existingMetadataMock()
+	mockedDynamodb.GetItemFuncMock = uploadMock
+
+	var mu sync.Mutex
+	published := map[string]bool{}
+
+	mockedDynamodb.UpdateItemFuncMock = func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		published[params.Key[ID].(*types.AttributeValueMemberS).Value] = true
+
+		return &dynamodb.UpdateItemOutput{}, nil
+	}
+
+	var chunks []int
+	unprocessed := true
+
+	mockedDynamodb.BatchWriteItemFuncMock = func(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+		requests := params.RequestItems[DYNAMO_TABLE]
+
+		// The metadata carries the properties staged on the upload.
+		if _, ok := requests[0].PutRequest.Item["duration_ms"]; !ok {
+			t.Errorf("The item is different from expected. Result: %+v", requests[0].PutRequest.Item)
+		}
+
+		// The first write leaves an item unprocessed, which is sent again.
+		if unprocessed {
+			unprocessed = false
+
+			return &dynamodb.BatchWriteItemOutput{
+				UnprocessedItems: map[string][]types.WriteRequest{DYNAMO_TABLE: requests[:1]},
+			}, nil
+		}
+
+		chunks = append(chunks, len(requests))
+
+		return &dynamodb.BatchWriteItemOutput{}, nil
+	}
+
+	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
+
+	items := make([]dto.MetadataDTOInput, 0, MAX_BATCH_WRITE_SIZE+5)
+
+	for index := 0; index < cap(items); index++ {
+		items = append(items, metadataInput(ulid.New()))
+	}
+
+	results, err := serviceHandler.CreateItems(context.TODO(), items)
+
+	if err != nil {
+		t.Errorf("The result is different from expected. Expected nil. Result: %v", err)
+	}
+
+	for _, result := range results {
+		if result.Status != BATCH_CREATED {
+			t.Errorf("The result is different from expected. Result: %+v. Expected: %v", result, BATCH_CREATED)
+		}
+	}
+
+	if len(published) != len(items) {
+		t.Errorf("The result is different from expected. Result: %v published. Expected: %v", len(published), len(items))
+	}
+
+	expected := fmt.Sprint([]int{1, 5})
+
+	if fmt.Sprint(chunks) != expected {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", chunks, expected)
+	}
+}
+
+func TestCreateItemsReportsWhatWasStored(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedS3.HeadObjectFuncMock = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		return &s3.HeadObjectOutput{}, nil
+	}
+
+	mockedDynamodb.GetItemFuncMock = uploadMock
+
+	stored, failed := ulid.New(), ulid.New()
+	expired := ulid.New()
+
+	mockedDynamodb.UpdateItemFuncMock = func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		// The upload expired after it was checked.
+		if params.Key[ID].(*types.AttributeValueMemberS).Value == expired {
+			return nil, &types.ConditionalCheckFailedException{}
+		}
+
+		return &dynamodb.UpdateItemOutput{}, nil
+	}
+
+	mockedDynamodb.BatchWriteItemFuncMock = func(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+		if len(params.RequestItems[DYNAMO_TABLE]) != 2 {
+			t.Errorf("The upload that expired shouldn't get metadata. Result: %+v", params.RequestItems[DYNAMO_TABLE])
+		}
+
+		return nil, errors.New("Dynamodb error")
+	}
+
+	lookups := 0
+
+	// Only one of the items of the failed chunk was stored.
+	mockedDynamodb.BatchGetItemFuncMock = func(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+		lookups++
+
+		if lookups == 1 {
+			return existingMetadataMock()(ctx, params, optFns...)
+		}
+
+		return existingMetadataMock(stored)(ctx, params, optFns...)
+	}
+
+	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
+
+	results, err := serviceHandler.CreateItems(context.TODO(), []dto.MetadataDTOInput{
+		metadataInput(stored),
+		metadataInput(expired),
+		metadataInput(failed),
+	})
+
+	if err != nil {
+		t.Errorf("The result is different from expected. Expected nil. Result: %v", err)
+	}
+
+	expected := fmt.Sprint([]string{BATCH_CREATED, BATCH_FILE_NOT_FOUND, BATCH_FAILED})
+
+	if fmt.Sprint(statuses(results)) != expected {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", statuses(results), expected)
+	}
+}
+
+func TestCreateItemsRefusesAudioRepeatedInBatch(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedS3.HeadObjectFuncMock = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		return &s3.HeadObjectOutput{}, nil
+	}
+
+	mockedDynamodb.BatchGetItemFuncMock = existingMetadataMock()
+
+	mockedDynamodb.GetItemFuncMock = func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+		return &dynamodb.GetItemOutput{
+			Item: map[string]types.AttributeValue{
+				ID:       params.Key[ID],
+				STATUS:   &types.AttributeValueMemberS{Value: UPLOAD_UPLOADED},
+				SHA256:   &types.AttributeValueMemberS{Value: "digest"},
+				"format": &types.AttributeValueMemberS{Value: "mp3"},
+			},
+		}, nil
+	}
+
+	mockedDynamodb.QueryFuncMock = func(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+		return &dynamodb.QueryOutput{}, nil
+	}
+
+	mockedDynamodb.UpdateItemFuncMock = func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		return &dynamodb.UpdateItemOutput{}, nil
+	}
+
+	mockedDynamodb.BatchWriteItemFuncMock = func(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+		return &dynamodb.BatchWriteItemOutput{}, nil
+	}
+
+	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
+
+	first := ulid.New()
+
+	results, err := serviceHandler.CreateItems(context.TODO(), []dto.MetadataDTOInput{
+		metadataInput(first),
+		metadataInput(ulid.New()),
+	})
+
+	if err != nil {
+		t.Errorf("The result is different from expected. Expected nil. Result: %v", err)
+	}
+
+	expected := fmt.Sprint([]string{BATCH_CREATED, BATCH_CONFLICT})
+
+	if fmt.Sprint(statuses(results)) != expected {
+		t.Fatalf("The result is different from expected. Result: %v. Expected: %v", statuses(results), expected)
+	}
+
+	message := (&DuplicateAudioError{ID: first, Match: MATCH_BYTES}).Error()
+
+	if results[1].Message != message {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", results[1].Message, message)
+	}
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/normalize"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	AUTHOR_INDEX = "author-normalized-index"
+	TYPE_INDEX   = "type-index"
+)
+
+type metadataIndex struct {
+	name      string
+	attribute string
+}
+
+// metadataIndexes are the global secondary indexes from template.yaml, in
+// order of preference. Labels are close to unique per insertion, so there is
+// no index for them and a label-only filter falls back to a filtered Scan.
+var metadataIndexes = []metadataIndex{
+	{name: AUTHOR_INDEX, attribute: AUTHOR_NORMALIZED},
+	{name: TYPE_INDEX, attribute: TYPE},
+}
+
+type listQuery struct {
+	index            string
+	scope            string
+	keyCondition     *string
+	filterExpression *string
+	attributeNames   map[string]string
+	attributeValues  map[string]types.AttributeValue
+}
+
+// buildListQuery picks the first index covering one of the filters and turns
+// the remaining ones into a FilterExpression. With no usable index, index is
+// left empty and the caller scans the table. Author and label are matched
+// against their normalized shadow attributes, so "BRASILIA" finds "Brasília".
+func buildListQuery(input dto.MetadataListInput) listQuery {
+	filters := map[string]string{}
+
+	for attribute, value := range map[string]string{
+		AUTHOR_NORMALIZED: normalize.Fold(input.Author),
+		LABEL_NORMALIZED:  normalize.Fold(input.Label),
+		TYPE:              input.Type,
+	} {
+		if value != "" {
+			filters[attribute] = value
+		}
+	}
+
+	if len(filters) == 0 {
+		return listQuery{}
+	}
+
+	query := listQuery{
+		attributeNames:  map[string]string{},
+		attributeValues: map[string]types.AttributeValue{},
+	}
+
+	keyAttribute := ""
+
+	for _, index := range metadataIndexes {
+		if value, ok := filters[index.attribute]; ok {
+			query.index = index.name
+			query.keyCondition = aws.String(query.bind(index.attribute, value))
+			keyAttribute = index.attribute
+			break
+		}
+	}
+
+	var conditions []string
+	scope := []string{query.index}
+
+	for _, attribute := range []string{AUTHOR_NORMALIZED, LABEL_NORMALIZED, TYPE} {
+		value, ok := filters[attribute]
+
+		if !ok {
+			continue
+		}
+
+		scope = append(scope, attribute+"="+value)
+
+		if attribute == keyAttribute {
+			continue
+		}
+
+		conditions = append(conditions, query.bind(attribute, value))
+	}
+
+	if len(conditions) > 0 {
+		query.filterExpression = aws.String(strings.Join(conditions, " AND "))
+	}
+
+	query.scope = strings.Join(scope, "|")
+
+	return query
+}
+
+func (q *listQuery) bind(attribute string, value string) string {
+	q.attributeNames["#"+attribute] = attribute
+	q.attributeValues[":"+attribute] = &types.AttributeValueMemberS{Value: value}
+
+	return fmt.Sprintf("#%s = :%s", attribute, attribute)
+}
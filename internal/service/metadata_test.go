@@ -3,46 +3,87 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
-	"time"
 
 	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
 	"github.com/LucasAndFlores/go_lambdas_project/internal/mocks"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
+func canceledTransaction(codes ...string) error {
+	reasons := make([]types.CancellationReason, 0, len(codes))
+
+	for _, code := range codes {
+		reasons = append(reasons, types.CancellationReason{Code: aws.String(code)})
+	}
+
+	return &types.TransactionCanceledException{CancellationReasons: reasons}
+}
+
+func uploadMock(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{
+		Item: map[string]types.AttributeValue{
+			"id":          &types.AttributeValueMemberS{Value: "test"},
+			"status":      &types.AttributeValueMemberS{Value: UPLOAD_UPLOADED},
+			"duration_ms": &types.AttributeValueMemberN{Value: "2500"},
+			"format":      &types.AttributeValueMemberS{Value: "mp3"},
+		},
+	}, nil
+}
+
 func TestCreateItemSuccessfulResponse(t *testing.T) {
 	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+	mockedDynamodb.GetItemFuncMock = uploadMock
 
-	mockedS3.HeadObjectFuncMock = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
-		time := time.Now()
+	mockedDynamodb.TransactWriteItemsFuncMock = func(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+		put := params.TransactItems[0].Put
+		condition := "attribute_not_exists(#id)"
 
-		return &s3.HeadObjectOutput{
-			LastModified: &time,
-		}, nil
-	}
+		if put == nil || *put.ConditionExpression != condition {
+			t.Fatalf("The condition is different from expected. Result: %v. Expected: %v", put, condition)
+		}
 
-	mockedDynamodb := mocks.MockedDynamoDB{}
+		duration := put.Item["duration_ms"].(*types.AttributeValueMemberN).Value
 
-	mockedDynamodb.PutItemFuncMock = func(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
-		return &dynamodb.PutItemOutput{
-			Attributes: map[string]types.AttributeValue{},
-		}, nil
-	}
+		if duration != "2500" {
+			t.Errorf("The duration is different from expected. Result: %v. Expected: %v", duration, "2500")
+		}
 
-	mockedDynamodb.GetItemFuncMock = func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
-		return &dynamodb.GetItemOutput{
-			Item: nil,
-		}, nil
+		format := put.Item["format"].(*types.AttributeValueMemberS).Value
+
+		if format != "mp3" {
+			t.Errorf("The format is different from expected. Result: %v. Expected: %v", format, "mp3")
+		}
+
+		author := put.Item["author_normalized"].(*types.AttributeValueMemberS).Value
+
+		if author != "sao paulo" {
+			t.Errorf("The normalized author is different from expected. Result: %v. Expected: %v", author, "sao paulo")
+		}
+
+		update := params.TransactItems[1].Update
+		published := update.ExpressionAttributeValues[":published"].(*types.AttributeValueMemberS).Value
+
+		if *update.UpdateExpression != "SET #status = :published" || published != UPLOAD_PUBLISHED {
+			t.Errorf("The upload update is different from expected. Result: %v", *update.UpdateExpression)
+		}
+
+		return &dynamodb.TransactWriteItemsOutput{}, nil
 	}
 
 	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
 
 	metadata := dto.MetadataDTOInput{
+		ID:       "test",
 		FileName: "test",
-		Author:   "test",
+		Author:   "São Paulo",
 		Label:    "123",
 		Words:    "test",
 		Type:     "test",
@@ -56,30 +97,19 @@ func TestCreateItemSuccessfulResponse(t *testing.T) {
 
 }
 
-func TestCreateItemS3HeadObjectError(t *testing.T) {
+func TestCreateItemUploadNotFinished(t *testing.T) {
 	mockedS3 := mocks.MockedS3{}
-
-	mockedS3.HeadObjectFuncMock = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
-		return nil, errors.New("AWS Error")
-	}
-
 	mockedDynamodb := mocks.MockedDynamoDB{}
+	mockedDynamodb.GetItemFuncMock = uploadMock
 
-	mockedDynamodb.PutItemFuncMock = func(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
-		return &dynamodb.PutItemOutput{
-			Attributes: map[string]types.AttributeValue{},
-		}, nil
-	}
-
-	mockedDynamodb.GetItemFuncMock = func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
-		return &dynamodb.GetItemOutput{
-			Item: nil,
-		}, nil
+	mockedDynamodb.TransactWriteItemsFuncMock = func(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+		return nil, canceledTransaction("None", "ConditionalCheckFailed")
 	}
 
 	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
 
 	metadata := dto.MetadataDTOInput{
+		ID:       "test",
 		FileName: "test",
 		Author:   "test",
 		Label:    "123",
@@ -89,91 +119,91 @@ func TestCreateItemS3HeadObjectError(t *testing.T) {
 
 	err := serviceHandler.CreateItem(context.TODO(), metadata)
 
-	expected := errors.New("Filename not found. Unable to complete the operation")
-
-	if err.Error() != expected.Error() {
-		t.Errorf("Result is different from expected. Expected: %v. Result: %v", expected, err)
+	if !errors.Is(err, FileNotFoundErr) {
+		t.Errorf("Result is different from expected. Expected: %v. Result: %v", FileNotFoundErr, err)
 	}
 
 }
 
-func TestCreateItemDynamoDBGetItemError(t *testing.T) {
+func TestCreateItemWithoutUpload(t *testing.T) {
 	mockedS3 := mocks.MockedS3{}
-
-	mockedS3.HeadObjectFuncMock = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
-		time := time.Now()
-
-		return &s3.HeadObjectOutput{
-			LastModified: &time,
-		}, nil
-	}
-
 	mockedDynamodb := mocks.MockedDynamoDB{}
 
-	mockedDynamodb.PutItemFuncMock = func(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
-		return &dynamodb.PutItemOutput{
-			Attributes: map[string]types.AttributeValue{},
-		}, nil
-	}
-
 	mockedDynamodb.GetItemFuncMock = func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
-		return nil, errors.New("Dynamodb error")
+		return &dynamodb.GetItemOutput{}, nil
 	}
 
-	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
-
-	metadata := dto.MetadataDTOInput{
-		FileName: "test",
-		Author:   "test",
-		Label:    "123",
-		Words:    "test",
-		Type:     "test",
+	mockedDynamodb.TransactWriteItemsFuncMock = func(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+		t.Errorf("Nothing should be written without an upload")
+		return &dynamodb.TransactWriteItemsOutput{}, nil
 	}
 
-	err := serviceHandler.CreateItem(context.TODO(), metadata)
+	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
 
-	expected := errors.New("Dynamodb error")
+	err := serviceHandler.CreateItem(context.TODO(), dto.MetadataDTOInput{ID: "test", FileName: "test"})
 
-	if err.Error() != expected.Error() {
-		t.Errorf("Result is different from expected. Expected: %v. Result: %v", expected, err)
+	if !errors.Is(err, FileNotFoundErr) {
+		t.Errorf("Result is different from expected. Expected: %v. Result: %v", FileNotFoundErr, err)
 	}
 
 }
 
-func TestCreateItemConflictError(t *testing.T) {
+func TestCreateItemDuplicateAudio(t *testing.T) {
 	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
 
-	mockedS3.HeadObjectFuncMock = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
-		time := time.Now()
+	mockedDynamodb.GetItemFuncMock = func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+		output, _ := uploadMock(ctx, params, optFns...)
+		output.Item[SHA256] = &types.AttributeValueMemberS{Value: "bytes-hash"}
+		output.Item[PCM_SHA256] = &types.AttributeValueMemberS{Value: "pcm-hash"}
+		return output, nil
+	}
 
-		return &s3.HeadObjectOutput{
-			LastModified: &time,
+	// Only the metadata of the upload itself has the same bytes, as when it
+	// is published again, while another one has the same samples.
+	mockedDynamodb.QueryFuncMock = func(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+		id := "test"
+
+		if *params.IndexName == PCM_SHA256_INDEX {
+			id = "original"
+		}
+
+		return &dynamodb.QueryOutput{
+			Items: []map[string]types.AttributeValue{
+				{"id": &types.AttributeValueMemberS{Value: id}},
+			},
 		}, nil
 	}
 
-	mockedDynamodb := mocks.MockedDynamoDB{}
+	mockedDynamodb.TransactWriteItemsFuncMock = func(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+		t.Errorf("Nothing should be written for a duplicate")
+		return &dynamodb.TransactWriteItemsOutput{}, nil
+	}
 
-	mockedDynamodb.PutItemFuncMock = func(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
-		return &dynamodb.PutItemOutput{
-			Attributes: map[string]types.AttributeValue{},
-		}, nil
+	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
+
+	err := serviceHandler.CreateItem(context.TODO(), dto.MetadataDTOInput{ID: "test", FileName: "test"})
+
+	var duplicateErr *DuplicateAudioError
+
+	if !errors.As(err, &duplicateErr) || duplicateErr.ID != "original" || duplicateErr.Match != MATCH_PCM {
+		t.Errorf("Result is different from expected. Expected: %v. Result: %v", DuplicateAudioErr, err)
 	}
+}
 
-	mockedDynamodb.GetItemFuncMock = func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
-		return &dynamodb.GetItemOutput{
-			Item: map[string]types.AttributeValue{
-				"filename": &types.AttributeValueMemberS{Value: "test"},
-				"author":   &types.AttributeValueMemberS{Value: "test"},
-				"label":    &types.AttributeValueMemberS{Value: "123"},
-				"words":    &types.AttributeValueMemberS{Value: "test"},
-				"type":     &types.AttributeValueMemberS{Value: "test"},
-			},
-		}, nil
+func TestCreateItemConflictError(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+	mockedDynamodb.GetItemFuncMock = uploadMock
+
+	mockedDynamodb.TransactWriteItemsFuncMock = func(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+		return nil, canceledTransaction("ConditionalCheckFailed", "None")
 	}
 
 	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
 
 	metadata := dto.MetadataDTOInput{
+		ID:       "test",
 		FileName: "test",
 		Author:   "test",
 		Label:    "123",
@@ -189,34 +219,25 @@ func TestCreateItemConflictError(t *testing.T) {
 		t.Errorf("Result is different from expected. Expected: %v. Result: %v", expected, err)
 	}
 
+	if !errors.Is(err, ConfilctErr) {
+		t.Errorf("Result is different from expected. Expected: %v. Result: %v", ConfilctErr, err)
+	}
+
 }
 
-func TestCreateDynamoDBPutItemError(t *testing.T) {
+func TestCreateDynamoDBTransactionError(t *testing.T) {
 	mockedS3 := mocks.MockedS3{}
-
-	mockedS3.HeadObjectFuncMock = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
-		time := time.Now()
-
-		return &s3.HeadObjectOutput{
-			LastModified: &time,
-		}, nil
-	}
-
 	mockedDynamodb := mocks.MockedDynamoDB{}
+	mockedDynamodb.GetItemFuncMock = uploadMock
 
-	mockedDynamodb.PutItemFuncMock = func(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	mockedDynamodb.TransactWriteItemsFuncMock = func(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
 		return nil, errors.New("Dynamodb error")
 	}
 
-	mockedDynamodb.GetItemFuncMock = func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
-		return &dynamodb.GetItemOutput{
-			Item: nil,
-		}, nil
-	}
-
 	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
 
 	metadata := dto.MetadataDTOInput{
+		ID:       "test",
 		FileName: "test",
 		Author:   "test",
 		Label:    "123",
@@ -242,11 +263,11 @@ func TestListAllItemsSuccessfulResponse(t *testing.T) {
 		return &dynamodb.ScanOutput{
 			Items: []map[string]types.AttributeValue{
 				{
-					"filename": &types.AttributeValueMemberS{Value: "test"},
-					"author":   &types.AttributeValueMemberS{Value: "test"},
-					"label":    &types.AttributeValueMemberS{Value: "123"},
-					"words":    &types.AttributeValueMemberS{Value: "test"},
-					"type":     &types.AttributeValueMemberS{Value: "test"},
+					"id":     &types.AttributeValueMemberS{Value: "test"},
+					"author": &types.AttributeValueMemberS{Value: "test"},
+					"label":  &types.AttributeValueMemberS{Value: "123"},
+					"words":  &types.AttributeValueMemberS{Value: "test"},
+					"type":   &types.AttributeValueMemberS{Value: "test"},
 				},
 			},
 		}, nil
@@ -254,24 +275,28 @@ func TestListAllItemsSuccessfulResponse(t *testing.T) {
 
 	expected := []dto.MetadataDTOOutput{
 		{
-			FileName: "test",
-			Author:   "test",
-			Label:    "123",
-			Words:    "test",
-			Type:     "test",
+			ID:     "test",
+			Author: "test",
+			Label:  "123",
+			Words:  "test",
+			Type:   "test",
 		},
 	}
 
 	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
 
-	metadata, err := serviceHandler.ListAllItems(context.TODO())
+	output, err := serviceHandler.ListAllItems(context.TODO(), dto.MetadataListInput{})
 
 	if err != nil {
 		t.Errorf("Expected nil but received an error. Error: %v", err)
 	}
 
-	if metadata[0] != expected[0] {
-		t.Errorf("The result is different from expected.Result: %v. Expected: %v", metadata[0], expected[0])
+	if output.Metadata[0] != expected[0] {
+		t.Errorf("The result is different from expected.Result: %v. Expected: %v", output.Metadata[0], expected[0])
+	}
+
+	if output.NextCursor != "" {
+		t.Errorf("Expected an empty cursor but received one. Cursor: %v", output.NextCursor)
 	}
 }
 
@@ -287,10 +312,10 @@ func TestListAllItemsDynamoDBError(t *testing.T) {
 
 	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
 
-	metadata, err := serviceHandler.ListAllItems(context.TODO())
+	output, err := serviceHandler.ListAllItems(context.TODO(), dto.MetadataListInput{})
 
-	if len(metadata) != 0 {
-		t.Errorf("Expected an empty array but received an item. Item: %v", metadata)
+	if len(output.Metadata) != 0 {
+		t.Errorf("Expected an empty array but received an item. Item: %v", output.Metadata)
 	}
 
 	if err.Error() != expected.Error() {
@@ -310,13 +335,585 @@ func TestListAllItemsEmptyDynamoResponse(t *testing.T) {
 
 	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
 
-	metadata, err := serviceHandler.ListAllItems(context.TODO())
+	output, err := serviceHandler.ListAllItems(context.TODO(), dto.MetadataListInput{})
+
+	if err != nil {
+		t.Errorf("Expected nil but received an error. Error: %v", err)
+	}
+
+	if len(output.Metadata) != 0 {
+		t.Errorf("Expected an empty array but received an item. Item: %v", output.Metadata)
+	}
+}
+
+func TestListAllItemsReturnsCursorForNextPage(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	lastKey := map[string]types.AttributeValue{
+		"id": &types.AttributeValueMemberS{Value: "test"},
+	}
+
+	mockedDynamodb.ScanFuncMock = func(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+		if *params.Limit != 10 {
+			t.Errorf("The limit is different from expected. Result: %v. Expected: %v", *params.Limit, 10)
+		}
+
+		if params.ExclusiveStartKey == nil {
+			return &dynamodb.ScanOutput{LastEvaluatedKey: lastKey}, nil
+		}
+
+		startKey := params.ExclusiveStartKey["id"].(*types.AttributeValueMemberS).Value
+
+		if startKey != "test" {
+			t.Errorf("The start key is different from expected. Result: %v. Expected: %v", startKey, "test")
+		}
+
+		return &dynamodb.ScanOutput{}, nil
+	}
+
+	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
+
+	firstPage, err := serviceHandler.ListAllItems(context.TODO(), dto.MetadataListInput{Limit: 10})
+
+	if err != nil {
+		t.Errorf("Expected nil but received an error. Error: %v", err)
+	}
+
+	if firstPage.NextCursor == "" {
+		t.Errorf("Expected a cursor but received an empty one")
+	}
+
+	secondPage, err := serviceHandler.ListAllItems(context.TODO(), dto.MetadataListInput{Limit: 10, Cursor: firstPage.NextCursor})
+
+	if err != nil {
+		t.Errorf("Expected nil but received an error. Error: %v", err)
+	}
+
+	if secondPage.NextCursor != "" {
+		t.Errorf("Expected an empty cursor but received one. Cursor: %v", secondPage.NextCursor)
+	}
+}
+
+func TestListAllItemsTamperedCursor(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	token, _ := encodeCursor(map[string]types.AttributeValue{
+		"id": &types.AttributeValueMemberS{Value: "test"},
+	}, "")
+
+	forged, _ := encodeCursor(map[string]types.AttributeValue{
+		"id": &types.AttributeValueMemberS{Value: "other"},
+	}, "")
+
+	payload, _, _ := strings.Cut(forged, ".")
+	_, signature, _ := strings.Cut(token, ".")
+
+	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
+
+	_, err := serviceHandler.ListAllItems(context.TODO(), dto.MetadataListInput{Cursor: payload + "." + signature})
+
+	if !errors.Is(err, InvalidCursorErr) {
+		t.Errorf("The result is different from expected.Result: %v. Expected: %v", err, InvalidCursorErr)
+	}
+}
+
+func TestListAllItemsFetchEverythingFollowsLastEvaluatedKey(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	calls := 0
+
+	mockedDynamodb.ScanFuncMock = func(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+		calls++
+
+		if params.Limit != nil {
+			t.Errorf("Expected no limit when fetching everything. Result: %v", *params.Limit)
+		}
+
+		output := &dynamodb.ScanOutput{
+			Items: []map[string]types.AttributeValue{
+				{"id": &types.AttributeValueMemberS{Value: fmt.Sprintf("test-%d", calls)}},
+			},
+		}
+
+		if calls < 3 {
+			output.LastEvaluatedKey = output.Items[0]
+		}
+
+		return output, nil
+	}
+
+	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
+
+	output, err := serviceHandler.ListAllItems(context.TODO(), dto.MetadataListInput{All: true})
+
+	if err != nil {
+		t.Errorf("Expected nil but received an error. Error: %v", err)
+	}
+
+	if len(output.Metadata) != 3 {
+		t.Errorf("The result is different from expected.Result: %v. Expected: %v", len(output.Metadata), 3)
+	}
+
+	if output.NextCursor != "" {
+		t.Errorf("Expected an empty cursor but received one. Cursor: %v", output.NextCursor)
+	}
+}
+
+func TestListAllItemsByAuthorQueriesIndex(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedDynamodb.ScanFuncMock = func(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+		t.Errorf("Scan shouldn't be called when an index covers the filter")
+		return &dynamodb.ScanOutput{}, nil
+	}
+
+	mockedDynamodb.QueryFuncMock = func(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+		if *params.IndexName != AUTHOR_INDEX {
+			t.Errorf("The index is different from expected. Result: %v. Expected: %v", *params.IndexName, AUTHOR_INDEX)
+		}
+
+		if *params.KeyConditionExpression != "#author_normalized = :author_normalized" {
+			t.Errorf("The key condition is different from expected. Result: %v", *params.KeyConditionExpression)
+		}
+
+		author := params.ExpressionAttributeValues[":author_normalized"].(*types.AttributeValueMemberS).Value
+
+		if author != "joao" {
+			t.Errorf("The author is different from expected. Result: %v. Expected: %v", author, "joao")
+		}
+
+		if *params.FilterExpression != "#type = :type" {
+			t.Errorf("The filter is different from expected. Result: %v", *params.FilterExpression)
+		}
+
+		return &dynamodb.QueryOutput{
+			Items: []map[string]types.AttributeValue{
+				{
+					"id":     &types.AttributeValueMemberS{Value: "test"},
+					"author": &types.AttributeValueMemberS{Value: "speaker"},
+					"type":   &types.AttributeValueMemberS{Value: "laugh"},
+				},
+			},
+		}, nil
+	}
+
+	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
+
+	output, err := serviceHandler.ListAllItems(context.TODO(), dto.MetadataListInput{Author: "JOÃO", Type: "laugh"})
 
 	if err != nil {
 		t.Errorf("Expected nil but received an error. Error: %v", err)
 	}
 
-	if len(metadata) != 0 {
-		t.Errorf("Expected an empty array but received an item. Item: %v", metadata)
+	if len(output.Metadata) != 1 || output.Metadata[0].Author != "speaker" {
+		t.Errorf("The result is different from expected.Result: %v", output.Metadata)
+	}
+}
+
+func TestListAllItemsByLabelFallsBackToScan(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedDynamodb.ScanFuncMock = func(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+		if *params.FilterExpression != "#label_normalized = :label_normalized" {
+			t.Errorf("The filter is different from expected. Result: %v", *params.FilterExpression)
+		}
+
+		label := params.ExpressionAttributeValues[":label_normalized"].(*types.AttributeValueMemberS).Value
+
+		if label != "delirio" {
+			t.Errorf("The label is different from expected. Result: %v. Expected: %v", label, "delirio")
+		}
+
+		return &dynamodb.ScanOutput{}, nil
+	}
+
+	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
+
+	_, err := serviceHandler.ListAllItems(context.TODO(), dto.MetadataListInput{Label: "Delírio"})
+
+	if err != nil {
+		t.Errorf("Expected nil but received an error. Error: %v", err)
+	}
+}
+
+func TestListAllItemsCursorFromAnotherFilter(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedDynamodb.QueryFuncMock = func(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+		return &dynamodb.QueryOutput{
+			LastEvaluatedKey: map[string]types.AttributeValue{
+				"id":     &types.AttributeValueMemberS{Value: "test"},
+				"author": &types.AttributeValueMemberS{Value: "speaker"},
+			},
+		}, nil
+	}
+
+	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
+
+	output, err := serviceHandler.ListAllItems(context.TODO(), dto.MetadataListInput{Author: "speaker"})
+
+	if err != nil {
+		t.Errorf("Expected nil but received an error. Error: %v", err)
+	}
+
+	_, err = serviceHandler.ListAllItems(context.TODO(), dto.MetadataListInput{Author: "other", Cursor: output.NextCursor})
+
+	if !errors.Is(err, InvalidCursorErr) {
+		t.Errorf("The result is different from expected.Result: %v. Expected: %v", err, InvalidCursorErr)
+	}
+}
+
+func TestGetItemSuccessfulResponse(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedDynamodb.GetItemFuncMock = func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+		return &dynamodb.GetItemOutput{
+			Item: map[string]types.AttributeValue{
+				"id":     &types.AttributeValueMemberS{Value: "test"},
+				"author": &types.AttributeValueMemberS{Value: "test"},
+				"label":  &types.AttributeValueMemberS{Value: "123"},
+				"words":  &types.AttributeValueMemberS{Value: "test"},
+				"type":   &types.AttributeValueMemberS{Value: "test"},
+			},
+		}, nil
+	}
+
+	expected := dto.MetadataDTOOutput{
+		ID:     "test",
+		Author: "test",
+		Label:  "123",
+		Words:  "test",
+		Type:   "test",
+	}
+
+	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
+
+	metadata, err := serviceHandler.GetItem(context.TODO(), "test")
+
+	if err != nil {
+		t.Errorf("Expected nil but received an error. Error: %v", err)
+	}
+
+	if metadata != expected {
+		t.Errorf("The result is different from expected.Result: %v. Expected: %v", metadata, expected)
+	}
+}
+
+func TestGetItemNotFound(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedDynamodb.GetItemFuncMock = func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+		return &dynamodb.GetItemOutput{
+			Item: nil,
+		}, nil
+	}
+
+	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
+
+	_, err := serviceHandler.GetItem(context.TODO(), "test")
+
+	if !errors.Is(err, ItemNotFoundErr) {
+		t.Errorf("The result is different from expected.Result: %v. Expected: %v", err, ItemNotFoundErr)
+	}
+}
+
+func TestGetItemDynamoDBError(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedDynamodb.GetItemFuncMock = func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+		return nil, errors.New("Dynamodb error")
+	}
+
+	expected := errors.New("Dynamodb error")
+
+	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
+
+	_, err := serviceHandler.GetItem(context.TODO(), "test")
+
+	if err.Error() != expected.Error() {
+		t.Errorf("The result is different from expected.Result: %v. Expected: %v", err, expected)
+	}
+}
+
+func TestUpdateItemSuccessfulResponse(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedDynamodb.UpdateItemFuncMock = func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		expression := "SET #author = :author, #author_normalized = :author_normalized, #filename = :filename, #label = :label, #label_normalized = :label_normalized, #type = :type, #words = :words"
+
+		if *params.UpdateExpression != expression {
+			t.Errorf("The update expression is different from expected. Result: %v. Expected: %v", *params.UpdateExpression, expression)
+		}
+
+		return &dynamodb.UpdateItemOutput{
+			Attributes: map[string]types.AttributeValue{
+				"id":     &types.AttributeValueMemberS{Value: "test"},
+				"author": &types.AttributeValueMemberS{Value: "new author"},
+				"label":  &types.AttributeValueMemberS{Value: "123"},
+				"words":  &types.AttributeValueMemberS{Value: "test"},
+				"type":   &types.AttributeValueMemberS{Value: "test"},
+			},
+		}, nil
+	}
+
+	expected := dto.MetadataDTOOutput{
+		ID:     "test",
+		Author: "new author",
+		Label:  "123",
+		Words:  "test",
+		Type:   "test",
+	}
+
+	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
+
+	metadata, err := serviceHandler.UpdateItem(context.TODO(), "test", dto.MetadataDTOInput{
+		ID:       "test",
+		FileName: "test",
+		Author:   "new author",
+		Label:    "123",
+		Words:    "test",
+		Type:     "test",
+	})
+
+	if err != nil {
+		t.Errorf("Expected nil but received an error. Error: %v", err)
+	}
+
+	if metadata != expected {
+		t.Errorf("The result is different from expected.Result: %v. Expected: %v", metadata, expected)
+	}
+}
+
+func TestPatchItemOnlyUpdatesSuppliedFields(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedDynamodb.UpdateItemFuncMock = func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		expression := "SET #label = :label, #label_normalized = :label_normalized"
+
+		if *params.UpdateExpression != expression {
+			t.Errorf("The update expression is different from expected. Result: %v. Expected: %v", *params.UpdateExpression, expression)
+		}
+
+		if len(params.ExpressionAttributeValues) != 2 {
+			t.Errorf("Expected only the label and its shadow attribute. Result: %v", params.ExpressionAttributeValues)
+		}
+
+		return &dynamodb.UpdateItemOutput{
+			Attributes: map[string]types.AttributeValue{
+				"id":    &types.AttributeValueMemberS{Value: "test"},
+				"label": &types.AttributeValueMemberS{Value: "fixed"},
+			},
+		}, nil
+	}
+
+	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
+
+	label := "fixed"
+
+	metadata, err := serviceHandler.PatchItem(context.TODO(), "test", dto.MetadataDTOPatchInput{Label: &label})
+
+	if err != nil {
+		t.Errorf("Expected nil but received an error. Error: %v", err)
+	}
+
+	if metadata.Label != label {
+		t.Errorf("The result is different from expected.Result: %v. Expected: %v", metadata.Label, label)
+	}
+}
+
+func TestPatchItemWithoutFields(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
+
+	_, err := serviceHandler.PatchItem(context.TODO(), "test", dto.MetadataDTOPatchInput{})
+
+	if !errors.Is(err, NothingToUpdateErr) {
+		t.Errorf("The result is different from expected.Result: %v. Expected: %v", err, NothingToUpdateErr)
+	}
+}
+
+func TestUpdateItemNotFound(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedDynamodb.UpdateItemFuncMock = func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+
+	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
+
+	words := "test"
+
+	_, err := serviceHandler.PatchItem(context.TODO(), "test", dto.MetadataDTOPatchInput{Words: &words})
+
+	if !errors.Is(err, ItemNotFoundErr) {
+		t.Errorf("The result is different from expected.Result: %v. Expected: %v", err, ItemNotFoundErr)
+	}
+}
+
+func TestDeleteItemRemovesMetadataAndObject(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	objectDeleted := false
+	waveformDeleted := false
+
+	mockedDynamodb.DeleteItemFuncMock = func(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+		return &dynamodb.DeleteItemOutput{
+			Attributes: map[string]types.AttributeValue{
+				"id": &types.AttributeValueMemberS{Value: "test"},
+			},
+		}, nil
+	}
+
+	mockedS3.HeadObjectFuncMock = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		return &s3.HeadObjectOutput{}, nil
+	}
+
+	mockedS3.DeleteObjectFuncMock = func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+		objectDeleted = objectDeleted || *params.Key == "test"
+		waveformDeleted = waveformDeleted || *params.Key == "waveforms/test.json"
+		return &s3.DeleteObjectOutput{}, nil
+	}
+
+	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
+
+	err := serviceHandler.DeleteItem(context.TODO(), "test", false)
+
+	if err != nil {
+		t.Errorf("Expected nil but received an error. Error: %v", err)
+	}
+
+	if !objectDeleted || !waveformDeleted {
+		t.Errorf("Expected the S3 object and its waveform to be deleted")
+	}
+}
+
+func TestDeleteItemMetadataOnly(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedDynamodb.DeleteItemFuncMock = func(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+		return &dynamodb.DeleteItemOutput{
+			Attributes: map[string]types.AttributeValue{
+				"id": &types.AttributeValueMemberS{Value: "test"},
+			},
+		}, nil
+	}
+
+	mockedS3.DeleteObjectFuncMock = func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+		t.Errorf("The S3 object shouldn't be deleted")
+		return &s3.DeleteObjectOutput{}, nil
+	}
+
+	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
+
+	err := serviceHandler.DeleteItem(context.TODO(), "test", true)
+
+	if err != nil {
+		t.Errorf("Expected nil but received an error. Error: %v", err)
+	}
+}
+
+func TestDeleteItemOnlyObjectLeft(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	objectDeleted := false
+	waveformDeleted := false
+
+	mockedDynamodb.DeleteItemFuncMock = func(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+		return &dynamodb.DeleteItemOutput{}, nil
+	}
+
+	mockedS3.HeadObjectFuncMock = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		return &s3.HeadObjectOutput{}, nil
+	}
+
+	mockedS3.DeleteObjectFuncMock = func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+		objectDeleted = objectDeleted || *params.Key == "test"
+		waveformDeleted = waveformDeleted || *params.Key == "waveforms/test.json"
+		return &s3.DeleteObjectOutput{}, nil
+	}
+
+	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
+
+	err := serviceHandler.DeleteItem(context.TODO(), "test", false)
+
+	if err != nil {
+		t.Errorf("Expected nil but received an error. Error: %v", err)
+	}
+
+	if !objectDeleted || !waveformDeleted {
+		t.Errorf("Expected the S3 object and its waveform to be deleted")
+	}
+}
+
+func TestDeleteItemNothingToDelete(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedDynamodb.DeleteItemFuncMock = func(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+		return &dynamodb.DeleteItemOutput{}, nil
+	}
+
+	mockedS3.HeadObjectFuncMock = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		return nil, &s3types.NotFound{}
+	}
+
+	mockedS3.DeleteObjectFuncMock = func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+		return &s3.DeleteObjectOutput{}, nil
+	}
+
+	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
+
+	err := serviceHandler.DeleteItem(context.TODO(), "test", false)
+
+	if !errors.Is(err, ItemNotFoundErr) {
+		t.Errorf("The result is different from expected.Result: %v. Expected: %v", err, ItemNotFoundErr)
+	}
+}
+
+func TestDeleteItemS3Error(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedDynamodb.DeleteItemFuncMock = func(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+		return &dynamodb.DeleteItemOutput{
+			Attributes: map[string]types.AttributeValue{
+				"id": &types.AttributeValueMemberS{Value: "test"},
+			},
+		}, nil
+	}
+
+	mockedS3.HeadObjectFuncMock = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		return nil, errors.New("AWS Error")
+	}
+
+	mockedS3.DeleteObjectFuncMock = func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+		return &s3.DeleteObjectOutput{}, nil
+	}
+
+	expected := errors.New("AWS Error")
+
+	serviceHandler := NewMetadataService(mockedS3, mockedDynamodb)
+
+	err := serviceHandler.DeleteItem(context.TODO(), "test", false)
+
+	if err.Error() != expected.Error() {
+		t.Errorf("The result is different from expected.Result: %v. Expected: %v", err, expected)
 	}
 }
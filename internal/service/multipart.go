@@ -0,0 +1,333 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/entity"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/ulid"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+var MULTIPART_TABLE = os.Getenv("MULTIPART_TABLE")
+
+const (
+	PARTS = "parts"
+
+	MULTIPART_PENDING   = "pending"
+	MULTIPART_COMPLETED = "completed"
+	MULTIPART_ABORTED   = "aborted"
+
+	// PART_SIZE keeps every part small enough to be retried on a mobile
+	// network, while MAX_EPISODE_SIZE stays far under the 10000 parts S3
+	// allows.
+	PART_SIZE        int64 = 8 << 20
+	MAX_EPISODE_SIZE       = dto.MAX_EPISODE_SIZE
+
+	// MULTIPART_EXPIRATION matches the lifecycle rule of the bucket, which
+	// aborts the uploads left incomplete.
+	MULTIPART_EXPIRATION = 7 * 24 * time.Hour
+)
+
+var MultipartNotFoundErr = errors.New("Multipart upload not found")
+var MultipartNotPendingErr = errors.New("The multipart upload is not pending")
+var PartOutOfRangeErr = errors.New("The part number is out of range")
+var MissingPartsErr = errors.New("Some parts are missing or don't match the uploaded ones")
+
+// CreateMultipartUpload starts uploading an episode of size bytes in parts
+// of PART_SIZE, returning the URLs of every part.
+func (s *AudioService) CreateMultipartUpload(ctx context.Context, size int64, options dto.PresignOptions) (dto.MultipartDTOOutput, error) {
+	id := ulid.New()
+
+	output, err := s.s3.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(BUCKET_NAME),
+		Key:         aws.String(EpisodeKey(id)),
+		ContentType: aws.String("audio/wav"),
+	})
+
+	if err != nil {
+		log.Printf("Error starting the multipart upload of %s/%s: %s", BUCKET_NAME, EpisodeKey(id), err.Error())
+		return dto.MultipartDTOOutput{}, err
+	}
+
+	upload := entity.MultipartUpload{
+		ID:        id,
+		UploadID:  aws.ToString(output.UploadId),
+		Size:      size,
+		PartSize:  PART_SIZE,
+		Parts:     map[string]string{},
+		Status:    MULTIPART_PENDING,
+		ExpiresAt: time.Now().Add(MULTIPART_EXPIRATION).Unix(),
+	}
+
+	item, err := attributevalue.MarshalMap(upload)
+
+	if err != nil {
+		log.Printf("An error occurred when tried use attributevalue. Error: %v", err)
+		return dto.MultipartDTOOutput{}, err
+	}
+
+	_, err = s.dynamo.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                aws.String(MULTIPART_TABLE),
+		Item:                     item,
+		ConditionExpression:      aws.String("attribute_not_exists(#id)"),
+		ExpressionAttributeNames: map[string]string{"#id": ID},
+	})
+
+	if err != nil {
+		log.Printf("Error when trying to use putItem method: %s", err)
+		return dto.MultipartDTOOutput{}, err
+	}
+
+	return s.multipartProgress(ctx, upload, options)
+}
+
+// GetMultipartUpload tells which parts were uploaded, along with fresh URLs
+// for the others, so an interrupted upload resumes where it stopped.
+func (s *AudioService) GetMultipartUpload(ctx context.Context, id string, options dto.PresignOptions) (dto.MultipartDTOOutput, error) {
+	upload, err := s.getMultipart(ctx, id)
+
+	if err != nil {
+		return dto.MultipartDTOOutput{}, err
+	}
+
+	return s.multipartProgress(ctx, upload, options)
+}
+
+func (s *AudioService) multipartProgress(ctx context.Context, upload entity.MultipartUpload, options dto.PresignOptions) (dto.MultipartDTOOutput, error) {
+	progress := upload.ConvertToDTO()
+
+	if upload.Status != MULTIPART_PENDING {
+		return progress, nil
+	}
+
+	for number := int32(1); number <= upload.PartCount(); number++ {
+		if _, ok := upload.Parts[strconv.Itoa(int(number))]; ok {
+			continue
+		}
+
+		request, err := s.s3PresignedAPI.PresignUploadPart(ctx, &s3.UploadPartInput{
+			Bucket:        aws.String(BUCKET_NAME),
+			Key:           aws.String(EpisodeKey(upload.ID)),
+			UploadId:      aws.String(upload.UploadID),
+			PartNumber:    aws.Int32(number),
+			ContentLength: aws.Int64(upload.PartLength(number)),
+		}, expires(options))
+
+		if err != nil {
+			log.Println("An error happened when tried to pre sign a part URL", err)
+			return dto.MultipartDTOOutput{}, err
+		}
+
+		progress.Parts = append(progress.Parts, dto.PartDTO{
+			Number: number,
+			Size:   upload.PartLength(number),
+			Url:    request.URL,
+		})
+	}
+
+	return progress, nil
+}
+
+// RecordPart keeps the ETag of an uploaded part. Parts are recorded on their
+// own key of the parts map, so parts uploaded at once don't overwrite each
+// other.
+func (s *AudioService) RecordPart(ctx context.Context, id string, number int32, etag string) error {
+	upload, err := s.getMultipart(ctx, id)
+
+	if err != nil {
+		return err
+	}
+
+	if number < 1 || number > upload.PartCount() {
+		return PartOutOfRangeErr
+	}
+
+	_, err = s.dynamo.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(MULTIPART_TABLE),
+		Key: map[string]types.AttributeValue{
+			ID: &types.AttributeValueMemberS{Value: id},
+		},
+		UpdateExpression:         aws.String("SET #parts.#number = :etag"),
+		ConditionExpression:      aws.String("#status = :pending"),
+		ExpressionAttributeNames: map[string]string{"#parts": PARTS, "#number": strconv.Itoa(int(number)), "#status": STATUS},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":etag":    &types.AttributeValueMemberS{Value: etag},
+			":pending": &types.AttributeValueMemberS{Value: MULTIPART_PENDING},
+		},
+	})
+
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+
+		if errors.As(err, &conditionErr) {
+			return MultipartNotPendingErr
+		}
+
+		log.Printf("An error occurred when tried to record the part %d of %s. Error: %v", number, id, err)
+		return err
+	}
+
+	return nil
+}
+
+// CompleteMultipartUpload joins the recorded parts into the episode. A
+// completed upload is left as is, so the request can be retried.
+func (s *AudioService) CompleteMultipartUpload(ctx context.Context, id string) error {
+	upload, err := s.getMultipart(ctx, id)
+
+	if err != nil {
+		return err
+	}
+
+	switch upload.Status {
+	case MULTIPART_COMPLETED:
+		return nil
+	case MULTIPART_ABORTED:
+		return MultipartNotPendingErr
+	}
+
+	completed := upload.CompletedParts()
+
+	if len(completed) != int(upload.PartCount()) {
+		return MissingPartsErr
+	}
+
+	parts := make([]s3types.CompletedPart, 0, len(completed))
+
+	for _, number := range completed {
+		parts = append(parts, s3types.CompletedPart{
+			PartNumber: aws.Int32(number),
+			ETag:       aws.String(upload.Parts[strconv.Itoa(int(number))]),
+		})
+	}
+
+	_, err = s.s3.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(BUCKET_NAME),
+		Key:             aws.String(EpisodeKey(id)),
+		UploadId:        aws.String(upload.UploadID),
+		MultipartUpload: &s3types.CompletedMultipartUpload{Parts: parts},
+	})
+
+	if err != nil {
+		var apiErr smithy.APIError
+
+		if errors.As(err, &apiErr) {
+			switch apiErr.ErrorCode() {
+			case "InvalidPart", "InvalidPartOrder", "EntityTooSmall":
+				return MissingPartsErr
+			case "NoSuchUpload":
+				return MultipartNotPendingErr
+			}
+		}
+
+		log.Printf("Error completing the multipart upload of %s/%s: %s", BUCKET_NAME, EpisodeKey(id), err.Error())
+		return err
+	}
+
+	return s.finishMultipart(ctx, id, MULTIPART_COMPLETED)
+}
+
+// AbortMultipartUpload drops the parts uploaded so far. Aborting an upload
+// twice is harmless.
+func (s *AudioService) AbortMultipartUpload(ctx context.Context, id string) error {
+	upload, err := s.getMultipart(ctx, id)
+
+	if err != nil {
+		return err
+	}
+
+	switch upload.Status {
+	case MULTIPART_ABORTED:
+		return nil
+	case MULTIPART_COMPLETED:
+		return MultipartNotPendingErr
+	}
+
+	_, err = s.s3.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(BUCKET_NAME),
+		Key:      aws.String(EpisodeKey(id)),
+		UploadId: aws.String(upload.UploadID),
+	})
+
+	var noSuchUpload *s3types.NoSuchUpload
+
+	if err != nil && !errors.As(err, &noSuchUpload) {
+		log.Printf("Error aborting the multipart upload of %s/%s: %s", BUCKET_NAME, EpisodeKey(id), err.Error())
+		return err
+	}
+
+	return s.finishMultipart(ctx, id, MULTIPART_ABORTED)
+}
+
+func (s *AudioService) finishMultipart(ctx context.Context, id string, status string) error {
+	_, err := s.dynamo.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(MULTIPART_TABLE),
+		Key: map[string]types.AttributeValue{
+			ID: &types.AttributeValueMemberS{Value: id},
+		},
+		UpdateExpression:         aws.String("SET #status = :status"),
+		ConditionExpression:      aws.String("#status = :pending"),
+		ExpressionAttributeNames: map[string]string{"#status": STATUS},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":status":  &types.AttributeValueMemberS{Value: status},
+			":pending": &types.AttributeValueMemberS{Value: MULTIPART_PENDING},
+		},
+	})
+
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+
+		if errors.As(err, &conditionErr) {
+			return MultipartNotPendingErr
+		}
+
+		log.Printf("An error occurred when tried to mark the multipart upload %s as %s. Error: %v", id, status, err)
+		return err
+	}
+
+	return nil
+}
+
+// getMultipart reads consistently, since parts are recorded right before
+// the upload is completed.
+func (s *AudioService) getMultipart(ctx context.Context, id string) (entity.MultipartUpload, error) {
+	output, err := s.dynamo.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(MULTIPART_TABLE),
+		Key: map[string]types.AttributeValue{
+			ID: &types.AttributeValueMemberS{Value: id},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+
+	if err != nil {
+		log.Printf("Error when tried to getItem from dynamoDB: %s", err)
+		return entity.MultipartUpload{}, err
+	}
+
+	if output.Item == nil {
+		return entity.MultipartUpload{}, MultipartNotFoundErr
+	}
+
+	var upload entity.MultipartUpload
+
+	err = attributevalue.UnmarshalMap(output.Item, &upload)
+
+	if err != nil {
+		log.Printf("An error occurred when tried use attributevalue. Error: %v", err)
+		return entity.MultipartUpload{}, err
+	}
+
+	return upload, nil
+}
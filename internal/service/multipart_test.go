@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/mocks"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// multipartMock is an upload of 20 MB, three parts of which the first was
+// uploaded.
+func multipartMock(status string) func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+		return &dynamodb.GetItemOutput{
+			Item: map[string]types.AttributeValue{
+				"id":        &types.AttributeValueMemberS{Value: "episode"},
+				"upload_id": &types.AttributeValueMemberS{Value: "upload"},
+				"size":      &types.AttributeValueMemberN{Value: "20971520"},
+				"part_size": &types.AttributeValueMemberN{Value: "8388608"},
+				"status":    &types.AttributeValueMemberS{Value: status},
+				"parts": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+					"1": &types.AttributeValueMemberS{Value: `"etag1"`},
+				}},
+			},
+		}, nil
+	}
+}
+
+func partURLMock(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+	return &v4.PresignedHTTPRequest{URL: "test.com/part", SignedHeader: http.Header{}, Method: "PUT"}, nil
+}
+
+func TestCreateMultipartUploadPresignsEveryPart(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedS3.CreateMultipartUploadFuncMock = func(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+		return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload")}, nil
+	}
+
+	mockedDynamodb.PutItemFuncMock = func(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+		// Parts are later set on their own key, which needs the map to exist.
+		if _, ok := params.Item[PARTS].(*types.AttributeValueMemberM); !ok {
+			t.Errorf("The parts should be stored as a map. Result: %#v", params.Item[PARTS])
+		}
+
+		return &dynamodb.PutItemOutput{}, nil
+	}
+
+	var sizes []int64
+
+	preSigned := mocks.MockedPresignedClient{}
+
+	preSigned.PresignUploadPartFuncMock = func(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+		sizes = append(sizes, aws.ToInt64(params.ContentLength))
+		return partURLMock(ctx, params, optFns...)
+	}
+
+	serviceHandler := NewAudioService(preSigned, mockedS3, mockedDynamodb)
+
+	upload, err := serviceHandler.CreateMultipartUpload(context.TODO(), 20<<20, dto.PresignOptions{})
+
+	if err != nil {
+		t.Errorf("The result is different from expected. Expected nil. Result: %v", err)
+	}
+
+	if upload.PartCount != 3 || len(upload.Parts) != 3 || upload.Status != MULTIPART_PENDING {
+		t.Errorf("The result is different from expected. Result: %+v", upload)
+	}
+
+	if len(sizes) != 3 || sizes[0] != 8<<20 || sizes[2] != 4<<20 {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", sizes, []int64{8 << 20, 8 << 20, 4 << 20})
+	}
+}
+
+func TestGetMultipartUploadResumes(t *testing.T) {
+	mockedDynamodb := mocks.MockedDynamoDB{}
+	mockedDynamodb.GetItemFuncMock = multipartMock(MULTIPART_PENDING)
+
+	preSigned := mocks.MockedPresignedClient{PresignUploadPartFuncMock: partURLMock}
+
+	serviceHandler := NewAudioService(preSigned, mocks.MockedS3{}, mockedDynamodb)
+
+	upload, err := serviceHandler.GetMultipartUpload(context.TODO(), "episode", dto.PresignOptions{})
+
+	if err != nil {
+		t.Errorf("The result is different from expected. Expected nil. Result: %v", err)
+	}
+
+	if len(upload.CompletedParts) != 1 || len(upload.Parts) != 2 || upload.Parts[0].Number != 2 {
+		t.Errorf("The result is different from expected. Result: %+v", upload)
+	}
+}
+
+func TestRecordPartOutOfRange(t *testing.T) {
+	mockedDynamodb := mocks.MockedDynamoDB{}
+	mockedDynamodb.GetItemFuncMock = multipartMock(MULTIPART_PENDING)
+
+	serviceHandler := NewAudioService(mocks.MockedPresignedClient{}, mocks.MockedS3{}, mockedDynamodb)
+
+	for _, number := range []int32{0, 4} {
+		err := serviceHandler.RecordPart(context.TODO(), "episode", number, `"etag"`)
+
+		if !errors.Is(err, PartOutOfRangeErr) {
+			t.Errorf("The result is different from expected. Result: %v. Expected: %v", err, PartOutOfRangeErr)
+		}
+	}
+}
+
+func TestRecordPartSetsItsOwnKey(t *testing.T) {
+	mockedDynamodb := mocks.MockedDynamoDB{}
+	mockedDynamodb.GetItemFuncMock = multipartMock(MULTIPART_PENDING)
+
+	mockedDynamodb.UpdateItemFuncMock = func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		if params.ExpressionAttributeNames["#number"] != "2" || *params.UpdateExpression != "SET #parts.#number = :etag" {
+			t.Errorf("The result is different from expected. Result: %v %v", *params.UpdateExpression, params.ExpressionAttributeNames)
+		}
+
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+
+	serviceHandler := NewAudioService(mocks.MockedPresignedClient{}, mocks.MockedS3{}, mockedDynamodb)
+
+	err := serviceHandler.RecordPart(context.TODO(), "episode", 2, `"etag2"`)
+
+	if !errors.Is(err, MultipartNotPendingErr) {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", err, MultipartNotPendingErr)
+	}
+}
+
+func TestCompleteMultipartUploadMissingParts(t *testing.T) {
+	mockedDynamodb := mocks.MockedDynamoDB{}
+	mockedDynamodb.GetItemFuncMock = multipartMock(MULTIPART_PENDING)
+
+	serviceHandler := NewAudioService(mocks.MockedPresignedClient{}, mocks.MockedS3{}, mockedDynamodb)
+
+	err := serviceHandler.CompleteMultipartUpload(context.TODO(), "episode")
+
+	if !errors.Is(err, MissingPartsErr) {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", err, MissingPartsErr)
+	}
+}
+
+func TestCompleteMultipartUploadJoinsParts(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedDynamodb.GetItemFuncMock = func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+		output, _ := multipartMock(MULTIPART_PENDING)(ctx, params, optFns...)
+		parts := output.Item[PARTS].(*types.AttributeValueMemberM).Value
+		parts["3"] = &types.AttributeValueMemberS{Value: `"etag3"`}
+		parts["2"] = &types.AttributeValueMemberS{Value: `"etag2"`}
+		return output, nil
+	}
+
+	var completed []int32
+
+	mockedS3.CompleteMultipartUploadFuncMock = func(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+		if *params.Key != "episodes/episode" || *params.UploadId != "upload" {
+			t.Errorf("The result is different from expected. Result: %v %v", *params.Key, *params.UploadId)
+		}
+
+		for _, part := range params.MultipartUpload.Parts {
+			completed = append(completed, *part.PartNumber)
+		}
+
+		return &s3.CompleteMultipartUploadOutput{}, nil
+	}
+
+	var status string
+
+	mockedDynamodb.UpdateItemFuncMock = func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		status = params.ExpressionAttributeValues[":status"].(*types.AttributeValueMemberS).Value
+		return &dynamodb.UpdateItemOutput{}, nil
+	}
+
+	serviceHandler := NewAudioService(mocks.MockedPresignedClient{}, mockedS3, mockedDynamodb)
+
+	err := serviceHandler.CompleteMultipartUpload(context.TODO(), "episode")
+
+	if err != nil {
+		t.Errorf("The result is different from expected. Expected nil. Result: %v", err)
+	}
+
+	// S3 refuses parts out of order.
+	if len(completed) != 3 || completed[0] != 1 || completed[1] != 2 || completed[2] != 3 {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", completed, []int32{1, 2, 3})
+	}
+
+	if status != MULTIPART_COMPLETED {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", status, MULTIPART_COMPLETED)
+	}
+}
+
+func TestAbortMultipartUploadCompleted(t *testing.T) {
+	mockedDynamodb := mocks.MockedDynamoDB{}
+	mockedDynamodb.GetItemFuncMock = multipartMock(MULTIPART_COMPLETED)
+
+	serviceHandler := NewAudioService(mocks.MockedPresignedClient{}, mocks.MockedS3{}, mockedDynamodb)
+
+	err := serviceHandler.AbortMultipartUpload(context.TODO(), "episode")
+
+	if !errors.Is(err, MultipartNotPendingErr) {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", err, MultipartNotPendingErr)
+	}
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3_READ_BLOCK_SIZE is the smallest range fetched from S3, so parsers
+// reading a few bytes at a time don't make one request each.
+const S3_READ_BLOCK_SIZE = 64 << 10
+
+// s3ReaderAt reads an object of a known size with ranged GETs, keeping the
+// last block fetched.
+type s3ReaderAt struct {
+	ctx    context.Context
+	s3     S3Bucket
+	bucket string
+	key    string
+	size   int64
+
+	block       []byte
+	blockOffset int64
+}
+
+func newS3ReaderAt(ctx context.Context, s S3Bucket, bucket string, key string, size int64) *s3ReaderAt {
+	return &s3ReaderAt{ctx: ctx, s3: s, bucket: bucket, key: key, size: size}
+}
+
+func (r *s3ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.size {
+		return 0, io.EOF
+	}
+
+	end := min(off+int64(len(p)), r.size)
+
+	if off < r.blockOffset || end > r.blockOffset+int64(len(r.block)) {
+		err := r.fetch(off, max(end, off+S3_READ_BLOCK_SIZE))
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.block[off-r.blockOffset:end-r.blockOffset])
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (r *s3ReaderAt) fetch(start int64, end int64) error {
+	end = min(end, r.size)
+
+	output, err := r.s3.GetObject(r.ctx, &s3.GetObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(r.key),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", start, end-1)),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	defer output.Body.Close()
+
+	block, err := io.ReadAll(output.Body)
+
+	if err != nil {
+		return err
+	}
+
+	if int64(len(block)) < end-start {
+		return io.ErrUnexpectedEOF
+	}
+
+	r.block = block
+	r.blockOffset = start
+
+	return nil
+}
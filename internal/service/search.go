@@ -0,0 +1,268 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/entity"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/normalize"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var SEARCH_TABLE = os.Getenv("SEARCH_TABLE")
+
+const (
+	TERM = "term"
+	TF   = "tf"
+
+	DEFAULT_SEARCH_LIMIT int32 = 20
+	MAX_SEARCH_LIMIT     int32 = 50
+)
+
+var EmptySearchErr = errors.New("The search query has no searchable words")
+
+type SearchService struct {
+	dynamo DynamoDB
+}
+
+type ISearchService interface {
+	Search(context.Context, string, int32) ([]dto.SearchResultDTO, error)
+	IndexItem(ctx context.Context, id string, oldWords string, newWords string) error
+}
+
+func NewSearchService(d DynamoDB) ISearchService {
+	return &SearchService{
+		dynamo: d,
+	}
+}
+
+type searchHit struct {
+	id      string
+	matched int
+	score   int
+}
+
+// Search ranks insertions by how many of the query terms they contain and
+// then by the summed term frequency, so a clip holding every word of a
+// multi-word query comes before one that repeats a single word.
+func (s *SearchService) Search(ctx context.Context, query string, limit int32) ([]dto.SearchResultDTO, error) {
+	terms := tokenize(query)
+
+	if len(terms) == 0 {
+		return []dto.SearchResultDTO{}, EmptySearchErr
+	}
+
+	hits := map[string]*searchHit{}
+
+	for term := range terms {
+		postings, err := s.postings(ctx, term)
+
+		if err != nil {
+			log.Printf("An error occurred when tried to query the search index. Error: %v", err)
+			return []dto.SearchResultDTO{}, err
+		}
+
+		for id, tf := range postings {
+			hit, ok := hits[id]
+
+			if !ok {
+				hit = &searchHit{id: id}
+				hits[id] = hit
+			}
+
+			hit.matched++
+			hit.score += tf
+		}
+	}
+
+	ranked := make([]*searchHit, 0, len(hits))
+
+	for _, hit := range hits {
+		ranked = append(ranked, hit)
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].matched != ranked[j].matched {
+			return ranked[i].matched > ranked[j].matched
+		}
+
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+
+		return ranked[i].id < ranked[j].id
+	})
+
+	if size := int(searchLimit(limit)); len(ranked) > size {
+		ranked = ranked[:size]
+	}
+
+	return s.loadResults(ctx, ranked)
+}
+
+func (s *SearchService) postings(ctx context.Context, term string) (map[string]int, error) {
+	postings := map[string]int{}
+
+	input := &dynamodb.QueryInput{
+		TableName:                aws.String(SEARCH_TABLE),
+		KeyConditionExpression:   aws.String("#term = :term"),
+		ExpressionAttributeNames: map[string]string{"#term": TERM},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":term": &types.AttributeValueMemberS{Value: term},
+		},
+	}
+
+	for {
+		output, err := s.dynamo.Query(ctx, input)
+
+		if err != nil {
+			return nil, err
+		}
+
+		var entries []entity.SearchEntry
+
+		err = attributevalue.UnmarshalListOfMaps(output.Items, &entries)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			postings[entry.ID] = entry.TF
+		}
+
+		if len(output.LastEvaluatedKey) == 0 {
+			return postings, nil
+		}
+
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
+func (s *SearchService) loadResults(ctx context.Context, ranked []*searchHit) ([]dto.SearchResultDTO, error) {
+	keys := make([]map[string]types.AttributeValue, 0, len(ranked))
+
+	for _, hit := range ranked {
+		keys = append(keys, map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: hit.id},
+		})
+	}
+
+	items, err := batchGet(ctx, s.dynamo, DYNAMO_TABLE, keys)
+
+	if err != nil {
+		return []dto.SearchResultDTO{}, err
+	}
+
+	var listOfItems []entity.Metadata
+
+	err = attributevalue.UnmarshalListOfMaps(items, &listOfItems)
+
+	if err != nil {
+		log.Printf("An error occurred when tried use attributevalue. Error: %v", err)
+		return []dto.SearchResultDTO{}, err
+	}
+
+	byID := map[string]entity.Metadata{}
+
+	for _, item := range listOfItems {
+		byID[item.ID] = item
+	}
+
+	results := []dto.SearchResultDTO{}
+
+	for _, hit := range ranked {
+		item, ok := byID[hit.id]
+
+		// The index is updated from the table stream, so it can briefly point
+		// to an insertion that was just deleted.
+		if !ok {
+			continue
+		}
+
+		results = append(results, dto.SearchResultDTO{Metadata: item.ConvertToDTO(), Score: hit.score})
+	}
+
+	return results, nil
+}
+
+// IndexItem brings the index entries of an insertion from oldWords to
+// newWords. An empty newWords removes the insertion from the index.
+func (s *SearchService) IndexItem(ctx context.Context, id string, oldWords string, newWords string) error {
+	oldTerms := tokenize(oldWords)
+	newTerms := tokenize(newWords)
+
+	var requests []types.WriteRequest
+
+	for term := range oldTerms {
+		if _, ok := newTerms[term]; ok {
+			continue
+		}
+
+		requests = append(requests, types.WriteRequest{
+			DeleteRequest: &types.DeleteRequest{
+				Key: map[string]types.AttributeValue{
+					TERM: &types.AttributeValueMemberS{Value: term},
+					ID:   &types.AttributeValueMemberS{Value: id},
+				},
+			},
+		})
+	}
+
+	for term, tf := range newTerms {
+		if oldTerms[term] == tf {
+			continue
+		}
+
+		requests = append(requests, types.WriteRequest{
+			PutRequest: &types.PutRequest{
+				Item: map[string]types.AttributeValue{
+					TERM: &types.AttributeValueMemberS{Value: term},
+					ID:   &types.AttributeValueMemberS{Value: id},
+					TF:   &types.AttributeValueMemberN{Value: strconv.Itoa(tf)},
+				},
+			},
+		})
+	}
+
+	err := batchWrite(ctx, s.dynamo, SEARCH_TABLE, requests)
+
+	if err != nil {
+		log.Printf("An error occurred when tried to index %s. Error: %v", id, err)
+		return err
+	}
+
+	return nil
+}
+
+// tokenize returns the normalized terms of text with their frequency, so
+// "delirio" matches "delírio" both when indexing and when searching.
+func tokenize(text string) map[string]int {
+	terms := map[string]int{}
+
+	for _, token := range normalize.Tokens(text) {
+		terms[token]++
+	}
+
+	return terms
+}
+
+func searchLimit(limit int32) int32 {
+	if limit <= 0 {
+		return DEFAULT_SEARCH_LIMIT
+	}
+
+	if limit > MAX_SEARCH_LIMIT {
+		return MAX_SEARCH_LIMIT
+	}
+
+	return limit
+}
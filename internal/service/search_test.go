@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/LucasAndFlores/go_lambdas_project/internal/mocks"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestSearchRanksByMatchedTermsAndFrequency(t *testing.T) {
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	postings := map[string]map[string]string{
+		"medo":    {"one": "1", "two": "3"},
+		"delirio": {"one": "1"},
+	}
+
+	mockedDynamodb.QueryFuncMock = func(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+		term := params.ExpressionAttributeValues[":term"].(*types.AttributeValueMemberS).Value
+
+		var items []map[string]types.AttributeValue
+
+		for id, tf := range postings[term] {
+			items = append(items, map[string]types.AttributeValue{
+				"term": &types.AttributeValueMemberS{Value: term},
+				"id":   &types.AttributeValueMemberS{Value: id},
+				"tf":   &types.AttributeValueMemberN{Value: tf},
+			})
+		}
+
+		return &dynamodb.QueryOutput{Items: items}, nil
+	}
+
+	mockedDynamodb.BatchGetItemFuncMock = func(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+		var items []map[string]types.AttributeValue
+
+		for _, key := range params.RequestItems[DYNAMO_TABLE].Keys {
+			items = append(items, map[string]types.AttributeValue{
+				"id": key["id"],
+			})
+		}
+
+		return &dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]types.AttributeValue{DYNAMO_TABLE: items}}, nil
+	}
+
+	serviceHandler := NewSearchService(mockedDynamodb)
+
+	results, err := serviceHandler.Search(context.TODO(), "Medo e delirio", 0)
+
+	if err != nil {
+		t.Errorf("Expected nil but received an error. Error: %v", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("The result is different from expected. Result: %v. Expected: %v", len(results), 2)
+	}
+
+	if results[0].Metadata.ID != "one" || results[1].Metadata.ID != "two" {
+		t.Errorf("The order is different from expected. Result: %v", results)
+	}
+}
+
+func TestSearchWithoutWords(t *testing.T) {
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	serviceHandler := NewSearchService(mockedDynamodb)
+
+	_, err := serviceHandler.Search(context.TODO(), " ?! ", 0)
+
+	if !errors.Is(err, EmptySearchErr) {
+		t.Errorf("The result is different from expected.Result: %v. Expected: %v", err, EmptySearchErr)
+	}
+}
+
+func TestIndexItemOnlyWritesChangedTerms(t *testing.T) {
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedDynamodb.BatchWriteItemFuncMock = func(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+		puts := map[string]bool{}
+		deletes := map[string]bool{}
+
+		for _, request := range params.RequestItems[SEARCH_TABLE] {
+			if request.PutRequest != nil {
+				puts[request.PutRequest.Item["term"].(*types.AttributeValueMemberS).Value] = true
+			}
+
+			if request.DeleteRequest != nil {
+				deletes[request.DeleteRequest.Key["term"].(*types.AttributeValueMemberS).Value] = true
+			}
+		}
+
+		if len(puts) != 1 || !puts["world"] {
+			t.Errorf("The puts are different from expected. Result: %v", puts)
+		}
+
+		if len(deletes) != 1 || !deletes["bye"] {
+			t.Errorf("The deletes are different from expected. Result: %v", deletes)
+		}
+
+		return &dynamodb.BatchWriteItemOutput{}, nil
+	}
+
+	serviceHandler := NewSearchService(mockedDynamodb)
+
+	err := serviceHandler.IndexItem(context.TODO(), "test", "hello bye", "Hello, world!")
+
+	if err != nil {
+		t.Errorf("Expected nil but received an error. Error: %v", err)
+	}
+}
+
+func TestIndexItemRetriesUnprocessedItems(t *testing.T) {
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	calls := 0
+
+	mockedDynamodb.BatchWriteItemFuncMock = func(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+		calls++
+
+		if calls == 1 {
+			return &dynamodb.BatchWriteItemOutput{UnprocessedItems: params.RequestItems}, nil
+		}
+
+		return &dynamodb.BatchWriteItemOutput{}, nil
+	}
+
+	serviceHandler := NewSearchService(mockedDynamodb)
+
+	err := serviceHandler.IndexItem(context.TODO(), "test", "", "hello")
+
+	if err != nil {
+		t.Errorf("Expected nil but received an error. Error: %v", err)
+	}
+
+	if calls != 2 {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", calls, 2)
+	}
+}
@@ -0,0 +1,313 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/entity"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/normalize"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var SUGGEST_TABLE = os.Getenv("SUGGEST_TABLE")
+
+const (
+	PREFIX  = "prefix"
+	VALUE   = "value"
+	KIND    = "kind"
+	DISPLAY = "display"
+	COUNT   = "count"
+
+	KIND_AUTHOR = "author"
+	KIND_LABEL  = "label"
+
+	MAX_PREFIX_LENGTH = 10
+
+	DEFAULT_SUGGEST_LIMIT int32 = 10
+	MAX_SUGGEST_LIMIT     int32 = 50
+
+	ORDER_POPULARITY   = "popularity"
+	ORDER_ALPHABETICAL = "alphabetical"
+
+	// EVENT_PREFIX is the partition of the markers of the stream events
+	// already applied, which expire after MARKER_EXPIRATION, well past the
+	// 24 hours a stream keeps its records.
+	EVENT_PREFIX      = "#event#"
+	MARKER_EXPIRATION = 48 * time.Hour
+)
+
+var EmptyPrefixErr = errors.New("The prefix can't be empty")
+
+type SuggestService struct {
+	dynamo DynamoDB
+}
+
+type ISuggestService interface {
+	Suggest(context.Context, dto.SuggestInput) ([]dto.SuggestionDTO, error)
+	IndexItem(ctx context.Context, eventID string, kind string, oldValue string, newValue string) error
+}
+
+func NewSuggestService(d DynamoDB) ISuggestService {
+	return &SuggestService{
+		dynamo: d,
+	}
+}
+
+// Suggest reads the partition of the first MAX_PREFIX_LENGTH characters of the
+// normalized prefix. Longer prefixes are narrowed down here, since the index
+// only stores prefixes up to that length.
+func (s *SuggestService) Suggest(ctx context.Context, input dto.SuggestInput) ([]dto.SuggestionDTO, error) {
+	prefix := normalize.Fold(input.Prefix)
+
+	if prefix == "" {
+		return []dto.SuggestionDTO{}, EmptyPrefixErr
+	}
+
+	partition := strings.TrimRight(truncate(prefix, MAX_PREFIX_LENGTH), " ")
+
+	queryInput := &dynamodb.QueryInput{
+		TableName:                aws.String(SUGGEST_TABLE),
+		KeyConditionExpression:   aws.String("#prefix = :prefix"),
+		ExpressionAttributeNames: map[string]string{"#prefix": PREFIX},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":prefix": &types.AttributeValueMemberS{Value: partition},
+		},
+	}
+
+	var suggestions []entity.Suggestion
+
+	for {
+		output, err := s.dynamo.Query(ctx, queryInput)
+
+		if err != nil {
+			log.Printf("An error occurred when tried to query the suggestions. Error: %v", err)
+			return []dto.SuggestionDTO{}, err
+		}
+
+		var page []entity.Suggestion
+
+		err = attributevalue.UnmarshalListOfMaps(output.Items, &page)
+
+		if err != nil {
+			log.Printf("An error occurred when tried use attributevalue. Error: %v", err)
+			return []dto.SuggestionDTO{}, err
+		}
+
+		suggestions = append(suggestions, page...)
+
+		if len(output.LastEvaluatedKey) == 0 {
+			break
+		}
+
+		queryInput.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+
+	results := []dto.SuggestionDTO{}
+
+	for _, suggestion := range suggestions {
+		if input.Kind != "" && suggestion.Kind != input.Kind {
+			continue
+		}
+
+		if !strings.HasPrefix(strings.TrimPrefix(suggestion.Value, suggestion.Kind+"#"), prefix) {
+			continue
+		}
+
+		results = append(results, suggestion.ConvertToDTO())
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if input.Order != ORDER_ALPHABETICAL && results[i].Count != results[j].Count {
+			return results[i].Count > results[j].Count
+		}
+
+		return normalize.Fold(results[i].Value) < normalize.Fold(results[j].Value)
+	})
+
+	if size := int(suggestLimit(input.Limit)); len(results) > size {
+		results = results[:size]
+	}
+
+	return results, nil
+}
+
+// IndexItem moves one insertion from oldValue to newValue in every prefix
+// partition. Either value may be empty, for created and deleted insertions.
+// The counters are moved at most once per eventID, the id of the stream
+// record, so a retried record isn't counted twice.
+func (s *SuggestService) IndexItem(ctx context.Context, eventID string, kind string, oldValue string, newValue string) error {
+	if normalize.Fold(oldValue) == normalize.Fold(newValue) {
+		if oldValue == newValue {
+			return nil
+		}
+
+		// Same entry, only the case or accents changed: refresh what is shown.
+		return s.adjust(ctx, eventID, kind, newValue, 0)
+	}
+
+	if newValue != "" {
+		err := s.adjust(ctx, eventID, kind, newValue, 1)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if oldValue != "" {
+		err := s.adjust(ctx, eventID, kind, oldValue, -1)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// adjust moves the counters of every prefix along with a marker of the
+// event in a single transaction, which is canceled when the marker exists.
+// Refreshing what is shown moves no counter and needs no marker.
+func (s *SuggestService) adjust(ctx context.Context, eventID string, kind string, value string, delta int) error {
+	folded := normalize.Fold(value)
+
+	if folded == "" {
+		return nil
+	}
+
+	sortKey := kind + "#" + folded
+
+	var items []types.TransactWriteItem
+
+	if delta != 0 {
+		items = append(items, types.TransactWriteItem{
+			Put: &types.Put{
+				TableName: aws.String(SUGGEST_TABLE),
+				Item: map[string]types.AttributeValue{
+					PREFIX:     &types.AttributeValueMemberS{Value: EVENT_PREFIX + eventID},
+					VALUE:      &types.AttributeValueMemberS{Value: kind + "#" + strconv.Itoa(delta)},
+					EXPIRES_AT: &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Add(MARKER_EXPIRATION).Unix(), 10)},
+				},
+				ConditionExpression:      aws.String("attribute_not_exists(#prefix)"),
+				ExpressionAttributeNames: map[string]string{"#prefix": PREFIX},
+			},
+		})
+	}
+
+	for _, prefix := range prefixes(folded) {
+		update := &types.Update{
+			TableName: aws.String(SUGGEST_TABLE),
+			Key: map[string]types.AttributeValue{
+				PREFIX: &types.AttributeValueMemberS{Value: prefix},
+				VALUE:  &types.AttributeValueMemberS{Value: sortKey},
+			},
+			UpdateExpression:         aws.String("ADD #count :delta"),
+			ExpressionAttributeNames: map[string]string{"#count": COUNT},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":delta": &types.AttributeValueMemberN{Value: strconv.Itoa(delta)},
+			},
+		}
+
+		if delta >= 0 {
+			update.UpdateExpression = aws.String("ADD #count :delta SET #kind = :kind, #display = :display")
+			update.ExpressionAttributeNames["#kind"] = KIND
+			update.ExpressionAttributeNames["#display"] = DISPLAY
+			update.ExpressionAttributeValues[":kind"] = &types.AttributeValueMemberS{Value: kind}
+			update.ExpressionAttributeValues[":display"] = &types.AttributeValueMemberS{Value: value}
+		}
+
+		items = append(items, types.TransactWriteItem{Update: update})
+	}
+
+	_, err := s.dynamo.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+
+	if err != nil {
+		var canceledErr *types.TransactionCanceledException
+
+		if delta == 0 || !errors.As(err, &canceledErr) || !conditionFailed(canceledErr, 0) {
+			log.Printf("An error occurred when tried to update the suggestion %s. Error: %v", sortKey, err)
+			return err
+		}
+
+		// A retried event: the counters were already moved, but the entries
+		// may not have been removed yet.
+	}
+
+	if delta >= 0 {
+		return nil
+	}
+
+	for _, prefix := range prefixes(folded) {
+		// Only remove the entry if nothing incremented it in the meantime.
+		_, err = s.dynamo.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+			TableName: aws.String(SUGGEST_TABLE),
+			Key: map[string]types.AttributeValue{
+				PREFIX: &types.AttributeValueMemberS{Value: prefix},
+				VALUE:  &types.AttributeValueMemberS{Value: sortKey},
+			},
+			ConditionExpression:      aws.String("#count <= :zero"),
+			ExpressionAttributeNames: map[string]string{"#count": COUNT},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":zero": &types.AttributeValueMemberN{Value: "0"},
+			},
+		})
+
+		var conditionErr *types.ConditionalCheckFailedException
+
+		if err != nil && !errors.As(err, &conditionErr) {
+			log.Printf("An error occurred when tried to delete the suggestion %s. Error: %v", sortKey, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// prefixes skips the ones ending in a space, as normalize.Fold trims the
+// prefix typed by the user and they would never be queried.
+func prefixes(folded string) []string {
+	var result []string
+
+	runes := []rune(folded)
+
+	for length := 1; length <= len(runes) && length <= MAX_PREFIX_LENGTH; length++ {
+		if runes[length-1] == ' ' {
+			continue
+		}
+
+		result = append(result, string(runes[:length]))
+	}
+
+	return result
+}
+
+func truncate(s string, length int) string {
+	runes := []rune(s)
+
+	if len(runes) <= length {
+		return s
+	}
+
+	return string(runes[:length])
+}
+
+func suggestLimit(limit int32) int32 {
+	if limit <= 0 {
+		return DEFAULT_SUGGEST_LIMIT
+	}
+
+	if limit > MAX_SUGGEST_LIMIT {
+		return MAX_SUGGEST_LIMIT
+	}
+
+	return limit
+}
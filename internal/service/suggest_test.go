@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/mocks"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func suggestionItem(value string, kind string, display string, count string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"prefix":  &types.AttributeValueMemberS{Value: "bra"},
+		"value":   &types.AttributeValueMemberS{Value: kind + "#" + value},
+		"kind":    &types.AttributeValueMemberS{Value: kind},
+		"display": &types.AttributeValueMemberS{Value: display},
+		"count":   &types.AttributeValueMemberN{Value: count},
+	}
+}
+
+func TestSuggestOrdersByPopularity(t *testing.T) {
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedDynamodb.QueryFuncMock = func(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+		prefix := params.ExpressionAttributeValues[":prefix"].(*types.AttributeValueMemberS).Value
+
+		if prefix != "bra" {
+			t.Errorf("The prefix is different from expected. Result: %v. Expected: %v", prefix, "bra")
+		}
+
+		return &dynamodb.QueryOutput{
+			Items: []map[string]types.AttributeValue{
+				suggestionItem("brasilia", "label", "Brasília", "1"),
+				suggestionItem("braulio", "author", "Bráulio", "7"),
+			},
+		}, nil
+	}
+
+	serviceHandler := NewSuggestService(mockedDynamodb)
+
+	suggestions, err := serviceHandler.Suggest(context.TODO(), dto.SuggestInput{Prefix: "BRÁ"})
+
+	if err != nil {
+		t.Errorf("Expected nil but received an error. Error: %v", err)
+	}
+
+	if len(suggestions) != 2 || suggestions[0].Value != "Bráulio" {
+		t.Errorf("The result is different from expected. Result: %v", suggestions)
+	}
+
+	suggestions, _ = serviceHandler.Suggest(context.TODO(), dto.SuggestInput{Prefix: "bra", Order: ORDER_ALPHABETICAL})
+
+	if len(suggestions) != 2 || suggestions[0].Value != "Brasília" {
+		t.Errorf("The result is different from expected. Result: %v", suggestions)
+	}
+
+	suggestions, _ = serviceHandler.Suggest(context.TODO(), dto.SuggestInput{Prefix: "bra", Kind: KIND_LABEL})
+
+	if len(suggestions) != 1 || suggestions[0].Kind != KIND_LABEL {
+		t.Errorf("The result is different from expected. Result: %v", suggestions)
+	}
+}
+
+func TestSuggestEmptyPrefix(t *testing.T) {
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	serviceHandler := NewSuggestService(mockedDynamodb)
+
+	_, err := serviceHandler.Suggest(context.TODO(), dto.SuggestInput{Prefix: "   "})
+
+	if !errors.Is(err, EmptyPrefixErr) {
+		t.Errorf("The result is different from expected.Result: %v. Expected: %v", err, EmptyPrefixErr)
+	}
+}
+
+func TestSuggestIndexItemMovesCounters(t *testing.T) {
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	deltas := map[string]string{}
+	markers := 0
+	deleted := 0
+
+	mockedDynamodb.TransactWriteItemsFuncMock = func(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+		for _, item := range params.TransactItems {
+			if item.Put != nil {
+				if item.Put.Item["prefix"].(*types.AttributeValueMemberS).Value != EVENT_PREFIX+"event" {
+					t.Errorf("The marker is different from expected. Result: %v", item.Put.Item)
+				}
+
+				markers++
+				continue
+			}
+
+			prefix := item.Update.Key["prefix"].(*types.AttributeValueMemberS).Value
+			deltas[prefix] = item.Update.ExpressionAttributeValues[":delta"].(*types.AttributeValueMemberN).Value
+		}
+
+		return &dynamodb.TransactWriteItemsOutput{}, nil
+	}
+
+	mockedDynamodb.DeleteItemFuncMock = func(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+		deleted++
+		return &dynamodb.DeleteItemOutput{}, nil
+	}
+
+	serviceHandler := NewSuggestService(mockedDynamodb)
+
+	err := serviceHandler.IndexItem(context.TODO(), "event", KIND_AUTHOR, "Zé", "Ana")
+
+	if err != nil {
+		t.Errorf("Expected nil but received an error. Error: %v", err)
+	}
+
+	expected := map[string]string{"a": "1", "an": "1", "ana": "1", "z": "-1", "ze": "-1"}
+
+	if len(deltas) != len(expected) {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", deltas, expected)
+	}
+
+	for prefix, delta := range expected {
+		if deltas[prefix] != delta {
+			t.Errorf("The delta of %v is different from expected. Result: %v. Expected: %v", prefix, deltas[prefix], delta)
+		}
+	}
+
+	if markers != 2 {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", markers, 2)
+	}
+
+	if deleted != 2 {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", deleted, 2)
+	}
+}
+
+func TestSuggestIndexItemSkipsRetriedEvent(t *testing.T) {
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedDynamodb.TransactWriteItemsFuncMock = func(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+		return nil, canceledTransaction("ConditionalCheckFailed", "None")
+	}
+
+	deleted := 0
+
+	mockedDynamodb.DeleteItemFuncMock = func(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+		deleted++
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+
+	serviceHandler := NewSuggestService(mockedDynamodb)
+
+	err := serviceHandler.IndexItem(context.TODO(), "event", KIND_AUTHOR, "Zé", "Ana")
+
+	if err != nil {
+		t.Errorf("Expected nil but received an error. Error: %v", err)
+	}
+
+	// The entries of the old value are still removed, in case the first try
+	// stopped before it.
+	if deleted != 2 {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", deleted, 2)
+	}
+}
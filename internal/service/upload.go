@@ -0,0 +1,889 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LucasAndFlores/go_lambdas_project/internal/audio"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/entity"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+var UPLOADS_TABLE = os.Getenv("UPLOADS_TABLE")
+
+const (
+	STATUS     = "status"
+	EXPIRES_AT = "expires_at"
+	REASON     = "reason"
+	FORMAT     = "format"
+	TAGS       = "tags"
+	REPLACING  = "replacing"
+
+	STATUS_INDEX = "status-index"
+
+	UPLOAD_PENDING   = "pending"
+	UPLOAD_UPLOADED  = "uploaded"
+	UPLOAD_PUBLISHED = "published"
+	UPLOAD_EXPIRED   = "expired"
+	UPLOAD_REJECTED  = "rejected"
+
+	UPLOAD_EXPIRATION = time.Hour
+
+	QUARANTINE_PREFIX = "quarantine/"
+
+	MAX_AUDIO_SIZE     = dto.MAX_AUDIO_SIZE
+	MAX_AUDIO_DURATION = 5 * time.Minute
+)
+
+// OPTIONAL_PROPERTIES are the audio properties left out when unknown, which
+// a replacement has to remove.
+var OPTIONAL_PROPERTIES = []string{"loudness_lufs", "true_peak_dbtp", "sha256", "pcm_sha256"}
+
+var UploadNotPendingErr = errors.New("The upload is not pending")
+var UploadNotFoundErr = errors.New("Upload not found")
+
+type UploadService struct {
+	s3        S3Bucket
+	dynamo    DynamoDB
+	waveforms IWaveformService
+}
+
+type IUploadService interface {
+	CreateUpload(ctx context.Context, id string, filename string) error
+	DeleteUpload(ctx context.Context, id string) error
+	DiscardUpload(ctx context.Context, id string) error
+	ProcessUpload(ctx context.Context, id string, size int64) error
+	MarkUploaded(ctx context.Context, id string, info audio.Info) error
+	ExpirePendingUploads(ctx context.Context, now time.Time) (int, error)
+	SuggestMetadata(ctx context.Context, id string) (dto.SuggestedMetadataDTO, error)
+}
+
+func NewUploadService(s S3Bucket, d DynamoDB) IUploadService {
+	return &UploadService{
+		s3:        s,
+		dynamo:    d,
+		waveforms: NewWaveformService(s),
+	}
+}
+
+// CreateUpload records an upload that was handed out but not received yet.
+// It stays pending until the object shows up on S3 or it expires.
+func (s *UploadService) CreateUpload(ctx context.Context, id string, filename string) error {
+	item, err := attributevalue.MarshalMap(entity.Upload{
+		ID:        id,
+		FileName:  filename,
+		Status:    UPLOAD_PENDING,
+		ExpiresAt: time.Now().Add(UPLOAD_EXPIRATION).Unix(),
+	})
+
+	if err != nil {
+		log.Printf("An error occurred when tried use attributevalue. Error: %v", err)
+		return err
+	}
+
+	_, err = s.dynamo.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                aws.String(UPLOADS_TABLE),
+		Item:                     item,
+		ConditionExpression:      aws.String("attribute_not_exists(#id)"),
+		ExpressionAttributeNames: map[string]string{"#id": ID},
+	})
+
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+
+		if errors.As(err, &conditionErr) {
+			return ConfilctErr
+		}
+
+		log.Printf("Error when trying to use putItem method: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteUpload removes an upload that is still pending, for when its URL
+// couldn't be handed out. Uploads that moved on are kept.
+func (s *UploadService) DeleteUpload(ctx context.Context, id string) error {
+	_, err := s.dynamo.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(UPLOADS_TABLE),
+		Key: map[string]types.AttributeValue{
+			ID: &types.AttributeValueMemberS{Value: id},
+		},
+		ConditionExpression:      aws.String("#status = :pending"),
+		ExpressionAttributeNames: map[string]string{"#status": STATUS},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pending": &types.AttributeValueMemberS{Value: UPLOAD_PENDING},
+		},
+	})
+
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+
+		if errors.As(err, &conditionErr) {
+			return nil
+		}
+
+		log.Printf("An error occurred when tried to delete the upload %s. Error: %v", id, err)
+		return err
+	}
+
+	return nil
+}
+
+// DiscardUpload removes an upload that will never be published, along with
+// its object and waveform. The upload goes first, so nothing processes the
+// object again while it is removed. Published uploads are kept.
+func (s *UploadService) DiscardUpload(ctx context.Context, id string) error {
+	_, err := s.dynamo.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(UPLOADS_TABLE),
+		Key: map[string]types.AttributeValue{
+			ID: &types.AttributeValueMemberS{Value: id},
+		},
+		ConditionExpression:      aws.String("attribute_not_exists(#status) OR #status <> :published"),
+		ExpressionAttributeNames: map[string]string{"#status": STATUS},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":published": &types.AttributeValueMemberS{Value: UPLOAD_PUBLISHED},
+		},
+	})
+
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+
+		if errors.As(err, &conditionErr) {
+			return nil
+		}
+
+		log.Printf("An error occurred when tried to delete the upload %s. Error: %v", id, err)
+		return err
+	}
+
+	err = s.deleteObject(ctx, id)
+
+	if err != nil {
+		return err
+	}
+
+	return s.waveforms.DeleteWaveform(ctx, id)
+}
+
+// ProcessUpload checks the object that reached S3 under id before accepting
+// it. Objects that aren't audio, are too big or too long are moved under
+// QUARANTINE_PREFIX and their upload is rejected with the reason, or, for a
+// replacement, the archived audio is put back. Accepted audio is hashed, to
+// find duplicates, and decoded for its waveform and loudness.
+func (s *UploadService) ProcessUpload(ctx context.Context, id string, size int64) error {
+	info, reason, err := s.validate(ctx, id, size)
+
+	if err != nil {
+		var noSuchKey *s3types.NoSuchKey
+
+		// A retried event whose object was already moved away.
+		if errors.As(err, &noSuchKey) {
+			log.Printf("The object %s/%s no longer exists", BUCKET_NAME, id)
+			return nil
+		}
+
+		log.Printf("An error occurred when tried to read the object %s/%s. Error: %v", BUCKET_NAME, id, err)
+		return err
+	}
+
+	if reason != "" {
+		return s.reject(ctx, id, reason)
+	}
+
+	// Nothing is analysed nor stored next to an object that no upload is
+	// waiting for, or a retried event would rewrite the waveform of audio
+	// already accepted. MarkUploaded removes the object of an expired one.
+	upload, err := s.getUpload(ctx, id, true)
+
+	if errors.Is(err, UploadNotFoundErr) {
+		return UploadNotPendingErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if upload.Status == UPLOAD_EXPIRED {
+		return s.MarkUploaded(ctx, id, info)
+	}
+
+	if upload.Status != UPLOAD_PENDING && upload.Replacing == "" {
+		return UploadNotPendingErr
+	}
+
+	var analysis audio.Analysis
+
+	// Only decodable audio is read whole. The others are only hashed, which
+	// S3 mostly did already.
+	if audio.Decodable(info.Format) {
+		data, err := s.fetch(ctx, id)
+
+		if err != nil {
+			return err
+		}
+
+		digest := sha256.Sum256(data)
+		analysis = s.analyze(id, data, info)
+
+		info.SHA256 = hex.EncodeToString(digest[:])
+	} else {
+		info.SHA256, err = s.checksum(ctx, id)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	info.PCMHash = analysis.PCMHash
+	info.Loudness = analysis.Loudness
+
+	// The waveform is stored first, so a retry after a failure finds the
+	// upload still pending and stores it again.
+	if analysis.Peaks != nil {
+		err = s.waveforms.StoreWaveform(ctx, id, dto.WaveformDTO{
+			DurationMs: info.Duration.Milliseconds(),
+			Peaks:      analysis.Peaks,
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.MarkUploaded(ctx, id, info)
+}
+
+// validate probes the object and returns why it is rejected, or an empty
+// reason along with what the probe found.
+func (s *UploadService) validate(ctx context.Context, id string, size int64) (audio.Info, string, error) {
+	if size > MAX_AUDIO_SIZE {
+		return audio.Info{}, fmt.Sprintf("The audio has %d bytes, more than the limit of %d", size, MAX_AUDIO_SIZE), nil
+	}
+
+	info, err := audio.Probe(newS3ReaderAt(ctx, s.s3, BUCKET_NAME, id, size), size)
+
+	if errors.Is(err, audio.UnknownFormatErr) || errors.Is(err, audio.MalformedErr) {
+		return audio.Info{}, err.Error(), nil
+	}
+
+	if err != nil {
+		return audio.Info{}, "", err
+	}
+
+	if info.Duration > MAX_AUDIO_DURATION {
+		return audio.Info{}, fmt.Sprintf("The audio lasts %s, more than the limit of %s", info.Duration.Round(time.Second), MAX_AUDIO_DURATION), nil
+	}
+
+	return info, "", nil
+}
+
+// fetch reads the whole object, which validate made sure is small enough
+// to hold in memory.
+func (s *UploadService) fetch(ctx context.Context, id string) ([]byte, error) {
+	output, err := s.s3.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(BUCKET_NAME),
+		Key:    aws.String(id),
+	})
+
+	if err != nil {
+		log.Printf("Error getting object %s/%s: %s", BUCKET_NAME, id, err.Error())
+		return nil, err
+	}
+
+	defer output.Body.Close()
+
+	data, err := io.ReadAll(output.Body)
+
+	if err != nil {
+		log.Printf("Error reading object %s/%s: %s", BUCKET_NAME, id, err.Error())
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// checksum returns the SHA-256 of the object in hex. Objects uploaded with a
+// signed checksum carry it, so they aren't read; the others are hashed as
+// they stream.
+func (s *UploadService) checksum(ctx context.Context, id string) (string, error) {
+	head, err := s.s3.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       aws.String(BUCKET_NAME),
+		Key:          aws.String(id),
+		ChecksumMode: s3types.ChecksumModeEnabled,
+	})
+
+	if err != nil {
+		log.Printf("Error getting head of object %s/%s: %s", BUCKET_NAME, id, err.Error())
+		return "", err
+	}
+
+	// Objects uploaded in parts carry a checksum of the checksums of their
+	// parts instead, which doesn't decode to a digest.
+	digest, err := base64.StdEncoding.DecodeString(aws.ToString(head.ChecksumSHA256))
+
+	if err == nil && len(digest) == sha256.Size {
+		return hex.EncodeToString(digest), nil
+	}
+
+	output, err := s.s3.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(BUCKET_NAME),
+		Key:    aws.String(id),
+	})
+
+	if err != nil {
+		log.Printf("Error getting object %s/%s: %s", BUCKET_NAME, id, err.Error())
+		return "", err
+	}
+
+	defer output.Body.Close()
+
+	hash := sha256.New()
+
+	_, err = io.Copy(hash, output.Body)
+
+	if err != nil {
+		log.Printf("Error reading object %s/%s: %s", BUCKET_NAME, id, err.Error())
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// analyze decodes the audio. Audio that can't be decoded is still accepted,
+// without a waveform, loudness nor PCM hash.
+func (s *UploadService) analyze(id string, data []byte, info audio.Info) audio.Analysis {
+	analysis, err := audio.Analyze(bytes.NewReader(data), info, WAVEFORM_RESOLUTION)
+
+	if err != nil {
+		log.Printf("Unable to decode the audio %s, so it has no waveform nor loudness. Error: %v", id, err)
+		return audio.Analysis{}
+	}
+
+	return analysis
+}
+
+// reject records the reason before moving the object, so a retry after a
+// failed move still finds the object and finishes the job. A rejected
+// replacement puts the archived audio back instead, and audio already
+// accepted is left alone.
+func (s *UploadService) reject(ctx context.Context, id string, reason string) error {
+	log.Printf("Rejecting the upload %s: %s", id, reason)
+
+	_, err := s.dynamo.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(UPLOADS_TABLE),
+		Key: map[string]types.AttributeValue{
+			ID: &types.AttributeValueMemberS{Value: id},
+		},
+		UpdateExpression:         aws.String("SET #status = :rejected, #reason = :reason"),
+		ConditionExpression:      aws.String("#status = :pending"),
+		ExpressionAttributeNames: map[string]string{"#status": STATUS, "#reason": REASON},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":rejected": &types.AttributeValueMemberS{Value: UPLOAD_REJECTED},
+			":reason":   &types.AttributeValueMemberS{Value: reason},
+			":pending":  &types.AttributeValueMemberS{Value: UPLOAD_PENDING},
+		},
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+
+	var conditionErr *types.ConditionalCheckFailedException
+
+	if err != nil && !errors.As(err, &conditionErr) {
+		log.Printf("An error occurred when tried to reject the upload %s. Error: %v", id, err)
+		return err
+	}
+
+	if err != nil {
+		var upload entity.Upload
+
+		err = attributevalue.UnmarshalMap(conditionErr.Item, &upload)
+
+		if err != nil {
+			log.Printf("An error occurred when tried use attributevalue. Error: %v", err)
+			return err
+		}
+
+		switch {
+		case upload.Replacing != "":
+			return s.restore(ctx, id, upload.Replacing)
+
+		case upload.Status == UPLOAD_UPLOADED || upload.Status == UPLOAD_PUBLISHED:
+			return UploadNotPendingErr
+		}
+	}
+
+	err = s.quarantine(ctx, id)
+
+	if err != nil {
+		return err
+	}
+
+	return s.deleteObject(ctx, id)
+}
+
+// restore copies the archived audio back over a rejected replacement, which
+// is kept under QUARANTINE_PREFIX. The replacement is only marked as done
+// once the archive is back, so a retry after a failure restores it again.
+// The restored audio is processed like any other object and, should it
+// arrive while the replacement is still under way, staged again as is.
+func (s *UploadService) restore(ctx context.Context, id string, archive string) error {
+	err := s.quarantine(ctx, id)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = s.s3.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(BUCKET_NAME),
+		CopySource: aws.String(BUCKET_NAME + "/" + archive),
+		Key:        aws.String(id),
+	})
+
+	if err != nil {
+		log.Printf("Error copying object %s/%s back to %s: %s", BUCKET_NAME, archive, id, err.Error())
+		return err
+	}
+
+	_, err = s.dynamo.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(UPLOADS_TABLE),
+		Key: map[string]types.AttributeValue{
+			ID: &types.AttributeValueMemberS{Value: id},
+		},
+		UpdateExpression:         aws.String("REMOVE #replacing"),
+		ConditionExpression:      aws.String("#replacing = :archive"),
+		ExpressionAttributeNames: map[string]string{"#replacing": REPLACING},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":archive": &types.AttributeValueMemberS{Value: archive},
+		},
+	})
+
+	var conditionErr *types.ConditionalCheckFailedException
+
+	if err != nil && !errors.As(err, &conditionErr) {
+		log.Printf("An error occurred when tried to end the replacement of %s. Error: %v", id, err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *UploadService) quarantine(ctx context.Context, id string) error {
+	_, err := s.s3.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(BUCKET_NAME),
+		CopySource: aws.String(BUCKET_NAME + "/" + id),
+		Key:        aws.String(QUARANTINE_PREFIX + id),
+	})
+
+	if err != nil {
+		log.Printf("Error copying object %s/%s to quarantine: %s", BUCKET_NAME, id, err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// IsQuarantined tells the objects moved by ProcessUpload apart, as copying
+// them under QUARANTINE_PREFIX creates objects too.
+func IsQuarantined(key string) bool {
+	return strings.HasPrefix(key, QUARANTINE_PREFIX)
+}
+
+// MarkUploaded moves a pending upload to uploaded and stages the properties
+// and tags found by the probe until the upload is published. An object that arrives
+// after its upload expired is removed, since nothing will ever publish it.
+// The properties of a replacement overwrite the ones of the audio it
+// replaced.
+func (s *UploadService) MarkUploaded(ctx context.Context, id string, info audio.Info) error {
+	audioProperties := entity.AudioProperties{
+		DurationMs: info.Duration.Milliseconds(),
+		Bitrate:    info.Bitrate,
+		SampleRate: info.SampleRate,
+		Channels:   info.Channels,
+		Size:       info.Size,
+		SHA256:     info.SHA256,
+		PCMSHA256:  info.PCMHash,
+	}
+
+	if info.Loudness != nil {
+		audioProperties.LoudnessLUFS = hundredths(info.Loudness.Integrated)
+		audioProperties.TruePeakDBTP = hundredths(info.Loudness.TruePeak)
+	}
+
+	properties, err := attributevalue.MarshalMap(audioProperties)
+
+	if err != nil {
+		log.Printf("An error occurred when tried use attributevalue. Error: %v", err)
+		return err
+	}
+
+	properties[FORMAT] = &types.AttributeValueMemberS{Value: string(info.Format)}
+
+	if info.Tags != (audio.Tags{}) {
+		tags, err := attributevalue.Marshal(entity.AudioTags(info.Tags))
+
+		if err != nil {
+			log.Printf("An error occurred when tried use attributevalue. Error: %v", err)
+			return err
+		}
+
+		properties[TAGS] = tags
+	}
+
+	assignments, _, attributeNames, attributeValues := propertyUpdate(properties, nil)
+
+	assignments = append([]string{"#status = :uploaded"}, assignments...)
+	attributeNames["#status"] = STATUS
+	attributeValues[":uploaded"] = &types.AttributeValueMemberS{Value: UPLOAD_UPLOADED}
+	attributeValues[":pending"] = &types.AttributeValueMemberS{Value: UPLOAD_PENDING}
+
+	_, err = s.dynamo.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(UPLOADS_TABLE),
+		Key: map[string]types.AttributeValue{
+			ID: &types.AttributeValueMemberS{Value: id},
+		},
+		UpdateExpression:                    aws.String("SET " + strings.Join(assignments, ", ")),
+		ConditionExpression:                 aws.String("#status = :pending"),
+		ExpressionAttributeNames:            attributeNames,
+		ExpressionAttributeValues:           attributeValues,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+
+	if err == nil {
+		return nil
+	}
+
+	var conditionErr *types.ConditionalCheckFailedException
+
+	if !errors.As(err, &conditionErr) {
+		log.Printf("An error occurred when tried to mark the upload %s as uploaded. Error: %v", id, err)
+		return err
+	}
+
+	var upload entity.Upload
+
+	err = attributevalue.UnmarshalMap(conditionErr.Item, &upload)
+
+	if err != nil {
+		log.Printf("An error occurred when tried use attributevalue. Error: %v", err)
+		return err
+	}
+
+	if upload.Replacing != "" {
+		return s.stageReplacement(ctx, upload, info, properties)
+	}
+
+	if upload.Status == UPLOAD_EXPIRED {
+		err = s.deleteObject(ctx, id)
+
+		if err != nil {
+			return err
+		}
+
+		err = s.waveforms.DeleteWaveform(ctx, id)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return UploadNotPendingErr
+}
+
+// stageReplacement overwrites the properties of the replaced audio, on the
+// upload and on its metadata once published, so duplicates are found by the
+// hashes of the audio actually stored. The replacement is only marked as
+// done at the end, so a retry after a failure finds it still under way.
+func (s *UploadService) stageReplacement(ctx context.Context, upload entity.Upload, info audio.Info, properties map[string]types.AttributeValue) error {
+	if upload.Status == UPLOAD_PUBLISHED {
+		metadataProperties := map[string]types.AttributeValue{}
+
+		for name, value := range properties {
+			if name != TAGS {
+				metadataProperties[name] = value
+			}
+		}
+
+		assignments, removals, attributeNames, attributeValues := propertyUpdate(metadataProperties, OPTIONAL_PROPERTIES)
+
+		attributeNames["#id"] = ID
+
+		_, err := s.dynamo.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName: aws.String(DYNAMO_TABLE),
+			Key: map[string]types.AttributeValue{
+				ID: &types.AttributeValueMemberS{Value: upload.ID},
+			},
+			UpdateExpression:          aws.String(updateExpression(assignments, removals)),
+			ConditionExpression:       aws.String("attribute_exists(#id)"),
+			ExpressionAttributeNames:  attributeNames,
+			ExpressionAttributeValues: attributeValues,
+		})
+
+		var conditionErr *types.ConditionalCheckFailedException
+
+		// The metadata was deleted while the audio was kept.
+		if err != nil && !errors.As(err, &conditionErr) {
+			log.Printf("An error occurred when tried to update the metadata %s. Error: %v", upload.ID, err)
+			return err
+		}
+	}
+
+	// The waveform of the replaced audio doesn't match an audio that
+	// couldn't be decoded.
+	if info.PCMHash == "" {
+		err := s.waveforms.DeleteWaveform(ctx, upload.ID)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	assignments, removals, attributeNames, attributeValues := propertyUpdate(properties, append([]string{TAGS}, OPTIONAL_PROPERTIES...))
+
+	removals = append(removals, "#replacing")
+	attributeNames["#replacing"] = REPLACING
+	attributeValues[":archive"] = &types.AttributeValueMemberS{Value: upload.Replacing}
+
+	_, err := s.dynamo.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(UPLOADS_TABLE),
+		Key: map[string]types.AttributeValue{
+			ID: &types.AttributeValueMemberS{Value: upload.ID},
+		},
+		UpdateExpression:          aws.String(updateExpression(assignments, removals)),
+		ConditionExpression:       aws.String("#replacing = :archive"),
+		ExpressionAttributeNames:  attributeNames,
+		ExpressionAttributeValues: attributeValues,
+	})
+
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+
+		// Another replacement started in between.
+		if errors.As(err, &conditionErr) {
+			return UploadNotPendingErr
+		}
+
+		log.Printf("An error occurred when tried to stage the replacement of %s. Error: %v", upload.ID, err)
+		return err
+	}
+
+	return nil
+}
+
+// propertyUpdate sets every property, sorted so the expression doesn't
+// depend on the order of the map, and removes the removable ones missing.
+func propertyUpdate(properties map[string]types.AttributeValue, removable []string) ([]string, []string, map[string]string, map[string]types.AttributeValue) {
+	var assignments, removals []string
+
+	attributeNames := map[string]string{}
+	attributeValues := map[string]types.AttributeValue{}
+
+	for name, value := range properties {
+		assignments = append(assignments, fmt.Sprintf("#%s = :%s", name, name))
+		attributeNames["#"+name] = name
+		attributeValues[":"+name] = value
+	}
+
+	for _, name := range removable {
+		if _, ok := properties[name]; !ok {
+			removals = append(removals, "#"+name)
+			attributeNames["#"+name] = name
+		}
+	}
+
+	sort.Strings(assignments)
+
+	return assignments, removals, attributeNames, attributeValues
+}
+
+func updateExpression(assignments []string, removals []string) string {
+	expression := "SET " + strings.Join(assignments, ", ")
+
+	if len(removals) > 0 {
+		expression += " REMOVE " + strings.Join(removals, ", ")
+	}
+
+	return expression
+}
+
+// SuggestMetadata maps the tags staged on the upload to metadata fields: the
+// artist suggests the author, the title the label and the comment the words.
+// The filename is the one given when the upload was created.
+func (s *UploadService) SuggestMetadata(ctx context.Context, id string) (dto.SuggestedMetadataDTO, error) {
+	upload, err := s.getUpload(ctx, id, false)
+
+	if err != nil {
+		return dto.SuggestedMetadataDTO{}, err
+	}
+
+	switch upload.Status {
+	case UPLOAD_PENDING:
+		return dto.SuggestedMetadataDTO{}, FileNotFoundErr
+	case UPLOAD_EXPIRED, UPLOAD_REJECTED:
+		return dto.SuggestedMetadataDTO{}, UploadNotFoundErr
+	}
+
+	return dto.SuggestedMetadataDTO{
+		ID:       upload.ID,
+		FileName: upload.FileName,
+		Author:   upload.Tags.Artist,
+		Label:    upload.Tags.Title,
+		Words:    upload.Tags.Comment,
+	}, nil
+}
+
+func (s *UploadService) getUpload(ctx context.Context, id string, consistent bool) (entity.Upload, error) {
+	output, err := s.dynamo.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(UPLOADS_TABLE),
+		Key: map[string]types.AttributeValue{
+			ID: &types.AttributeValueMemberS{Value: id},
+		},
+		ConsistentRead: aws.Bool(consistent),
+	})
+
+	if err != nil {
+		log.Printf("Error when tried to getItem from dynamoDB: %s", err)
+		return entity.Upload{}, err
+	}
+
+	if output.Item == nil {
+		return entity.Upload{}, UploadNotFoundErr
+	}
+
+	var upload entity.Upload
+
+	err = attributevalue.UnmarshalMap(output.Item, &upload)
+
+	if err != nil {
+		log.Printf("An error occurred when tried use attributevalue. Error: %v", err)
+		return entity.Upload{}, err
+	}
+
+	return upload, nil
+}
+
+// ExpirePendingUploads expires every upload still pending at now and removes
+// whatever part of its object reached S3. It returns how many were expired.
+func (s *UploadService) ExpirePendingUploads(ctx context.Context, now time.Time) (int, error) {
+	queryInput := &dynamodb.QueryInput{
+		TableName:              aws.String(UPLOADS_TABLE),
+		IndexName:              aws.String(STATUS_INDEX),
+		KeyConditionExpression: aws.String("#status = :pending AND #expires_at < :now"),
+		ExpressionAttributeNames: map[string]string{
+			"#status":     STATUS,
+			"#expires_at": EXPIRES_AT,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pending": &types.AttributeValueMemberS{Value: UPLOAD_PENDING},
+			":now":     &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+		},
+	}
+
+	expired := 0
+
+	for {
+		output, err := s.dynamo.Query(ctx, queryInput)
+
+		if err != nil {
+			log.Printf("An error occurred when tried to query the pending uploads. Error: %v", err)
+			return expired, err
+		}
+
+		var uploads []entity.Upload
+
+		err = attributevalue.UnmarshalListOfMaps(output.Items, &uploads)
+
+		if err != nil {
+			log.Printf("An error occurred when tried use attributevalue. Error: %v", err)
+			return expired, err
+		}
+
+		for _, upload := range uploads {
+			ok, err := s.expire(ctx, upload.ID)
+
+			if err != nil {
+				return expired, err
+			}
+
+			if ok {
+				expired++
+			}
+		}
+
+		if len(output.LastEvaluatedKey) == 0 {
+			return expired, nil
+		}
+
+		queryInput.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
+// expire flips the status before touching S3, so an object landing in
+// between is removed by MarkUploaded instead of being left behind.
+func (s *UploadService) expire(ctx context.Context, id string) (bool, error) {
+	_, err := s.dynamo.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(UPLOADS_TABLE),
+		Key: map[string]types.AttributeValue{
+			ID: &types.AttributeValueMemberS{Value: id},
+		},
+		UpdateExpression:         aws.String("SET #status = :expired"),
+		ConditionExpression:      aws.String("#status = :pending"),
+		ExpressionAttributeNames: map[string]string{"#status": STATUS},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":expired": &types.AttributeValueMemberS{Value: UPLOAD_EXPIRED},
+			":pending": &types.AttributeValueMemberS{Value: UPLOAD_PENDING},
+		},
+	})
+
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+
+		// The object arrived after the query, so the upload is no longer abandoned.
+		if errors.As(err, &conditionErr) {
+			return false, nil
+		}
+
+		log.Printf("An error occurred when tried to expire the upload %s. Error: %v", id, err)
+		return false, err
+	}
+
+	return true, s.deleteObject(ctx, id)
+}
+
+// hundredths rounds decibels far below what anyone can hear.
+func hundredths(value float64) *float64 {
+	return aws.Float64(math.Round(value*100) / 100)
+}
+
+func (s *UploadService) deleteObject(ctx context.Context, id string) error {
+	_, err := s.s3.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(BUCKET_NAME),
+		Key:    aws.String(id),
+	})
+
+	if err != nil {
+		log.Printf("Error deleting object %s/%s: %s", BUCKET_NAME, id, err.Error())
+		return err
+	}
+
+	return nil
+}
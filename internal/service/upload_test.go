@@ -0,0 +1,683 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/LucasAndFlores/go_lambdas_project/internal/audio"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/loudness"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/mocks"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestCreateUploadStoresPendingRecord(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedDynamodb.PutItemFuncMock = func(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+		status := params.Item[STATUS].(*types.AttributeValueMemberS).Value
+
+		if status != UPLOAD_PENDING {
+			t.Errorf("The result is different from expected. Result: %v. Expected: %v", status, UPLOAD_PENDING)
+		}
+
+		expiresAt, _ := strconv.ParseInt(params.Item[EXPIRES_AT].(*types.AttributeValueMemberN).Value, 10, 64)
+
+		if expiresAt <= time.Now().Unix() {
+			t.Errorf("The upload should expire in the future. Result: %v", expiresAt)
+		}
+
+		return &dynamodb.PutItemOutput{}, nil
+	}
+
+	serviceHandler := NewUploadService(mockedS3, mockedDynamodb)
+
+	err := serviceHandler.CreateUpload(context.TODO(), "test", "audio.mp3")
+
+	if err != nil {
+		t.Errorf("The result is different from expected. Expected nil. Result: %v", err)
+	}
+}
+
+func TestDeleteUploadOnlyRemovesPendingUploads(t *testing.T) {
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	var condition string
+
+	mockedDynamodb.DeleteItemFuncMock = func(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+		condition = *params.ConditionExpression
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+
+	serviceHandler := NewUploadService(mocks.MockedS3{}, mockedDynamodb)
+
+	err := serviceHandler.DeleteUpload(context.TODO(), "test")
+
+	if err != nil {
+		t.Errorf("The result is different from expected. Expected nil. Result: %v", err)
+	}
+
+	if condition != "#status = :pending" {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", condition, "#status = :pending")
+	}
+}
+
+func TestMarkUploadedAfterExpirationRemovesObject(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	var deleted []string
+
+	mockedDynamodb.UpdateItemFuncMock = func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		return nil, &types.ConditionalCheckFailedException{
+			Item: map[string]types.AttributeValue{
+				ID:     &types.AttributeValueMemberS{Value: "test"},
+				STATUS: &types.AttributeValueMemberS{Value: UPLOAD_EXPIRED},
+			},
+		}
+	}
+
+	mockedS3.DeleteObjectFuncMock = func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+		deleted = append(deleted, *params.Key)
+		return &s3.DeleteObjectOutput{}, nil
+	}
+
+	serviceHandler := NewUploadService(mockedS3, mockedDynamodb)
+
+	err := serviceHandler.MarkUploaded(context.TODO(), "test", audio.Info{})
+
+	if !errors.Is(err, UploadNotPendingErr) {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", err, UploadNotPendingErr)
+	}
+
+	if fmt.Sprint(deleted) != "[test waveforms/test.json]" {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", deleted, "[test waveforms/test.json]")
+	}
+}
+
+func TestMarkUploadedTwiceKeepsObject(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedDynamodb.UpdateItemFuncMock = func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		return nil, &types.ConditionalCheckFailedException{
+			Item: map[string]types.AttributeValue{
+				ID:     &types.AttributeValueMemberS{Value: "test"},
+				STATUS: &types.AttributeValueMemberS{Value: UPLOAD_PUBLISHED},
+			},
+		}
+	}
+
+	mockedS3.DeleteObjectFuncMock = func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+		t.Errorf("A published object shouldn't be removed")
+		return &s3.DeleteObjectOutput{}, nil
+	}
+
+	serviceHandler := NewUploadService(mockedS3, mockedDynamodb)
+
+	err := serviceHandler.MarkUploaded(context.TODO(), "test", audio.Info{})
+
+	if !errors.Is(err, UploadNotPendingErr) {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", err, UploadNotPendingErr)
+	}
+}
+
+func TestExpirePendingUploadsSkipsUploadsThatArrived(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedDynamodb.QueryFuncMock = func(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+		if *params.IndexName != STATUS_INDEX {
+			t.Errorf("The result is different from expected. Result: %v. Expected: %v", *params.IndexName, STATUS_INDEX)
+		}
+
+		return &dynamodb.QueryOutput{
+			Items: []map[string]types.AttributeValue{
+				{ID: &types.AttributeValueMemberS{Value: "abandoned"}},
+				{ID: &types.AttributeValueMemberS{Value: "arrived"}},
+			},
+		}, nil
+	}
+
+	mockedDynamodb.UpdateItemFuncMock = func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		if params.Key[ID].(*types.AttributeValueMemberS).Value == "arrived" {
+			return nil, &types.ConditionalCheckFailedException{}
+		}
+
+		return &dynamodb.UpdateItemOutput{}, nil
+	}
+
+	var deleted []string
+
+	mockedS3.DeleteObjectFuncMock = func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+		deleted = append(deleted, *params.Key)
+		return &s3.DeleteObjectOutput{}, nil
+	}
+
+	serviceHandler := NewUploadService(mockedS3, mockedDynamodb)
+
+	expired, err := serviceHandler.ExpirePendingUploads(context.TODO(), time.Now())
+
+	if err != nil {
+		t.Errorf("The result is different from expected. Expected nil. Result: %v", err)
+	}
+
+	if expired != 1 || len(deleted) != 1 || deleted[0] != "abandoned" {
+		t.Errorf("The result is different from expected. Result: %v %v. Expected: 1 [abandoned]", expired, deleted)
+	}
+}
+
+func objectMock(file []byte) func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	return func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+		if params.Range == nil {
+			return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(file))}, nil
+		}
+
+		var start, end int
+
+		fmt.Sscanf(*params.Range, "bytes=%d-%d", &start, &end)
+
+		return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(file[start : end+1]))}, nil
+	}
+}
+
+func pendingUploadMock(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{
+		Item: map[string]types.AttributeValue{
+			ID:     params.Key[ID],
+			STATUS: &types.AttributeValueMemberS{Value: UPLOAD_PENDING},
+		},
+	}, nil
+}
+
+func TestProcessUploadAcceptsAudio(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	file := []byte("RIFF\x00\x00\x00\x00WAVEfmt \x10\x00\x00\x00\x01\x00\x01\x00\x40\x1f\x00\x00\x80\x3e\x00\x00\x02\x00\x10\x00data\xa0\x0f\x00\x00")
+	file = append(file, make([]byte, 4000)...)
+
+	mockedS3.GetObjectFuncMock = objectMock(file)
+	mockedDynamodb.GetItemFuncMock = pendingUploadMock
+
+	var waveform dto.WaveformDTO
+
+	mockedS3.PutObjectFuncMock = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		if *params.Key != "waveforms/test.json" {
+			t.Errorf("The result is different from expected. Result: %v. Expected: %v", *params.Key, "waveforms/test.json")
+		}
+
+		json.NewDecoder(params.Body).Decode(&waveform)
+
+		return &s3.PutObjectOutput{}, nil
+	}
+
+	mockedDynamodb.UpdateItemFuncMock = func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		status := params.ExpressionAttributeValues[":uploaded"]
+
+		if status == nil {
+			t.Errorf("The upload should be marked as uploaded. Result: %v", *params.UpdateExpression)
+		}
+
+		duration := params.ExpressionAttributeValues[":duration_ms"].(*types.AttributeValueMemberN).Value
+
+		if duration != "250" {
+			t.Errorf("The result is different from expected. Result: %v. Expected: %v", duration, "250")
+		}
+
+		// The SHA-256 of the file, and the one of the header of the samples
+		// followed by 2000 silent samples.
+		digest := params.ExpressionAttributeValues[":sha256"].(*types.AttributeValueMemberS).Value
+		pcmDigest := params.ExpressionAttributeValues[":pcm_sha256"].(*types.AttributeValueMemberS).Value
+
+		if digest != fmt.Sprintf("%x", sha256.Sum256(file)) || pcmDigest != fmt.Sprintf("%x", sha256.Sum256(append([]byte{0x40, 0x1f, 0, 0, 1, 0}, make([]byte, 4000)...))) {
+			t.Errorf("The result is different from expected. Result: %v %v", digest, pcmDigest)
+		}
+
+		return &dynamodb.UpdateItemOutput{}, nil
+	}
+
+	serviceHandler := NewUploadService(mockedS3, mockedDynamodb)
+
+	err := serviceHandler.ProcessUpload(context.TODO(), "test", int64(len(file)))
+
+	if err != nil {
+		t.Errorf("The result is different from expected. Expected nil. Result: %v", err)
+	}
+
+	if len(waveform.Peaks) != WAVEFORM_RESOLUTION || waveform.DurationMs != 250 {
+		t.Errorf("The result is different from expected. Result: %v %v. Expected: %v %v", len(waveform.Peaks), waveform.DurationMs, WAVEFORM_RESOLUTION, 250)
+	}
+}
+
+func TestProcessUploadWithoutPendingUploadStoresNothing(t *testing.T) {
+	file := []byte("RIFF\x00\x00\x00\x00WAVEfmt \x10\x00\x00\x00\x01\x00\x01\x00\x40\x1f\x00\x00\x80\x3e\x00\x00\x02\x00\x10\x00data\xa0\x0f\x00\x00")
+	file = append(file, make([]byte, 4000)...)
+
+	uploads := map[string]map[string]types.AttributeValue{
+		"no row": nil,
+		"published": {
+			ID:     &types.AttributeValueMemberS{Value: "test"},
+			STATUS: &types.AttributeValueMemberS{Value: UPLOAD_PUBLISHED},
+		},
+	}
+
+	for name, item := range uploads {
+		mockedS3 := mocks.MockedS3{}
+		mockedDynamodb := mocks.MockedDynamoDB{}
+
+		mockedS3.GetObjectFuncMock = objectMock(file)
+
+		mockedS3.PutObjectFuncMock = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+			t.Errorf("%s: Nothing should be stored. Result: %v", name, *params.Key)
+			return &s3.PutObjectOutput{}, nil
+		}
+
+		mockedDynamodb.GetItemFuncMock = func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{Item: item}, nil
+		}
+
+		serviceHandler := NewUploadService(mockedS3, mockedDynamodb)
+
+		err := serviceHandler.ProcessUpload(context.TODO(), "test", int64(len(file)))
+
+		if !errors.Is(err, UploadNotPendingErr) {
+			t.Errorf("%s: The result is different from expected. Result: %v. Expected: %v", name, err, UploadNotPendingErr)
+		}
+	}
+}
+
+func TestProcessUploadQuarantinesOtherFiles(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	file := []byte("<html><body>not audio</body></html>")
+
+	mockedS3.GetObjectFuncMock = objectMock(file)
+
+	reason := ""
+
+	mockedDynamodb.UpdateItemFuncMock = func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		reason = params.ExpressionAttributeValues[":reason"].(*types.AttributeValueMemberS).Value
+		return &dynamodb.UpdateItemOutput{}, nil
+	}
+
+	copied := ""
+	deleted := ""
+
+	mockedS3.CopyObjectFuncMock = func(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+		copied = *params.Key
+		return &s3.CopyObjectOutput{}, nil
+	}
+
+	mockedS3.DeleteObjectFuncMock = func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+		deleted = *params.Key
+		return &s3.DeleteObjectOutput{}, nil
+	}
+
+	serviceHandler := NewUploadService(mockedS3, mockedDynamodb)
+
+	err := serviceHandler.ProcessUpload(context.TODO(), "test", int64(len(file)))
+
+	if err != nil {
+		t.Errorf("The result is different from expected. Expected nil. Result: %v", err)
+	}
+
+	if reason != audio.UnknownFormatErr.Error() {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", reason, audio.UnknownFormatErr)
+	}
+
+	if copied != "quarantine/test" || deleted != "test" {
+		t.Errorf("The result is different from expected. Result: %v %v. Expected: quarantine/test test", copied, deleted)
+	}
+}
+
+func TestProcessUploadRejectsLargeFilesWithoutReadingThem(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedS3.GetObjectFuncMock = func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+		t.Errorf("A file over the limit shouldn't be read")
+		return nil, errors.New("AWS Error")
+	}
+
+	mockedDynamodb.UpdateItemFuncMock = func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+
+	mockedS3.CopyObjectFuncMock = func(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+		return &s3.CopyObjectOutput{}, nil
+	}
+
+	mockedS3.DeleteObjectFuncMock = func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+		return &s3.DeleteObjectOutput{}, nil
+	}
+
+	serviceHandler := NewUploadService(mockedS3, mockedDynamodb)
+
+	err := serviceHandler.ProcessUpload(context.TODO(), "test", MAX_AUDIO_SIZE+1)
+
+	if err != nil {
+		t.Errorf("The result is different from expected. Expected nil. Result: %v", err)
+	}
+}
+
+func TestProcessUploadRestoresRejectedReplacement(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	var updates []string
+	var copies []string
+
+	mockedDynamodb.UpdateItemFuncMock = func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		updates = append(updates, *params.UpdateExpression)
+
+		if len(updates) == 1 {
+			return nil, &types.ConditionalCheckFailedException{
+				Item: map[string]types.AttributeValue{
+					ID:        &types.AttributeValueMemberS{Value: "test"},
+					STATUS:    &types.AttributeValueMemberS{Value: UPLOAD_PUBLISHED},
+					REPLACING: &types.AttributeValueMemberS{Value: "archive/test/20240301T120000Z"},
+				},
+			}
+		}
+
+		return &dynamodb.UpdateItemOutput{}, nil
+	}
+
+	mockedS3.CopyObjectFuncMock = func(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+		copies = append(copies, *params.CopySource+" "+*params.Key)
+		return &s3.CopyObjectOutput{}, nil
+	}
+
+	mockedS3.DeleteObjectFuncMock = func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+		t.Errorf("The replaced audio should be restored rather than removed")
+		return &s3.DeleteObjectOutput{}, nil
+	}
+
+	serviceHandler := NewUploadService(mockedS3, mockedDynamodb)
+
+	err := serviceHandler.ProcessUpload(context.TODO(), "test", MAX_AUDIO_SIZE+1)
+
+	if err != nil {
+		t.Errorf("The result is different from expected. Expected nil. Result: %v", err)
+	}
+
+	expected := "[/test quarantine/test /archive/test/20240301T120000Z test]"
+
+	if fmt.Sprint(copies) != expected {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", copies, expected)
+	}
+
+	if len(updates) != 2 || updates[1] != "REMOVE #replacing" {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", updates, "REMOVE #replacing")
+	}
+}
+
+func TestProcessUploadKeepsAcceptedAudio(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedDynamodb.UpdateItemFuncMock = func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		return nil, &types.ConditionalCheckFailedException{
+			Item: map[string]types.AttributeValue{
+				ID:     &types.AttributeValueMemberS{Value: "test"},
+				STATUS: &types.AttributeValueMemberS{Value: UPLOAD_PUBLISHED},
+			},
+		}
+	}
+
+	mockedS3.CopyObjectFuncMock = func(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+		t.Errorf("A published audio shouldn't be quarantined")
+		return &s3.CopyObjectOutput{}, nil
+	}
+
+	serviceHandler := NewUploadService(mockedS3, mockedDynamodb)
+
+	err := serviceHandler.ProcessUpload(context.TODO(), "test", MAX_AUDIO_SIZE+1)
+
+	if !errors.Is(err, UploadNotPendingErr) {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", err, UploadNotPendingErr)
+	}
+}
+
+func TestMarkUploadedStagesReplacement(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	updates := map[string]*dynamodb.UpdateItemInput{}
+	var deleted []string
+
+	mockedDynamodb.UpdateItemFuncMock = func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		if *params.ConditionExpression == "#status = :pending" {
+			return nil, &types.ConditionalCheckFailedException{
+				Item: map[string]types.AttributeValue{
+					ID:        &types.AttributeValueMemberS{Value: "test"},
+					STATUS:    &types.AttributeValueMemberS{Value: UPLOAD_PUBLISHED},
+					REPLACING: &types.AttributeValueMemberS{Value: "archive/test/20240301T120000Z"},
+				},
+			}
+		}
+
+		updates[*params.TableName] = params
+		return &dynamodb.UpdateItemOutput{}, nil
+	}
+
+	mockedS3.DeleteObjectFuncMock = func(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+		deleted = append(deleted, *params.Key)
+		return &s3.DeleteObjectOutput{}, nil
+	}
+
+	defer func(metadata, uploads string) { DYNAMO_TABLE, UPLOADS_TABLE = metadata, uploads }(DYNAMO_TABLE, UPLOADS_TABLE)
+
+	DYNAMO_TABLE = "metadata"
+	UPLOADS_TABLE = "uploads"
+
+	serviceHandler := NewUploadService(mockedS3, mockedDynamodb)
+
+	err := serviceHandler.MarkUploaded(context.TODO(), "test", audio.Info{Format: audio.FormatOGG, SHA256: "digest"})
+
+	if err != nil {
+		t.Errorf("The result is different from expected. Expected nil. Result: %v", err)
+	}
+
+	metadata := updates["metadata"]
+
+	if metadata == nil || metadata.ExpressionAttributeValues[":sha256"].(*types.AttributeValueMemberS).Value != "digest" {
+		t.Fatalf("The metadata should get the hash of the replacement. Result: %+v", metadata)
+	}
+
+	expected := "SET #bitrate = :bitrate, #channels = :channels, #duration_ms = :duration_ms, #format = :format, #sample_rate = :sample_rate, #sha256 = :sha256, #size = :size REMOVE #loudness_lufs, #true_peak_dbtp, #pcm_sha256"
+
+	if *metadata.UpdateExpression != expected {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", *metadata.UpdateExpression, expected)
+	}
+
+	upload := updates["uploads"]
+
+	expected = "SET #bitrate = :bitrate, #channels = :channels, #duration_ms = :duration_ms, #format = :format, #sample_rate = :sample_rate, #sha256 = :sha256, #size = :size REMOVE #tags, #loudness_lufs, #true_peak_dbtp, #pcm_sha256, #replacing"
+
+	if upload == nil || *upload.UpdateExpression != expected {
+		t.Errorf("The result is different from expected. Result: %+v. Expected: %v", upload, expected)
+	}
+
+	if fmt.Sprint(deleted) != "[waveforms/test.json]" {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", deleted, "[waveforms/test.json]")
+	}
+}
+
+func TestMarkUploadedStagesTags(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedDynamodb.UpdateItemFuncMock = func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		tags, ok := params.ExpressionAttributeValues[":tags"].(*types.AttributeValueMemberM)
+
+		if !ok {
+			t.Fatalf("The tags should be staged on the upload. Result: %v", *params.UpdateExpression)
+		}
+
+		title := tags.Value["title"].(*types.AttributeValueMemberS).Value
+
+		if title != "Morning news" {
+			t.Errorf("The result is different from expected. Result: %v. Expected: %v", title, "Morning news")
+		}
+
+		if _, ok := tags.Value["comment"]; ok {
+			t.Errorf("Empty tags should not be staged. Result: %v", tags.Value)
+		}
+
+		return &dynamodb.UpdateItemOutput{}, nil
+	}
+
+	serviceHandler := NewUploadService(mockedS3, mockedDynamodb)
+
+	err := serviceHandler.MarkUploaded(context.TODO(), "test", audio.Info{Tags: audio.Tags{Title: "Morning news", Artist: "Ana"}})
+
+	if err != nil {
+		t.Errorf("The result is different from expected. Expected nil. Result: %v", err)
+	}
+}
+
+func TestSuggestMetadata(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+
+	cases := map[string]struct {
+		item     map[string]types.AttributeValue
+		expected dto.SuggestedMetadataDTO
+		err      error
+	}{
+		"uploaded": {
+			item: map[string]types.AttributeValue{
+				"id":       &types.AttributeValueMemberS{Value: "test"},
+				"filename": &types.AttributeValueMemberS{Value: "news.mp3"},
+				"status":   &types.AttributeValueMemberS{Value: UPLOAD_UPLOADED},
+				"tags": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+					"title":  &types.AttributeValueMemberS{Value: "Morning news"},
+					"artist": &types.AttributeValueMemberS{Value: "Ana"},
+				}},
+			},
+			expected: dto.SuggestedMetadataDTO{ID: "test", FileName: "news.mp3", Author: "Ana", Label: "Morning news"},
+		},
+		"pending": {
+			item: map[string]types.AttributeValue{
+				"id":     &types.AttributeValueMemberS{Value: "test"},
+				"status": &types.AttributeValueMemberS{Value: UPLOAD_PENDING},
+			},
+			err: FileNotFoundErr,
+		},
+		"rejected": {
+			item: map[string]types.AttributeValue{
+				"id":     &types.AttributeValueMemberS{Value: "test"},
+				"status": &types.AttributeValueMemberS{Value: UPLOAD_REJECTED},
+			},
+			err: UploadNotFoundErr,
+		},
+		"missing": {err: UploadNotFoundErr},
+	}
+
+	for name, c := range cases {
+		mockedDynamodb := mocks.MockedDynamoDB{}
+
+		mockedDynamodb.GetItemFuncMock = func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{Item: c.item}, nil
+		}
+
+		serviceHandler := NewUploadService(mockedS3, mockedDynamodb)
+
+		result, err := serviceHandler.SuggestMetadata(context.TODO(), "test")
+
+		if !errors.Is(err, c.err) || result != c.expected {
+			t.Errorf("%s: The result is different from expected. Result: %v %v. Expected: %v %v", name, result, err, c.expected, c.err)
+		}
+	}
+}
+
+func TestMarkUploadedStagesLoudness(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+	mockedDynamodb := mocks.MockedDynamoDB{}
+
+	mockedDynamodb.UpdateItemFuncMock = func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		values := params.ExpressionAttributeValues
+
+		lufs, ok := values[":loudness_lufs"].(*types.AttributeValueMemberN)
+
+		if !ok || lufs.Value != "-23.13" {
+			t.Errorf("The result is different from expected. Result: %v. Expected: %v", values[":loudness_lufs"], "-23.13")
+		}
+
+		peak, ok := values[":true_peak_dbtp"].(*types.AttributeValueMemberN)
+
+		if !ok || peak.Value != "-1.5" {
+			t.Errorf("The result is different from expected. Result: %v. Expected: %v", values[":true_peak_dbtp"], "-1.5")
+		}
+
+		return &dynamodb.UpdateItemOutput{}, nil
+	}
+
+	serviceHandler := NewUploadService(mockedS3, mockedDynamodb)
+
+	err := serviceHandler.MarkUploaded(context.TODO(), "test", audio.Info{Loudness: &loudness.Result{Integrated: -23.1256, TruePeak: -1.4999}})
+
+	if err != nil {
+		t.Errorf("The result is different from expected. Expected nil. Result: %v", err)
+	}
+}
+
+func TestChecksumUsesTheOneStoredByS3(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+
+	file := []byte("OggS audio")
+	digest := sha256.Sum256(file)
+
+	mockedS3.HeadObjectFuncMock = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		return &s3.HeadObjectOutput{ChecksumSHA256: aws.String(base64.StdEncoding.EncodeToString(digest[:]))}, nil
+	}
+
+	mockedS3.GetObjectFuncMock = func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+		t.Errorf("An object with a checksum shouldn't be read")
+		return nil, errors.New("AWS Error")
+	}
+
+	serviceHandler := &UploadService{s3: mockedS3}
+
+	checksum, err := serviceHandler.checksum(context.TODO(), "test")
+
+	if err != nil || checksum != fmt.Sprintf("%x", digest) {
+		t.Errorf("The result is different from expected. Result: %v %v. Expected: %x", checksum, err, digest)
+	}
+}
+
+func TestChecksumHashesObjectsWithoutOne(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+
+	file := []byte("OggS audio")
+
+	// Objects uploaded in parts have a checksum of checksums.
+	mockedS3.HeadObjectFuncMock = func(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		return &s3.HeadObjectOutput{ChecksumSHA256: aws.String("ZGlnZXN0-3")}, nil
+	}
+
+	mockedS3.GetObjectFuncMock = objectMock(file)
+
+	serviceHandler := &UploadService{s3: mockedS3}
+
+	checksum, err := serviceHandler.checksum(context.TODO(), "test")
+
+	if err != nil || checksum != fmt.Sprintf("%x", sha256.Sum256(file)) {
+		t.Errorf("The result is different from expected. Result: %v %v. Expected: %x", checksum, err, sha256.Sum256(file))
+	}
+}
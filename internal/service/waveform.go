@@ -0,0 +1,118 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	WAVEFORM_PREFIX = "waveforms/"
+
+	// WAVEFORM_RESOLUTION is how many peaks a waveform has, whatever the
+	// length of the audio.
+	WAVEFORM_RESOLUTION = 200
+)
+
+var WaveformNotFoundErr = errors.New("Waveform not found")
+
+type WaveformService struct {
+	s3 S3Bucket
+}
+
+type IWaveformService interface {
+	StoreWaveform(ctx context.Context, id string, waveform dto.WaveformDTO) error
+	GetWaveform(ctx context.Context, id string) (dto.WaveformOutput, error)
+	DeleteWaveform(ctx context.Context, id string) error
+}
+
+func NewWaveformService(s S3Bucket) IWaveformService {
+	return &WaveformService{
+		s3: s,
+	}
+}
+
+func (s *WaveformService) StoreWaveform(ctx context.Context, id string, waveform dto.WaveformDTO) error {
+	body, err := json.Marshal(waveform)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = s.s3.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(BUCKET_NAME),
+		Key:         aws.String(WaveformKey(id)),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	})
+
+	if err != nil {
+		log.Printf("Error putting object %s/%s: %s", BUCKET_NAME, WaveformKey(id), err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func (s *WaveformService) GetWaveform(ctx context.Context, id string) (dto.WaveformOutput, error) {
+	output, err := s.s3.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(BUCKET_NAME),
+		Key:    aws.String(WaveformKey(id)),
+	})
+
+	if err != nil {
+		var noSuchKey *s3types.NoSuchKey
+
+		if errors.As(err, &noSuchKey) {
+			return dto.WaveformOutput{}, WaveformNotFoundErr
+		}
+
+		log.Printf("Error getting object %s/%s: %s", BUCKET_NAME, WaveformKey(id), err.Error())
+		return dto.WaveformOutput{}, err
+	}
+
+	defer output.Body.Close()
+
+	body, err := io.ReadAll(output.Body)
+
+	if err != nil {
+		log.Printf("Error reading object %s/%s: %s", BUCKET_NAME, WaveformKey(id), err.Error())
+		return dto.WaveformOutput{}, err
+	}
+
+	return dto.WaveformOutput{Body: body, ETag: aws.ToString(output.ETag)}, nil
+}
+
+// DeleteWaveform succeeds when there is no waveform, as S3 does.
+func (s *WaveformService) DeleteWaveform(ctx context.Context, id string) error {
+	_, err := s.s3.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(BUCKET_NAME),
+		Key:    aws.String(WaveformKey(id)),
+	})
+
+	if err != nil {
+		log.Printf("Error deleting object %s/%s: %s", BUCKET_NAME, WaveformKey(id), err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func WaveformKey(id string) string {
+	return WAVEFORM_PREFIX + id + ".json"
+}
+
+// IsWaveform tells the sidecars apart from the audio, as storing them
+// creates objects in the same bucket.
+func IsWaveform(key string) bool {
+	return strings.HasPrefix(key, WAVEFORM_PREFIX)
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/LucasAndFlores/go_lambdas_project/internal/dto"
+	"github.com/LucasAndFlores/go_lambdas_project/internal/mocks"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestStoreWaveform(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+
+	mockedS3.PutObjectFuncMock = func(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		body, _ := io.ReadAll(params.Body)
+
+		if *params.Key != "waveforms/test.json" || string(body) != `{"durationMs":250,"peaks":[0,0.5]}` {
+			t.Errorf("The result is different from expected. Result: %v %s", *params.Key, body)
+		}
+
+		return &s3.PutObjectOutput{}, nil
+	}
+
+	serviceHandler := NewWaveformService(mockedS3)
+
+	err := serviceHandler.StoreWaveform(context.TODO(), "test", dto.WaveformDTO{DurationMs: 250, Peaks: []float64{0, 0.5}})
+
+	if err != nil {
+		t.Errorf("The result is different from expected. Expected nil. Result: %v", err)
+	}
+}
+
+func TestGetWaveform(t *testing.T) {
+	mockedS3 := mocks.MockedS3{}
+
+	mockedS3.GetObjectFuncMock = func(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+		if *params.Key != "waveforms/test.json" {
+			return nil, &s3types.NoSuchKey{}
+		}
+
+		return &s3.GetObjectOutput{
+			Body: io.NopCloser(bytes.NewReader([]byte(`{"peaks":[0.5]}`))),
+			ETag: aws.String(`"etag"`),
+		}, nil
+	}
+
+	serviceHandler := NewWaveformService(mockedS3)
+
+	result, err := serviceHandler.GetWaveform(context.TODO(), "test")
+
+	if err != nil || string(result.Body) != `{"peaks":[0.5]}` || result.ETag != `"etag"` {
+		t.Errorf("The result is different from expected. Result: %s %v %v", result.Body, result.ETag, err)
+	}
+
+	_, err = serviceHandler.GetWaveform(context.TODO(), "other")
+
+	if !errors.Is(err, WaveformNotFoundErr) {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", err, WaveformNotFoundErr)
+	}
+}
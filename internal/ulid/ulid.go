@@ -0,0 +1,73 @@
+package ulid
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"time"
+)
+
+// crockford is the base32 alphabet from the ULID spec. It has no I, L, O or
+// U, so IDs are safe in URLs and S3 keys and hard to misread.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// New returns a 26 character ULID: 48 bits of milliseconds since the Unix
+// epoch followed by 80 random bits. IDs sort by creation time.
+func New() string {
+	return newAt(time.Now())
+}
+
+func newAt(t time.Time) string {
+	var data [16]byte
+
+	binary.BigEndian.PutUint64(data[:8], uint64(t.UnixMilli())<<16)
+
+	_, err := rand.Read(data[6:])
+
+	if err != nil {
+		panic(err)
+	}
+
+	return encode(data)
+}
+
+func encode(data [16]byte) string {
+	hi := binary.BigEndian.Uint64(data[:8])
+	lo := binary.BigEndian.Uint64(data[8:])
+
+	var out [26]byte
+
+	// 128 bits in 26 characters of 5 bits: the first character only holds
+	// the 3 most significant bits.
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+
+	return string(out[:])
+}
+
+// Valid reports whether id has the shape of a ULID.
+func Valid(id string) bool {
+	if len(id) != 26 || id[0] > '7' {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if indexOf(id[i]) < 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func indexOf(c byte) int {
+	for i := 0; i < len(crockford); i++ {
+		if crockford[i] == c {
+			return i
+		}
+	}
+
+	return -1
+}
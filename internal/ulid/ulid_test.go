@@ -0,0 +1,60 @@
+package ulid
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewIsValidAndUnique(t *testing.T) {
+	first := New()
+	second := New()
+
+	if !Valid(first) || !Valid(second) {
+		t.Errorf("Expected valid IDs. Result: %v, %v", first, second)
+	}
+
+	if first == second {
+		t.Errorf("Expected different IDs. Result: %v", first)
+	}
+}
+
+func TestNewSortsByTime(t *testing.T) {
+	older := newAt(time.UnixMilli(1700000000000))
+	newer := newAt(time.UnixMilli(1700000000001))
+
+	if strings.Compare(older[:10], newer[:10]) >= 0 {
+		t.Errorf("Expected the older ID first. Older: %v. Newer: %v", older, newer)
+	}
+}
+
+func TestEncodeKnownValue(t *testing.T) {
+	var data [16]byte
+
+	for i := range data {
+		data[i] = 0xff
+	}
+
+	result := encode(data)
+	expected := "7ZZZZZZZZZZZZZZZZZZZZZZZZZ"
+
+	if result != expected {
+		t.Errorf("The result is different from expected. Result: %v. Expected: %v", result, expected)
+	}
+}
+
+func TestValid(t *testing.T) {
+	cases := map[string]bool{
+		"01ARZ3NDEKTSV4RRFFQ69G5FAV": true,
+		"01ARZ3NDEKTSV4RRFFQ69G5FA":  false,
+		"01ARZ3NDEKTSV4RRFFQ69G5FAU": false,
+		"81ARZ3NDEKTSV4RRFFQ69G5FAV": false,
+		"../../etc/passwd":           false,
+	}
+
+	for id, expected := range cases {
+		if Valid(id) != expected {
+			t.Errorf("The result is different from expected. Input: %v. Expected: %v", id, expected)
+		}
+	}
+}